@@ -1,15 +1,15 @@
 package main
 
 import (
-	"archive/zip"
-	"bytes"
 	"fmt"
 	"log"
 	"os"
 
 	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
 	"github.com/diegofxm/go-dian/pkg/dian"
 	"github.com/diegofxm/go-dian/pkg/invoice"
+	"github.com/diegofxm/go-dian/pkg/packaging"
 	"github.com/diegofxm/go-dian/pkg/soap"
 )
 
@@ -192,57 +192,14 @@ func main() {
 		},
 	}
 
-	// Agregar línea de factura
-	line := invoice.InvoiceLine{
-		ID: "1",
-		InvoicedQuantity: common.Quantity{
-			Value:    1.0,
-			UnitCode: "94",
-		},
-		LineExtensionAmount: common.AmountType{
-			Value:      100000.00,
-			CurrencyID: "COP",
-		},
-		Item: invoice.Item{
-			Description: "Servicio de consultoría",
-		},
-		Price: invoice.Price{
-			PriceAmount: common.AmountType{
-				Value:      100000.00,
-				CurrencyID: "COP",
-			},
-			BaseQuantity: common.Quantity{
-				Value:    1.0,
-				UnitCode: "94",
-			},
-		},
-		TaxTotal: []common.TaxTotal{
-			{
-				TaxAmount: common.AmountType{
-					Value:      19000.00,
-					CurrencyID: "COP",
-				},
-				TaxSubtotal: []common.TaxSubtotal{
-					{
-						TaxableAmount: common.AmountType{
-							Value:      100000.00,
-							CurrencyID: "COP",
-						},
-						TaxAmount: common.AmountType{
-							Value:      19000.00,
-							CurrencyID: "COP",
-						},
-						TaxCategory: common.TaxCategory{
-							Percent: 19.0,
-							TaxScheme: common.TaxScheme{
-								ID:   "01",
-								Name: "IVA",
-							},
-						},
-					},
-				},
-			},
-		},
+	// Agregar línea de factura: el builder calcula valor de la línea e IVA
+	line, err := invoice.NewLineBuilder("1", invoice.Item{Description: "Servicio de consultoría"}).
+		Quantity(decimal.NewFromInt(1), "94").
+		UnitPrice(decimal.NewFromInt(100000)).
+		Taxes(invoice.IVA19).
+		Build()
+	if err != nil {
+		log.Fatalf("error construyendo línea: %v", err)
 	}
 
 	inv.AddLine(line)
@@ -303,23 +260,23 @@ func main() {
 		log.Fatalf("❌ Error creando cliente SOAP: %v", err)
 	}
 
-	// Crear ZIP con la factura firmada
+	// Crear ZIP con la factura firmada (nomenclatura DIAN: fv/z + NIT + PPP + AA + consecutivo)
 	fmt.Println("\n📦 Creando archivo ZIP...")
-	zipData, err := createZIP("SETP990000001.xml", signedXML)
+	packager := packaging.NewPackager("6382356", "000")
+	pkg, err := packager.Package(packaging.Invoice, signedXML)
 	if err != nil {
 		log.Fatalf("❌ Error creando ZIP: %v", err)
 	}
-	fmt.Printf("✅ ZIP creado (%d bytes)\n", len(zipData))
+	fmt.Printf("✅ ZIP creado: %s (%d bytes)\n", pkg.ZipName, len(pkg.Data))
 
 	// Guardar ZIP (opcional, para debug)
-	if err := os.WriteFile("SETP990000001.zip", zipData, 0644); err != nil {
+	if err := os.WriteFile(pkg.ZipName, pkg.Data, 0644); err != nil {
 		log.Printf("⚠️  No se pudo guardar ZIP: %v", err)
 	}
 
 	// Enviar factura
 	fmt.Println("\n📤 Enviando factura a DIAN...")
-	fileName := "SETP990000001.zip"
-	response, err := soapClient.SendInvoice(fileName, zipData)
+	response, err := soapClient.SendInvoice(pkg.ZipName, pkg.Data)
 	if err != nil {
 		log.Fatalf("❌ Error enviando factura: %v", err)
 	}
@@ -344,24 +301,3 @@ func main() {
 
 	fmt.Println("\n=== PROCESO COMPLETADO ===")
 }
-
-// createZIP crea un archivo ZIP con el contenido especificado
-func createZIP(filename string, content []byte) ([]byte, error) {
-	var buf bytes.Buffer
-	zipWriter := zip.NewWriter(&buf)
-
-	fileWriter, err := zipWriter.Create(filename)
-	if err != nil {
-		return nil, fmt.Errorf("error creando archivo en ZIP: %w", err)
-	}
-
-	if _, err := fileWriter.Write(content); err != nil {
-		return nil, fmt.Errorf("error escribiendo contenido: %w", err)
-	}
-
-	if err := zipWriter.Close(); err != nil {
-		return nil, fmt.Errorf("error cerrando ZIP: %w", err)
-	}
-
-	return buf.Bytes(), nil
-}
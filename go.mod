@@ -1,5 +1,9 @@
 module github.com/diegofxm/go-dian
 
-go 1.23
+go 1.23.0
 
-require github.com/google/uuid v1.6.0
+require (
+	github.com/google/uuid v1.6.0
+	golang.org/x/crypto v0.40.0
+	software.sslmate.com/src/go-pkcs12 v0.5.0
+)
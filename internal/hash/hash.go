@@ -3,7 +3,6 @@ package hash
 import (
 	"crypto/sha512"
 	"encoding/hex"
-	"fmt"
 )
 
 func CalculateSHA384(data string) string {
@@ -12,48 +11,54 @@ func CalculateSHA384(data string) string {
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
-func CalculateCUFE(invoiceNumber, issueDate, issueTime, amount, taxAmount, totalAmount, nit, customerNIT, technicalKey, environment string) string {
-	// Formatear fecha: remover guiones (YYYY-MM-DD -> YYYYMMDD)
-	formattedDate := ""
-	for _, c := range issueDate {
-		if c != '-' {
-			formattedDate += string(c)
-		}
-	}
+// Códigos de los impuestos que componen el CUFE, en el orden del Anexo Técnico
+const (
+	CodImp1 = "01" // IVA
+	CodImp2 = "04" // INC
+	CodImp3 = "03" // ICA
+)
 
-	// Formatear hora: remover : y zona horaria (HH:MM:SS-05:00 -> HHMMSS)
-	formattedTime := ""
-	for _, c := range issueTime {
-		if c >= '0' && c <= '9' {
-			formattedTime += string(c)
-		}
-		if c == '-' || c == '+' {
-			break
-		}
-	}
-	if len(formattedTime) > 6 {
-		formattedTime = formattedTime[:6]
-	}
+// CUFEData contiene los campos del CUFE tal como aparecen en el XML.
+// FecFac y HorFac se usan sin modificar (ej: "2019-01-16" y "10:53:10-05:00");
+// los valores van con dos decimales y punto, y un impuesto vacío se toma como "0.00".
+type CUFEData struct {
+	NumFac       string // cbc:ID
+	FecFac       string // cbc:IssueDate
+	HorFac       string // cbc:IssueTime
+	ValFac       string // LineExtensionAmount
+	ValImp1      string // Suma de TaxAmount del IVA (01)
+	ValImp2      string // Suma de TaxAmount del INC (04)
+	ValImp3      string // Suma de TaxAmount del ICA (03)
+	ValTot       string // PayableAmount
+	NitOFE       string // NIT del facturador
+	NumAdq       string // Identificación del adquiriente
+	ClTec        string // Clave técnica del rango de numeración
+	TipoAmbiente string // 1 producción, 2 habilitación
+}
 
-	// Construir cadena CUFE según especificación DIAN
-	// NumFac + FecFac + HorFac + ValFac + CodImp1 + ValImp1 + CodImp2 + ValImp2 + CodImp3 + ValImp3 + ValTot + NitOFE + NumAdq + ClTec + TipoAmbiente
-	cufeData := fmt.Sprintf("%s%s%s%s%s%s%s%s%s%s%s%s%s%s%s",
-		invoiceNumber,
-		formattedDate,
-		formattedTime,
-		amount,
-		"01", // CodImp1 (IVA)
-		taxAmount,
-		"01", // CodImp2 (IVA adicional o 0)
-		"0.00",
-		"01", // CodImp3
-		"0.00",
-		totalAmount,
-		nit,
-		customerNIT,
-		technicalKey,
-		environment,
-	)
+// String retorna la cadena que se resume para obtener el CUFE:
+// NumFac + FecFac + HorFac + ValFac + CodImp1 + ValImp1 + CodImp2 + ValImp2 + CodImp3 + ValImp3 + ValTot + NitOFE + NumAdq + ClTec + TipoAmbiente
+func (d CUFEData) String() string {
+	return d.NumFac + d.FecFac + d.HorFac + d.ValFac +
+		CodImp1 + taxValue(d.ValImp1) +
+		CodImp2 + taxValue(d.ValImp2) +
+		CodImp3 + taxValue(d.ValImp3) +
+		d.ValTot + d.NitOFE + d.NumAdq + d.ClTec + d.TipoAmbiente
+}
+
+// CalculateCUFE calcula el CUFE (SHA-384) según el Anexo Técnico de factura electrónica.
+//
+// Ejemplo del Anexo Técnico: NumFac 323200000129, FecFac 2019-01-16, HorFac 10:53:10-05:00,
+// ValFac 1500000.00, ValImp1 285000.00, ValImp2 0.00, ValImp3 0.00, ValTot 1785000.00,
+// NitOFE 700085371, NumAdq 800199436, ClTec 693ff6f2a553c3646a063436fd4dd9ded0311471 y
+// TipoAmbiente 1 producen 8bb918b19ba22a694f1da11c643b5e9de39adf60311cf179179e9b33381030bcd4c3c3f156c506ed5908f9276f5bd9b4
+func CalculateCUFE(data CUFEData) string {
+	return CalculateSHA384(data.String())
+}
 
-	return CalculateSHA384(cufeData)
+func taxValue(value string) string {
+	if value == "" {
+		return "0.00"
+	}
+	return value
 }
@@ -0,0 +1,55 @@
+package hash
+
+import "testing"
+
+// anexoCUFE es el ejemplo de CUFE del Anexo Técnico de factura electrónica
+var anexoCUFE = CUFEData{
+	NumFac:       "323200000129",
+	FecFac:       "2019-01-16",
+	HorFac:       "10:53:10-05:00",
+	ValFac:       "1500000.00",
+	ValImp1:      "285000.00",
+	ValImp2:      "0.00",
+	ValImp3:      "0.00",
+	ValTot:       "1785000.00",
+	NitOFE:       "700085371",
+	NumAdq:       "800199436",
+	ClTec:        "693ff6f2a553c3646a063436fd4dd9ded0311471",
+	TipoAmbiente: "1",
+}
+
+func TestCalculateCUFE(t *testing.T) {
+	withoutTaxes := anexoCUFE
+	withoutTaxes.ValImp2, withoutTaxes.ValImp3 = "", ""
+
+	tests := []struct {
+		name     string
+		data     CUFEData
+		input    string
+		expected string
+	}{
+		{
+			name:     "ejemplo del Anexo Técnico",
+			data:     anexoCUFE,
+			input:    "323200000129" + "2019-01-16" + "10:53:10-05:00" + "1500000.00" + "01" + "285000.00" + "04" + "0.00" + "03" + "0.00" + "1785000.00" + "700085371" + "800199436" + "693ff6f2a553c3646a063436fd4dd9ded0311471" + "1",
+			expected: "8bb918b19ba22a694f1da11c643b5e9de39adf60311cf179179e9b33381030bcd4c3c3f156c506ed5908f9276f5bd9b4",
+		},
+		{
+			name:     "impuestos vacíos se toman como 0.00",
+			data:     withoutTaxes,
+			input:    anexoCUFE.String(),
+			expected: "8bb918b19ba22a694f1da11c643b5e9de39adf60311cf179179e9b33381030bcd4c3c3f156c506ed5908f9276f5bd9b4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.data.String(); got != tt.input {
+				t.Errorf("String() = %s, se esperaba %s", got, tt.input)
+			}
+			if got := CalculateCUFE(tt.data); got != tt.expected {
+				t.Errorf("CalculateCUFE = %s, se esperaba %s", got, tt.expected)
+			}
+		})
+	}
+}
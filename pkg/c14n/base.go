@@ -0,0 +1,95 @@
+package c14n
+
+import (
+	"net/url"
+	"strings"
+)
+
+// joinBase combina el xml:base de un ancestro con el de un descendiente según
+// Canonical XML 1.1, sección 2.4: resolución RFC 3986 que conserva los
+// segmentos ".." iniciales cuando la base es relativa.
+func joinBase(base, ref string) string {
+	if base == "" {
+		return ref
+	}
+	if ref == "" {
+		return base
+	}
+
+	if u, err := url.Parse(ref); err == nil && u.Scheme != "" {
+		return ref
+	}
+	if b, err := url.Parse(base); err == nil && b.Scheme != "" {
+		if r, err := url.Parse(ref); err == nil {
+			return b.ResolveReference(r).String()
+		}
+	}
+
+	// Base relativa
+	var path string
+	switch {
+	case strings.HasPrefix(ref, "//"), strings.HasPrefix(ref, "/"):
+		return ref
+	case strings.HasPrefix(ref, "#"):
+		if i := strings.IndexByte(base, '#'); i >= 0 {
+			base = base[:i]
+		}
+		return base + ref
+	case strings.HasPrefix(ref, "?"):
+		if i := strings.IndexAny(base, "?#"); i >= 0 {
+			base = base[:i]
+		}
+		return base + ref
+	default:
+		// Fusión: directorio de la base + referencia
+		trimmed := base
+		if i := strings.IndexAny(trimmed, "?#"); i >= 0 {
+			trimmed = trimmed[:i]
+		}
+		if i := strings.LastIndexByte(trimmed, '/'); i >= 0 {
+			path = trimmed[:i+1] + ref
+		} else {
+			path = ref
+		}
+	}
+	return removeDotSegments(path)
+}
+
+// removeDotSegments aplica RFC 3986 sección 5.2.4 a una ruta relativa,
+// conservando los ".." que no tienen segmento previo que eliminar
+func removeDotSegments(path string) string {
+	suffix := ""
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path, suffix = path[:i], path[i:]
+	}
+
+	absolute := strings.HasPrefix(path, "/")
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	var out []string
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 0 && out[len(out)-1] != ".." {
+				out = out[:len(out)-1]
+			} else if !absolute {
+				out = append(out, "..")
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+
+	result := strings.Join(out, "/")
+	if absolute {
+		result = "/" + result
+	}
+	return result + suffix
+}
@@ -0,0 +1,618 @@
+// Package c14n implementa canonicalización XML para documentos completos y subárboles:
+// Canonical XML 1.0 (https://www.w3.org/TR/xml-c14n), Canonical XML 1.1
+// (https://www.w3.org/TR/xml-c14n11) y Exclusive XML Canonicalization
+// (https://www.w3.org/TR/xml-exc-c14n), con y sin comentarios.
+//
+// Los documentos se procesan sin DTD: no se agregan atributos por defecto ni se
+// expanden entidades distintas de las predefinidas.
+package c14n
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Algoritmos de canonicalización
+const (
+	C14N10              = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	C14N10WithComments  = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315#WithComments"
+	C14N11              = "http://www.w3.org/2006/12/xml-c14n11"
+	C14N11WithComments  = "http://www.w3.org/2006/12/xml-c14n11#WithComments"
+	ExcC14N             = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	ExcC14NWithComments = "http://www.w3.org/2001/10/xml-exc-c14n#WithComments"
+)
+
+// xmlNamespace es el namespace reservado del prefijo xml
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// DefaultPrefix representa el namespace por defecto en InclusivePrefixes
+const DefaultPrefix = "#default"
+
+// Options configura la canonicalización. El valor cero es Canonical XML 1.0 sin comentarios.
+type Options struct {
+	// WithComments conserva los comentarios
+	WithComments bool
+
+	// Version11 aplica Canonical XML 1.1: en subárboles solo se heredan xml:lang y xml:space,
+	// y xml:base se combina con el de los ancestros
+	Version11 bool
+
+	// Exclusive aplica Exclusive XML Canonicalization: solo se emiten los namespaces
+	// utilizados visiblemente y no se heredan atributos xml:*
+	Exclusive bool
+
+	// InclusivePrefixes es el PrefixList de ec:InclusiveNamespaces (solo con Exclusive):
+	// prefijos que se emiten según las reglas de la canonicalización inclusiva.
+	// DefaultPrefix representa el namespace por defecto.
+	InclusivePrefixes []string
+
+	// Exclude omite los elementos (y sus subárboles) para los que retorna true,
+	// ej: la transformación enveloped-signature excluye ds:Signature
+	Exclude func(xml.StartElement) bool
+}
+
+// ForAlgorithm retorna las opciones correspondientes a la URI de un algoritmo de canonicalización
+func ForAlgorithm(algorithm string) (Options, error) {
+	switch algorithm {
+	case C14N10:
+		return Options{}, nil
+	case C14N10WithComments:
+		return Options{WithComments: true}, nil
+	case C14N11:
+		return Options{Version11: true}, nil
+	case C14N11WithComments:
+		return Options{Version11: true, WithComments: true}, nil
+	case ExcC14N:
+		return Options{Exclusive: true}, nil
+	case ExcC14NWithComments:
+		return Options{Exclusive: true, WithComments: true}, nil
+	default:
+		return Options{}, fmt.Errorf("c14n: algoritmo no soportado: %s", algorithm)
+	}
+}
+
+// Algorithm retorna la URI del algoritmo que corresponde a las opciones
+func (o Options) Algorithm() string {
+	switch {
+	case o.Exclusive && o.WithComments:
+		return ExcC14NWithComments
+	case o.Exclusive:
+		return ExcC14N
+	case o.Version11 && o.WithComments:
+		return C14N11WithComments
+	case o.Version11:
+		return C14N11
+	case o.WithComments:
+		return C14N10WithComments
+	default:
+		return C14N10
+	}
+}
+
+// Canonicalize canonicaliza un documento XML completo
+func Canonicalize(data []byte, opts Options) ([]byte, error) {
+	return canonicalize(data, nil, opts)
+}
+
+// CanonicalizeSubtree canonicaliza el primer elemento para el que match retorna true,
+// incluyendo los namespaces y atributos xml:* heredados de sus ancestros.
+// Los nombres pasados a match y Exclude están resueltos (Name.Space es la URI del namespace).
+func CanonicalizeSubtree(data []byte, match func(xml.StartElement) bool, opts Options) ([]byte, error) {
+	if match == nil {
+		return nil, fmt.Errorf("c14n: se requiere una función de selección")
+	}
+	return canonicalize(data, match, opts)
+}
+
+// Count retorna cuántos elementos del documento cumplen match. Las verificaciones de firma lo usan
+// para exigir que un Id resuelva a un único elemento antes de canonicalizarlo (XML Signature Wrapping).
+func Count(data []byte, match func(xml.StartElement) bool) (int, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	count := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("c14n: error parseando XML: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok && match(start) {
+			count++
+		}
+	}
+}
+
+// ByID retorna una función de selección por atributo Id (cualquier namespace) o ID
+func ByID(id string) func(xml.StartElement) bool {
+	return func(start xml.StartElement) bool {
+		for _, attr := range start.Attr {
+			if (attr.Name.Local == "Id" || attr.Name.Local == "ID" || attr.Name.Local == "id") && attr.Value == id {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ByName retorna una función de selección por namespace y nombre local
+func ByName(space, local string) func(xml.StartElement) bool {
+	return func(start xml.StartElement) bool {
+		return start.Name.Space == space && start.Name.Local == local
+	}
+}
+
+// frame es el estado de un elemento abierto
+type frame struct {
+	raw      xml.Name          // nombre tal como aparece en el documento (prefijo:local)
+	scope    map[string]string // namespaces en alcance (prefijo -> URI)
+	rendered map[string]string // namespaces emitidos por el ancestro visible más cercano
+	xmlAttrs map[string]string // atributos xml:* en alcance
+	base     string            // xml:base efectivo, combinado con el de los ancestros (C14N 1.1)
+	visible  bool
+	excluded bool
+}
+
+func canonicalize(data []byte, match func(xml.StartElement) bool, opts Options) ([]byte, error) {
+	if opts.Exclusive && opts.Version11 {
+		return nil, fmt.Errorf("c14n: Exclusive y Version11 son excluyentes")
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(normalizeAttributes(data)))
+	var buf bytes.Buffer
+
+	root := &frame{
+		scope:    map[string]string{"xml": xmlNamespace},
+		rendered: map[string]string{},
+		xmlAttrs: map[string]string{},
+		visible:  match == nil,
+	}
+	stack := []*frame{root}
+	found := false
+	seenRoot := false
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("c14n: error parseando XML: %w", err)
+		}
+
+		parent := stack[len(stack)-1]
+		topLevel := len(stack) == 1
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			seenRoot = true
+			current := &frame{
+				raw:      t.Name,
+				scope:    copyMap(parent.scope),
+				xmlAttrs: copyMap(parent.xmlAttrs),
+				base:     parent.base,
+				excluded: parent.excluded,
+			}
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					current.scope[attr.Name.Local] = attr.Value
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					current.scope[""] = attr.Value
+				case attr.Name.Space == "xml":
+					current.xmlAttrs[attr.Name.Local] = attr.Value
+					if attr.Name.Local == "base" {
+						current.base = joinBase(parent.base, attr.Value)
+					}
+				}
+			}
+
+			resolved, err := resolve(t, current.scope)
+			if err != nil {
+				return nil, err
+			}
+
+			apex := false
+			if !current.excluded && opts.Exclude != nil && opts.Exclude(resolved) {
+				current.excluded = true
+			}
+			current.visible = parent.visible
+			if match != nil && !found && !current.excluded && match(resolved) {
+				found = true
+				apex = true
+				current.visible = true
+			}
+
+			if current.visible && !current.excluded {
+				current.rendered = writeStart(&buf, t, current, parent, apex, opts)
+			} else {
+				current.rendered = parent.rendered
+			}
+			stack = append(stack, current)
+
+		case xml.EndElement:
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if current.visible && !current.excluded {
+				buf.WriteString("</")
+				buf.WriteString(qualified(current.raw))
+				buf.WriteString(">")
+			}
+			if match != nil && found && current.visible && !stack[len(stack)-1].visible {
+				// Fin del subárbol seleccionado
+				return buf.Bytes(), nil
+			}
+
+		case xml.CharData:
+			if topLevel || !parent.visible || parent.excluded {
+				continue
+			}
+			escapeText(&buf, string(t))
+
+		case xml.Comment:
+			if !opts.WithComments || parent.excluded || (!topLevel && !parent.visible) || (topLevel && match != nil) {
+				continue
+			}
+			if topLevel && seenRoot {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("<!--")
+			buf.Write(t)
+			buf.WriteString("-->")
+			if topLevel && !seenRoot {
+				buf.WriteString("\n")
+			}
+
+		case xml.ProcInst:
+			if t.Target == "xml" || parent.excluded || (!topLevel && !parent.visible) || (topLevel && match != nil) {
+				continue
+			}
+			if topLevel && seenRoot {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("<?")
+			buf.WriteString(t.Target)
+			if len(t.Inst) > 0 {
+				buf.WriteString(" ")
+				buf.Write(t.Inst)
+			}
+			buf.WriteString("?>")
+			if topLevel && !seenRoot {
+				buf.WriteString("\n")
+			}
+		}
+	}
+
+	if match != nil && !found {
+		return nil, fmt.Errorf("c14n: elemento no encontrado")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeStart emite la etiqueta de inicio y retorna los namespaces emitidos en alcance
+func writeStart(buf *bytes.Buffer, start xml.StartElement, current, parent *frame, apex bool, opts Options) map[string]string {
+	parentRendered := parent.rendered
+	if apex {
+		parentRendered = map[string]string{}
+	}
+
+	// Namespaces: se emiten los que difieren de los emitidos por el ancestro visible.
+	// La canonicalización exclusiva solo considera los utilizados visiblemente y los de InclusivePrefixes.
+	var candidates []string
+	if opts.Exclusive {
+		candidates = utilizedPrefixes(start, current.scope, opts.InclusivePrefixes)
+	} else {
+		for prefix := range current.scope {
+			candidates = append(candidates, prefix)
+		}
+	}
+
+	rendered := copyMap(parentRendered)
+	var prefixes []string
+	for _, prefix := range candidates {
+		if prefix == "xml" {
+			continue
+		}
+		uri := current.scope[prefix]
+		if prev, ok := parentRendered[prefix]; ok && prev == uri {
+			continue
+		}
+		if prefix == "" && uri == "" && parentRendered[""] == "" {
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+		rendered[prefix] = uri
+	}
+	sort.Strings(prefixes)
+
+	// Atributos (excluyendo declaraciones de namespace)
+	type attribute struct {
+		space string
+		name  string
+		value string
+	}
+	var attrs []attribute
+	present := map[string]bool{}
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		space := ""
+		if attr.Name.Space != "" {
+			space = current.scope[attr.Name.Space]
+		}
+		value := attr.Value
+		if attr.Name.Space == "xml" {
+			present[attr.Name.Local] = true
+			if apex && opts.Version11 && attr.Name.Local == "base" {
+				value = current.base
+			}
+		}
+		attrs = append(attrs, attribute{space: space, name: qualified(attr.Name), value: value})
+	}
+
+	// En el ápice de un subárbol se heredan los atributos xml:* de los ancestros.
+	// C14N 1.1 hereda solo xml:lang y xml:space y combina xml:base; la exclusiva no hereda ninguno.
+	if apex && !opts.Exclusive {
+		for local, value := range current.xmlAttrs {
+			if present[local] {
+				continue
+			}
+			if opts.Version11 {
+				switch local {
+				case "lang", "space":
+				case "base":
+					value = current.base
+				default:
+					continue
+				}
+			}
+			attrs = append(attrs, attribute{space: xmlNamespace, name: "xml:" + local, value: value})
+		}
+	}
+
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].space != attrs[j].space {
+			return attrs[i].space < attrs[j].space
+		}
+		return localName(attrs[i].name) < localName(attrs[j].name)
+	})
+
+	buf.WriteString("<")
+	buf.WriteString(qualified(start.Name))
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(" xmlns:")
+			buf.WriteString(prefix)
+			buf.WriteString(`="`)
+		}
+		escapeAttr(buf, current.scope[prefix])
+		buf.WriteString(`"`)
+	}
+	for _, attr := range attrs {
+		buf.WriteString(" ")
+		buf.WriteString(attr.name)
+		buf.WriteString(`="`)
+		escapeAttr(buf, attr.value)
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+
+	return rendered
+}
+
+// utilizedPrefixes retorna los prefijos utilizados visiblemente por el elemento y sus atributos,
+// más los de inclusive que están en alcance (Exclusive XML Canonicalization, sección 3)
+func utilizedPrefixes(start xml.StartElement, scope map[string]string, inclusive []string) []string {
+	seen := map[string]bool{start.Name.Space: true}
+	for _, attr := range start.Attr {
+		if attr.Name.Space != "" && attr.Name.Space != "xmlns" {
+			seen[attr.Name.Space] = true
+		}
+	}
+	for _, prefix := range inclusive {
+		if prefix == DefaultPrefix {
+			prefix = ""
+		}
+		if _, ok := scope[prefix]; ok {
+			seen[prefix] = true
+		}
+	}
+
+	prefixes := make([]string, 0, len(seen))
+	for prefix := range seen {
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+// resolve traduce los prefijos del elemento y sus atributos a URIs de namespace
+func resolve(start xml.StartElement, scope map[string]string) (xml.StartElement, error) {
+	resolved := xml.StartElement{Name: start.Name}
+	uri, ok := scope[start.Name.Space]
+	if !ok && start.Name.Space != "" {
+		return resolved, fmt.Errorf("c14n: prefijo no declarado %q", start.Name.Space)
+	}
+	resolved.Name.Space = uri
+
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		name := attr.Name
+		if name.Space != "" {
+			space, ok := scope[name.Space]
+			if !ok {
+				return resolved, fmt.Errorf("c14n: prefijo no declarado %q", name.Space)
+			}
+			name.Space = space
+		}
+		resolved.Attr = append(resolved.Attr, xml.Attr{Name: name, Value: attr.Value})
+	}
+
+	return resolved, nil
+}
+
+func qualified(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func localName(qname string) string {
+	if i := strings.IndexByte(qname, ':'); i >= 0 {
+		return qname[i+1:]
+	}
+	return qname
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func escapeText(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+func escapeAttr(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '"':
+			buf.WriteString("&quot;")
+		case '\t':
+			buf.WriteString("&#x9;")
+		case '\n':
+			buf.WriteString("&#xA;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+// normalizeAttributes aplica la normalización de valores de atributo de XML 1.0 (sección 3.3.3),
+// que encoding/xml omite: los tabuladores y saltos de línea literales dentro de un valor se
+// reemplazan por un espacio (CRLF cuenta como un solo salto). Las referencias de carácter
+// (&#x9;, &#xA;, &#xD;) no se tocan y escapeAttr las emite escapadas.
+func normalizeAttributes(data []byte) []byte {
+	if !bytes.ContainsAny(data, "\t\n\r") {
+		return data
+	}
+
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		rest := data[i:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			i += copyUntil(&out, rest, "-->")
+		case bytes.HasPrefix(rest, []byte("<![CDATA[")):
+			i += copyUntil(&out, rest, "]]>")
+		case bytes.HasPrefix(rest, []byte("<?")):
+			i += copyUntil(&out, rest, "?>")
+		case bytes.HasPrefix(rest, []byte("<!")):
+			i += copyDirective(&out, rest)
+		case rest[0] == '<':
+			i += copyTag(&out, rest)
+		default:
+			out = append(out, rest[0])
+			i++
+		}
+	}
+	return out
+}
+
+// copyUntil copia data hasta end inclusive (o hasta el final si no aparece) y retorna los bytes consumidos
+func copyUntil(out *[]byte, data []byte, end string) int {
+	n := len(data)
+	if j := bytes.Index(data, []byte(end)); j >= 0 {
+		n = j + len(end)
+	}
+	*out = append(*out, data[:n]...)
+	return n
+}
+
+// copyDirective copia una declaración <!...> (ej: DOCTYPE con subconjunto interno) sin modificarla
+func copyDirective(out *[]byte, data []byte) int {
+	depth := 0
+	var quote byte
+	n := len(data)
+loop:
+	for j := 2; j < len(data); j++ {
+		switch c := data[j]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '>' && depth <= 0:
+			n = j + 1
+			break loop
+		}
+	}
+	*out = append(*out, data[:n]...)
+	return n
+}
+
+// copyTag copia una etiqueta reemplazando los espacios en blanco literales de los valores de atributo
+func copyTag(out *[]byte, data []byte) int {
+	var quote byte
+	for j := 0; j < len(data); j++ {
+		c := data[j]
+		switch {
+		case quote == 0:
+			*out = append(*out, c)
+			if c == '"' || c == '\'' {
+				quote = c
+			} else if c == '>' {
+				return j + 1
+			}
+		case c == quote:
+			*out = append(*out, c)
+			quote = 0
+		case c == '\r' && j+1 < len(data) && data[j+1] == '\n':
+			// CRLF es un único fin de línea
+			*out = append(*out, ' ')
+			j++
+		case c == '\t' || c == '\n' || c == '\r':
+			*out = append(*out, ' ')
+		default:
+			*out = append(*out, c)
+		}
+	}
+	return len(data)
+}
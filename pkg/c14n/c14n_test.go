@@ -0,0 +1,257 @@
+package c14n
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+// Ejemplos de Canonical XML 1.0, sección 3 (https://www.w3.org/TR/xml-c14n#Examples).
+// Los documentos se procesan sin DTD, por eso se omiten los atributos por defecto y las
+// normalizaciones que dependen de tipos declarados (ID, NMTOKENS) y de entidades externas.
+
+const w3cPIs = `<?xml version="1.0"?>
+
+<?xml-stylesheet   href="doc.xsl"
+   type="text/xsl"   ?>
+
+<!DOCTYPE doc SYSTEM "doc.dtd">
+
+<doc>Hello, world!<!-- Comment 1 --></doc>
+
+<?pi-without-data     ?>
+
+<!-- Comment 2 -->
+
+<!-- Comment 3 -->`
+
+const w3cWhitespace = `<doc>
+   <clean>   </clean>
+   <dirty>   A   B   </dirty>
+   <mixed>
+      A
+      <clean>   </clean>
+      B
+      <dirty>   A   B   </dirty>
+      C
+   </mixed>
+</doc>`
+
+const w3cTags = `<doc>
+   <e1   />
+   <e2   ></e2>
+   <e3   name = "elem3"   id="elem3"   />
+   <e4   name="elem4"   id="elem4"   ></e4>
+   <e5 a:attr="out" b:attr="sorted" attr2="all" attr="I'm"
+      xmlns:b="http://www.ietf.org"
+      xmlns:a="http://www.w3.org"
+      xmlns="http://example.org"/>
+   <e6 xmlns="" xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="" xmlns:a="http://www.w3.org">
+            <e9 xmlns="" xmlns:a="http://www.ietf.org"/>
+         </e8>
+      </e7>
+   </e6>
+</doc>`
+
+const w3cCharacters = `<doc>
+   <text>First line&#x0d;&#10;Second line</text>
+   <value>&#x32;</value>
+   <compute><![CDATA[value>"0" && value<"10" ?"valid":"error"]]></compute>
+   <compute expr='value>"0" &amp;&amp; value&lt;"10" ?"valid":"error"'>valid</compute>
+   <norm attr=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>
+</doc>`
+
+// Ejemplos de Exclusive XML Canonicalization, sección 2.2 (https://www.w3.org/TR/xml-exc-c14n#sec-Enveloping)
+
+const excEnveloped = `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2>
+</n0:local>`
+
+const excEnveloping = `<n2:pdu xmlns:n1="http://example.com"
+           xmlns:n2="http://foo.example"
+           xml:lang="fr"
+           xml:space="retain">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2>
+</n2:pdu>`
+
+const xmlAttrs = `<a xml:lang="en" xml:id="a1" xml:base="http://example.org/x/"><b xml:base="y/"><c/></b></a>`
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected string
+	}{
+		{
+			name:  "3.1 PIs, comentarios y contenido fuera del elemento raíz",
+			input: w3cPIs,
+			expected: "<?xml-stylesheet href=\"doc.xsl\"\n   type=\"text/xsl\"   ?>\n" +
+				"<doc>Hello, world!</doc>\n" +
+				"<?pi-without-data?>",
+		},
+		{
+			name:  "3.1 con comentarios",
+			input: w3cPIs,
+			opts:  Options{WithComments: true},
+			expected: "<?xml-stylesheet href=\"doc.xsl\"\n   type=\"text/xsl\"   ?>\n" +
+				"<doc>Hello, world!<!-- Comment 1 --></doc>\n" +
+				"<?pi-without-data?>\n" +
+				"<!-- Comment 2 -->\n" +
+				"<!-- Comment 3 -->",
+		},
+		{
+			name:     "3.2 espacios en blanco en el contenido",
+			input:    w3cWhitespace,
+			expected: w3cWhitespace,
+		},
+		{
+			name:  "3.3 etiquetas de inicio y fin",
+			input: w3cTags,
+			expected: `<doc>
+   <e1></e1>
+   <e2></e2>
+   <e3 id="elem3" name="elem3"></e3>
+   <e4 id="elem4" name="elem4"></e4>
+   <e5 xmlns="http://example.org" xmlns:a="http://www.w3.org" xmlns:b="http://www.ietf.org" attr="I'm" attr2="all" b:attr="sorted" a:attr="out"></e5>
+   <e6 xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="">
+            <e9 xmlns:a="http://www.ietf.org"></e9>
+         </e8>
+      </e7>
+   </e6>
+</doc>`,
+		},
+		{
+			name:  "3.4 caracteres y referencias de carácter",
+			input: w3cCharacters,
+			expected: "<doc>\n" +
+				"   <text>First line&#xD;\nSecond line</text>\n" +
+				"   <value>2</value>\n" +
+				`   <compute>value&gt;"0" &amp;&amp; value&lt;"10" ?"valid":"error"</compute>` + "\n" +
+				`   <compute expr="value>&quot;0&quot; &amp;&amp; value&lt;&quot;10&quot; ?&quot;valid&quot;:&quot;error&quot;">valid</compute>` + "\n" +
+				`   <norm attr=" '    &#xD;&#xA;&#x9;   ' "></norm>` + "\n" +
+				"</doc>",
+		},
+		{
+			// XML 1.0 sección 3.3.3: tabuladores y saltos de línea literales se normalizan a espacios
+			name:     "espacios en blanco literales en atributos",
+			input:    "<e a=\"x\ny\" b='1\t2\r\n3\r4'\n   c=\"&#x9;\"/>",
+			expected: `<e a="x y" b="1 2 3 4" c="&#x9;"></e>`,
+		},
+		{
+			name:     "espacios en blanco en comentarios y CDATA se conservan",
+			input:    "<!DOCTYPE e [\n<!ATTLIST e a CDATA \"x\ty\">\n]>\n<e><!--a=\"x\ny\"--><![CDATA[<f a=\"x\ny\"/>]]></e>",
+			opts:     Options{WithComments: true},
+			expected: "<e><!--a=\"x\ny\"-->&lt;f a=\"x\ny\"/&gt;</e>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Canonicalize([]byte(tt.input), tt.opts)
+			if err != nil {
+				t.Fatalf("Canonicalize: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("resultado:\n%s\nse esperaba:\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeSubtree(t *testing.T) {
+	elem2 := ByName("http://example.net", "elem2")
+
+	tests := []struct {
+		name     string
+		input    string
+		match    func(xml.StartElement) bool
+		opts     Options
+		expected string
+	}{
+		{
+			name:  "exc-c14n 2.2 inclusiva, documento envolvente",
+			input: excEnveloped,
+			match: elem2,
+			expected: `<n1:elem2 xmlns:n0="foo:bar" xmlns:n1="http://example.net" xmlns:n3="ftp://example.org" xml:lang="en">
+    <n3:stuff></n3:stuff>
+  </n1:elem2>`,
+		},
+		{
+			name:  "exc-c14n 2.2 exclusiva, documento envolvente",
+			input: excEnveloped,
+			match: elem2,
+			opts:  Options{Exclusive: true},
+			expected: `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`,
+		},
+		{
+			name:  "exc-c14n 2.2 inclusiva, PDU",
+			input: excEnveloping,
+			match: elem2,
+			expected: `<n1:elem2 xmlns:n1="http://example.net" xmlns:n2="http://foo.example" xml:lang="en" xml:space="retain">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`,
+		},
+		{
+			name:  "exc-c14n 2.2 exclusiva, PDU",
+			input: excEnveloping,
+			match: elem2,
+			opts:  Options{Exclusive: true},
+			expected: `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`,
+		},
+		{
+			name:  "exc-c14n con InclusiveNamespaces",
+			input: excEnveloping,
+			match: elem2,
+			opts:  Options{Exclusive: true, InclusivePrefixes: []string{"n2"}},
+			expected: `<n1:elem2 xmlns:n1="http://example.net" xmlns:n2="http://foo.example" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`,
+		},
+		{
+			// C14N 1.0 hereda todos los atributos xml:* del ancestro más cercano
+			name:     "C14N 1.0 hereda xml:*",
+			input:    xmlAttrs,
+			match:    ByName("", "c"),
+			expected: `<c xml:base="y/" xml:id="a1" xml:lang="en"></c>`,
+		},
+		{
+			// C14N 1.1 sección 2.4: xml:id no se hereda y xml:base se combina con el de los ancestros
+			name:     "C14N 1.1 combina xml:base y omite xml:id",
+			input:    xmlAttrs,
+			match:    ByName("", "c"),
+			opts:     Options{Version11: true},
+			expected: `<c xml:base="http://example.org/x/y/" xml:lang="en"></c>`,
+		},
+		{
+			name:     "exc-c14n no hereda xml:*",
+			input:    xmlAttrs,
+			match:    ByName("", "c"),
+			opts:     Options{Exclusive: true},
+			expected: `<c></c>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CanonicalizeSubtree([]byte(tt.input), tt.match, tt.opts)
+			if err != nil {
+				t.Fatalf("CanonicalizeSubtree: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("resultado:\n%s\nse esperaba:\n%s", got, tt.expected)
+			}
+		})
+	}
+}
@@ -3,15 +3,35 @@ package common
 import (
 	"encoding/xml"
 	"fmt"
+
+	"github.com/diegofxm/go-dian/pkg/decimal"
 )
 
+// QuantityPlaces es el número de decimales con que se emiten las cantidades
+const QuantityPlaces = 4
+
 // AmountType representa un monto con moneda
 type AmountType struct {
-	Value      float64 `xml:"-"`
-	CurrencyID string  `xml:"currencyID,attr"`
+	Value      decimal.Decimal `xml:"-"`
+	CurrencyID string          `xml:"currencyID,attr"`
 }
 
-// MarshalXML implementa xml.Marshaler para evitar notación científica
+// NewAmount crea un monto redondeado según la precisión de la moneda
+func NewAmount(value decimal.Decimal, currencyID string) AmountType {
+	return AmountType{Value: RoundAmount(value, currencyID), CurrencyID: currencyID}
+}
+
+// Rounded retorna el monto redondeado según la precisión de su moneda
+func (a AmountType) Rounded() AmountType {
+	return NewAmount(a.Value, a.CurrencyID)
+}
+
+// String retorna el valor con los decimales de la moneda (ej: "1190.00")
+func (a AmountType) String() string {
+	return RoundAmount(a.Value, a.CurrencyID).String()
+}
+
+// MarshalXML emite el valor con los decimales de la moneda
 func (a AmountType) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	type Alias AmountType
 	aux := struct {
@@ -19,18 +39,36 @@ func (a AmountType) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 		Value string `xml:",chardata"`
 	}{
 		Alias: (*Alias)(&a),
-		Value: fmt.Sprintf("%.2f", a.Value),
+		Value: a.String(),
 	}
 	return e.EncodeElement(aux, start)
 }
 
+// UnmarshalXML lee el valor sin pérdida de precisión
+func (a *AmountType) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var aux struct {
+		Value      string `xml:",chardata"`
+		CurrencyID string `xml:"currencyID,attr"`
+	}
+	if err := d.DecodeElement(&aux, &start); err != nil {
+		return err
+	}
+	value, err := decimal.Parse(aux.Value)
+	if err != nil {
+		return fmt.Errorf("monto inválido en %s: %w", start.Name.Local, err)
+	}
+	a.Value = value
+	a.CurrencyID = aux.CurrencyID
+	return nil
+}
+
 // Quantity representa una cantidad con unidad de medida
 type Quantity struct {
-	Value    float64 `xml:"-"`
-	UnitCode string  `xml:"unitCode,attr"`
+	Value    decimal.Decimal `xml:"-"`
+	UnitCode string          `xml:"unitCode,attr"`
 }
 
-// MarshalXML implementa xml.Marshaler para evitar notación científica
+// MarshalXML emite la cantidad con QuantityPlaces decimales
 func (q Quantity) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	type Alias Quantity
 	aux := struct {
@@ -38,7 +76,25 @@ func (q Quantity) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 		Value string `xml:",chardata"`
 	}{
 		Alias: (*Alias)(&q),
-		Value: fmt.Sprintf("%.4f", q.Value),
+		Value: q.Value.StringFixed(QuantityPlaces),
 	}
 	return e.EncodeElement(aux, start)
 }
+
+// UnmarshalXML lee la cantidad sin pérdida de precisión
+func (q *Quantity) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var aux struct {
+		Value    string `xml:",chardata"`
+		UnitCode string `xml:"unitCode,attr"`
+	}
+	if err := d.DecodeElement(&aux, &start); err != nil {
+		return err
+	}
+	value, err := decimal.Parse(aux.Value)
+	if err != nil {
+		return fmt.Errorf("cantidad inválida en %s: %w", start.Name.Local, err)
+	}
+	q.Value = value
+	q.UnitCode = aux.UnitCode
+	return nil
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"sync"
+
+	"github.com/diegofxm/go-dian/pkg/decimal"
+)
+
+// CurrencyCOP es el código ISO 4217 del peso colombiano, moneda en la que DIAN liquida los tributos
+const CurrencyCOP = "COP"
+
+// CurrencyPrecision define los decimales y el redondeo de los montos de una moneda
+type CurrencyPrecision struct {
+	Places int32
+	Mode   decimal.RoundingMode
+}
+
+// DefaultPrecision es la precisión de las monedas sin configuración propia:
+// 2 decimales con redondeo mitad hacia arriba, como exige DIAN para COP
+var DefaultPrecision = CurrencyPrecision{Places: 2, Mode: decimal.RoundHalfUp}
+
+var (
+	precisionMu sync.RWMutex
+	precisions  = map[string]CurrencyPrecision{
+		CurrencyCOP: DefaultPrecision,
+	}
+)
+
+// SetCurrencyPrecision configura la precisión de una moneda (código ISO 4217)
+func SetCurrencyPrecision(currencyID string, precision CurrencyPrecision) {
+	precisionMu.Lock()
+	defer precisionMu.Unlock()
+	precisions[currencyID] = precision
+}
+
+// PrecisionFor retorna la precisión configurada para una moneda, o DefaultPrecision
+func PrecisionFor(currencyID string) CurrencyPrecision {
+	precisionMu.RLock()
+	defer precisionMu.RUnlock()
+	if precision, ok := precisions[currencyID]; ok {
+		return precision
+	}
+	return DefaultPrecision
+}
+
+// RoundAmount redondea un valor según la precisión de la moneda
+func RoundAmount(value decimal.Decimal, currencyID string) decimal.Decimal {
+	precision := PrecisionFor(currencyID)
+	return value.Round(precision.Places, precision.Mode)
+}
+
+// PaymentExchangeRate es la tasa de cambio de una factura en moneda extranjera:
+// 1 SourceCurrencyCode equivale a CalculationRate TargetCurrencyCode (COP)
+type PaymentExchangeRate struct {
+	SourceCurrencyCode     string          `xml:"cbc:SourceCurrencyCode"`
+	SourceCurrencyBaseRate decimal.Decimal `xml:"cbc:SourceCurrencyBaseRate"`
+	TargetCurrencyCode     string          `xml:"cbc:TargetCurrencyCode"`
+	TargetCurrencyBaseRate decimal.Decimal `xml:"cbc:TargetCurrencyBaseRate"`
+	CalculationRate        decimal.Decimal `xml:"cbc:CalculationRate"`
+	Date                   string          `xml:"cbc:Date"`
+}
+
+// NewPaymentExchangeRate crea la tasa de cambio de sourceCurrency a COP vigente en date (YYYY-MM-DD)
+func NewPaymentExchangeRate(sourceCurrency string, rate decimal.Decimal, date string) *PaymentExchangeRate {
+	return &PaymentExchangeRate{
+		SourceCurrencyCode:     sourceCurrency,
+		SourceCurrencyBaseRate: decimal.New(100, 2),
+		TargetCurrencyCode:     CurrencyCOP,
+		TargetCurrencyBaseRate: decimal.New(100, 2),
+		CalculationRate:        rate,
+		Date:                   date,
+	}
+}
+
+// Convert convierte un monto en la moneda origen a la moneda destino, redondeado según esta
+func (r PaymentExchangeRate) Convert(amount AmountType) AmountType {
+	return NewAmount(amount.Value.Mul(r.CalculationRate), r.TargetCurrencyCode)
+}
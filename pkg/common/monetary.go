@@ -1,22 +1,101 @@
 package common
 
+import "github.com/diegofxm/go-dian/pkg/decimal"
+
 // LegalMonetaryTotal representa el total monetario legal
 type LegalMonetaryTotal struct {
-	LineExtensionAmount AmountType  `xml:"cbc:LineExtensionAmount"`
-	TaxExclusiveAmount  AmountType  `xml:"cbc:TaxExclusiveAmount"`
-	TaxInclusiveAmount  AmountType  `xml:"cbc:TaxInclusiveAmount"`
-	PrepaidAmount       *AmountType `xml:"cbc:PrepaidAmount,omitempty"`
-	PayableAmount       AmountType  `xml:"cbc:PayableAmount"`
+	LineExtensionAmount   AmountType  `xml:"cbc:LineExtensionAmount"`
+	TaxExclusiveAmount    AmountType  `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusiveAmount    AmountType  `xml:"cbc:TaxInclusiveAmount"`
+	AllowanceTotalAmount  *AmountType `xml:"cbc:AllowanceTotalAmount,omitempty"`  // Suma de los descuentos a nivel de documento
+	ChargeTotalAmount     *AmountType `xml:"cbc:ChargeTotalAmount,omitempty"`     // Suma de los cargos a nivel de documento
+	PrepaidAmount         *AmountType `xml:"cbc:PrepaidAmount,omitempty"`         // Suma de los anticipos (PrepaidPayment)
+	PayableRoundingAmount *AmountType `xml:"cbc:PayableRoundingAmount,omitempty"` // Ajuste por redondeo del valor a pagar
+	PayableAmount         AmountType  `xml:"cbc:PayableAmount"`
+}
+
+// Códigos de descuento (AllowanceChargeReasonCode) de la tabla 13.3.8 del Anexo Técnico
+const (
+	DiscountTaxAssumed     = "00" // Descuento por impuesto asumido
+	DiscountBuyOneGetOne   = "01" // Pague uno lleve otro
+	DiscountContractual    = "02" // Descuentos contractuales
+	DiscountEarlyPayment   = "03" // Descuento por pronto pago
+	DiscountFreeShipping   = "04" // Envío gratis
+	DiscountInventory      = "05" // Descuentos específicos por inventarios
+	DiscountPurchaseAmount = "06" // Descuento por monto de compras
+	DiscountSeasonal       = "07" // Descuento de temporada
+	DiscountProductUpdate  = "08" // Descuento por actualización de productos / servicios
+	DiscountGeneral        = "09" // Descuento general
+	DiscountVolume         = "10" // Descuento por volumen
+	DiscountOther          = "11" // Otro descuento
+)
+
+// IsDiscountCode indica si code es un código de descuento de la tabla 13.3.8
+func IsDiscountCode(code string) bool {
+	switch code {
+	case DiscountTaxAssumed, DiscountBuyOneGetOne, DiscountContractual, DiscountEarlyPayment,
+		DiscountFreeShipping, DiscountInventory, DiscountPurchaseAmount, DiscountSeasonal,
+		DiscountProductUpdate, DiscountGeneral, DiscountVolume, DiscountOther:
+		return true
+	}
+	return false
 }
 
 // AllowanceCharge representa un descuento o cargo
 type AllowanceCharge struct {
-	ID                      string     `xml:"cbc:ID"`
-	ChargeIndicator         bool       `xml:"cbc:ChargeIndicator"`
-	AllowanceChargeReason   string     `xml:"cbc:AllowanceChargeReason,omitempty"`
-	MultiplierFactorNumeric float64    `xml:"cbc:MultiplierFactorNumeric,omitempty"`
-	Amount                  AmountType `xml:"cbc:Amount"`
-	BaseAmount              AmountType `xml:"cbc:BaseAmount,omitempty"`
+	ID                        string           `xml:"cbc:ID"`
+	ChargeIndicator           bool             `xml:"cbc:ChargeIndicator"`
+	AllowanceChargeReasonCode string           `xml:"cbc:AllowanceChargeReasonCode,omitempty"` // Solo descuentos del documento, ver constantes Discount*
+	AllowanceChargeReason     string           `xml:"cbc:AllowanceChargeReason,omitempty"`
+	MultiplierFactorNumeric   *decimal.Decimal `xml:"cbc:MultiplierFactorNumeric,omitempty"` // Porcentaje del descuento o cargo
+	Amount                    AmountType       `xml:"cbc:Amount"`
+	BaseAmount                AmountType       `xml:"cbc:BaseAmount,omitempty"`
+}
+
+// NewAllowance crea un descuento por valor fijo
+func NewAllowance(reasonCode, reason string, amount AmountType) AllowanceCharge {
+	return AllowanceCharge{AllowanceChargeReasonCode: reasonCode, AllowanceChargeReason: reason, Amount: amount}
+}
+
+// NewPercentAllowance crea un descuento porcentual; el valor se calcula con Calculate
+func NewPercentAllowance(reasonCode, reason string, percent decimal.Decimal) AllowanceCharge {
+	return AllowanceCharge{AllowanceChargeReasonCode: reasonCode, AllowanceChargeReason: reason, MultiplierFactorNumeric: &percent}
+}
+
+// NewCharge crea un cargo por valor fijo
+func NewCharge(reason string, amount AmountType) AllowanceCharge {
+	return AllowanceCharge{ChargeIndicator: true, AllowanceChargeReason: reason, Amount: amount}
+}
+
+// NewPercentCharge crea un cargo porcentual; el valor se calcula con Calculate
+func NewPercentCharge(reason string, percent decimal.Decimal) AllowanceCharge {
+	return AllowanceCharge{ChargeIndicator: true, AllowanceChargeReason: reason, MultiplierFactorNumeric: &percent}
+}
+
+// Calculate completa BaseAmount con base si no se indicó y, para descuentos o cargos
+// porcentuales, calcula Amount = BaseAmount × MultiplierFactorNumeric / 100
+func (a AllowanceCharge) Calculate(base AmountType) AllowanceCharge {
+	if a.BaseAmount.Value.IsZero() {
+		a.BaseAmount = base
+	}
+	a.BaseAmount = a.BaseAmount.Rounded()
+	if a.MultiplierFactorNumeric != nil {
+		a.Amount = NewAmount(a.BaseAmount.Value.Mul(a.MultiplierFactorNumeric.Shift(-2)), a.BaseAmount.CurrencyID)
+	} else {
+		if a.Amount.CurrencyID == "" {
+			a.Amount.CurrencyID = base.CurrencyID
+		}
+		a.Amount = a.Amount.Rounded()
+	}
+	return a
+}
+
+// SignedAmount retorna Amount negativo para descuentos y positivo para cargos
+func (a AllowanceCharge) SignedAmount() decimal.Decimal {
+	if a.ChargeIndicator {
+		return a.Amount.Value
+	}
+	return a.Amount.Value.Neg()
 }
 
 // DocumentReference representa una referencia a un documento
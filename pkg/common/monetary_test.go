@@ -0,0 +1,28 @@
+package common
+
+import "testing"
+
+func TestIsDiscountCode(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected bool
+	}{
+		{DiscountTaxAssumed, true},
+		{DiscountGeneral, true},
+		{DiscountOther, true},
+		{"", false},
+		{"0", false},
+		{"12", false},
+		{"99", false},
+		{"0A", false},
+		{"0:", false},
+		{"1/", false},
+		{"011", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsDiscountCode(tt.code); got != tt.expected {
+			t.Errorf("IsDiscountCode(%q) = %v, se esperaba %v", tt.code, got, tt.expected)
+		}
+	}
+}
@@ -1,20 +1,208 @@
 package common
 
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/diegofxm/go-dian/pkg/decimal"
+)
+
+// Códigos de tributo (TaxScheme ID) de la tabla 13.2.6.1 del Anexo Técnico
+const (
+	TaxSchemeIVA = "01" // Impuesto sobre las ventas
+	TaxSchemeIC  = "02" // Impuesto al consumo departamental
+	TaxSchemeICA = "03" // Impuesto de industria, comercio y avisos
+	TaxSchemeINC = "04" // Impuesto nacional al consumo
+
+	TaxSchemeReteIVA   = "05" // Retención sobre el IVA
+	TaxSchemeReteRenta = "06" // Retención en la fuente a título de renta (ReteFuente)
+	TaxSchemeReteICA   = "07" // Retención sobre el ICA
+
+	// Tributos de valor fijo por unidad
+	TaxSchemeINCBolsas             = "22" // Impuesto nacional al consumo de bolsas plásticas
+	TaxSchemeINCarbono             = "23" // Impuesto nacional al carbono
+	TaxSchemeINCombustibles        = "24" // Impuesto nacional a los combustibles
+	TaxSchemeSobretasaCombustibles = "25" // Sobretasa a los combustibles
+)
+
+// TaxSchemeNames son los nombres (TaxScheme Name) de los tributos
+var TaxSchemeNames = map[string]string{
+	TaxSchemeIVA: "IVA",
+	TaxSchemeIC:  "IC",
+	TaxSchemeICA: "ICA",
+	TaxSchemeINC: "INC",
+
+	TaxSchemeReteIVA:   "ReteIVA",
+	TaxSchemeReteRenta: "ReteRenta",
+	TaxSchemeReteICA:   "ReteICA",
+
+	TaxSchemeINCBolsas:             "INC Bolsas",
+	TaxSchemeINCarbono:             "INCarbono",
+	TaxSchemeINCombustibles:        "INCombustibles",
+	TaxSchemeSobretasaCombustibles: "Sobretasa Combustibles",
+}
+
+// IsWithholdingScheme indica si el tributo es una retención (05, 06 o 07),
+// que se reporta en WithholdingTaxTotal y no en TaxTotal
+func IsWithholdingScheme(id string) bool {
+	switch id {
+	case TaxSchemeReteIVA, TaxSchemeReteRenta, TaxSchemeReteICA:
+		return true
+	}
+	return false
+}
+
 // TaxTotal representa el total de impuestos
 type TaxTotal struct {
 	TaxAmount   AmountType    `xml:"cbc:TaxAmount"`
 	TaxSubtotal []TaxSubtotal `xml:"cac:TaxSubtotal"`
 }
 
-// TaxSubtotal representa un subtotal de impuesto
+// TaxSubtotal representa un subtotal de impuesto o retención. Los tributos por
+// valor fijo por unidad llevan BaseUnitMeasure y PerUnitAmount en lugar de porcentaje.
 type TaxSubtotal struct {
-	TaxableAmount AmountType  `xml:"cbc:TaxableAmount"`
-	TaxAmount     AmountType  `xml:"cbc:TaxAmount"`
-	TaxCategory   TaxCategory `xml:"cac:TaxCategory"`
+	TaxableAmount   AmountType  `xml:"cbc:TaxableAmount"`
+	TaxAmount       AmountType  `xml:"cbc:TaxAmount"`
+	BaseUnitMeasure *Quantity   `xml:"cbc:BaseUnitMeasure,omitempty"`
+	PerUnitAmount   *AmountType `xml:"cbc:PerUnitAmount,omitempty"`
+	TaxCategory     TaxCategory `xml:"cac:TaxCategory"`
+}
+
+// IsPerUnit indica si el subtotal es por valor fijo por unidad
+func (s TaxSubtotal) IsPerUnit() bool {
+	return s.PerUnitAmount != nil
+}
+
+// MarshalXML omite cbc:Percent en los subtotales por unidad, que no tienen tarifa porcentual
+func (s TaxSubtotal) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type Alias TaxSubtotal
+	if !s.IsPerUnit() {
+		return e.EncodeElement(Alias(s), start)
+	}
+	aux := struct {
+		Alias
+		TaxCategory struct {
+			TaxScheme TaxScheme `xml:"cac:TaxScheme"`
+		} `xml:"cac:TaxCategory"`
+	}{Alias: Alias(s)}
+	aux.TaxCategory.TaxScheme = s.TaxCategory.TaxScheme
+	return e.EncodeElement(aux, start)
 }
 
-// TaxCategory representa una categoría de impuesto
+// Validate verifica que TaxAmount corresponda a la tarifa del subtotal, redondeado según
+// su moneda: TaxableAmount × Percent / 100 o BaseUnitMeasure × PerUnitAmount.
+// Las exenciones requieren código y motivo, y solo aplican a tarifa 0%.
+func (s TaxSubtotal) Validate() error {
+	scheme := s.TaxCategory.TaxScheme.ID
+	var expected AmountType
+	if s.IsPerUnit() {
+		if s.BaseUnitMeasure == nil {
+			return fmt.Errorf("tributo %s: BaseUnitMeasure es requerido con PerUnitAmount", scheme)
+		}
+		if s.PerUnitAmount.Value.IsNegative() || s.BaseUnitMeasure.Value.IsNegative() {
+			return fmt.Errorf("tributo %s: el valor por unidad y la cantidad no pueden ser negativos", scheme)
+		}
+		expected = NewAmount(s.BaseUnitMeasure.Value.Mul(s.PerUnitAmount.Value), s.TaxAmount.CurrencyID)
+	} else {
+		if s.BaseUnitMeasure != nil {
+			return fmt.Errorf("tributo %s: BaseUnitMeasure requiere PerUnitAmount", scheme)
+		}
+		if s.TaxCategory.Percent.IsNegative() {
+			return fmt.Errorf("tributo %s: la tarifa no puede ser negativa", scheme)
+		}
+		expected = NewAmount(s.TaxableAmount.Value.Mul(s.TaxCategory.Percent.Shift(-2)), s.TaxAmount.CurrencyID)
+	}
+	if s.Treatment() == TaxTreatmentTaxed && (s.TaxCategory.TaxExemptionReasonCode != "" || s.TaxCategory.TaxExemptionReason != "") {
+		return fmt.Errorf("tributo %s: el motivo de exención solo aplica a tarifa 0%%", scheme)
+	}
+	if s.Treatment() == TaxTreatmentExempt && (s.TaxCategory.TaxExemptionReasonCode == "" || s.TaxCategory.TaxExemptionReason == "") {
+		return fmt.Errorf("tributo %s: la exención requiere TaxExemptionReasonCode y TaxExemptionReason", scheme)
+	}
+	if !s.TaxAmount.Rounded().Value.Equal(expected.Value) {
+		return fmt.Errorf("tributo %s: TaxAmount %s no corresponde a la tarifa (esperado %s)", scheme, s.TaxAmount, expected)
+	}
+	return nil
+}
+
+// NewPercentTaxSubtotal calcula un subtotal porcentual: TaxAmount = base × percent / 100,
+// redondeado según la moneda de base
+func NewPercentTaxSubtotal(schemeID string, base AmountType, percent decimal.Decimal) TaxSubtotal {
+	return TaxSubtotal{
+		TaxableAmount: base.Rounded(),
+		TaxAmount:     NewAmount(base.Value.Mul(percent.Shift(-2)), base.CurrencyID),
+		TaxCategory: TaxCategory{
+			Percent:   percent,
+			TaxScheme: TaxScheme{ID: schemeID, Name: TaxSchemeNames[schemeID]},
+		},
+	}
+}
+
+// NewPerUnitTaxSubtotal calcula un subtotal por unidad (ej: bolsas, combustibles):
+// TaxAmount = quantity × perUnit, donde quantity es el número de unidades gravadas.
+// base es el valor de esas unidades, que se reporta como TaxableAmount.
+func NewPerUnitTaxSubtotal(schemeID string, base AmountType, quantity Quantity, perUnit AmountType) TaxSubtotal {
+	return TaxSubtotal{
+		TaxableAmount:   base.Rounded(),
+		TaxAmount:       NewAmount(quantity.Value.Mul(perUnit.Value), perUnit.CurrencyID),
+		BaseUnitMeasure: &quantity,
+		PerUnitAmount:   &perUnit,
+		TaxCategory: TaxCategory{
+			TaxScheme: TaxScheme{ID: schemeID, Name: TaxSchemeNames[schemeID]},
+		},
+	}
+}
+
+// NewTaxTotal agrupa subtotales en un TaxTotal cuyo TaxAmount es la suma de sus TaxAmount
+func NewTaxTotal(subtotals ...TaxSubtotal) TaxTotal {
+	total := TaxTotal{TaxSubtotal: subtotals}
+	for _, subtotal := range subtotals {
+		total.TaxAmount.CurrencyID = subtotal.TaxAmount.CurrencyID
+		total.TaxAmount.Value = total.TaxAmount.Value.Add(subtotal.TaxAmount.Value)
+	}
+	return total
+}
+
+// TaxCategory representa una categoría de impuesto. Los bienes exentos se reportan
+// con tarifa 0% y el motivo de la exención
 type TaxCategory struct {
-	Percent   float64   `xml:"cbc:Percent"`
-	TaxScheme TaxScheme `xml:"cac:TaxScheme"`
+	Percent                decimal.Decimal `xml:"cbc:Percent"`
+	TaxExemptionReasonCode string          `xml:"cbc:TaxExemptionReasonCode,omitempty"`
+	TaxExemptionReason     string          `xml:"cbc:TaxExemptionReason,omitempty"`
+	TaxScheme              TaxScheme       `xml:"cac:TaxScheme"`
+}
+
+// TaxTreatment es el tratamiento de un bien o servicio frente a un tributo
+type TaxTreatment int
+
+const (
+	TaxTreatmentExcluded  TaxTreatment = iota // Excluido: no causa el tributo y no lleva subtotal
+	TaxTreatmentZeroRated                     // Gravado a tarifa 0%
+	TaxTreatmentExempt                        // Exento: tarifa 0% con motivo de exención
+	TaxTreatmentTaxed                         // Gravado a tarifa mayor que 0% o por unidad
+)
+
+// String retorna el nombre del tratamiento
+func (t TaxTreatment) String() string {
+	switch t {
+	case TaxTreatmentExcluded:
+		return "excluido"
+	case TaxTreatmentZeroRated:
+		return "tarifa 0%"
+	case TaxTreatmentExempt:
+		return "exento"
+	case TaxTreatmentTaxed:
+		return "gravado"
+	}
+	return "desconocido"
+}
+
+// Treatment retorna el tratamiento que expresa el subtotal
+func (s TaxSubtotal) Treatment() TaxTreatment {
+	switch {
+	case s.IsPerUnit() || s.TaxCategory.Percent.Sign() > 0:
+		return TaxTreatmentTaxed
+	case s.TaxCategory.TaxExemptionReasonCode != "" || s.TaxCategory.TaxExemptionReason != "":
+		return TaxTreatmentExempt
+	}
+	return TaxTreatmentZeroRated
 }
@@ -0,0 +1,290 @@
+// Package decimal implementa números decimales de punto fijo para montos y cantidades,
+// con aritmética exacta y modos de redondeo explícitos.
+package decimal
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode define cómo se redondea un valor al reducir decimales
+type RoundingMode int
+
+const (
+	RoundHalfUp   RoundingMode = iota // 0.5 se aleja de cero (redondeo comercial, el usado por DIAN)
+	RoundHalfEven                     // 0.5 va al par más cercano (redondeo bancario)
+	RoundDown                         // Trunca hacia cero
+	RoundUp                           // Se aleja de cero
+)
+
+// Decimal es un número decimal exacto: coeficiente × 10^-scale.
+// El valor cero es 0 y se puede usar sin inicializar. Los valores son inmutables.
+type Decimal struct {
+	coef  *big.Int // nil equivale a 0
+	scale int32    // número de decimales, >= 0
+}
+
+// Zero es el decimal 0
+var Zero = Decimal{}
+
+// New crea el decimal value × 10^-scale, ej: New(1999, 2) es 19.99
+func New(value int64, scale int32) Decimal {
+	if scale < 0 {
+		return Decimal{coef: new(big.Int).Mul(big.NewInt(value), pow10(-scale))}
+	}
+	return Decimal{coef: big.NewInt(value), scale: scale}
+}
+
+// NewFromInt crea un decimal entero
+func NewFromInt(value int64) Decimal {
+	return New(value, 0)
+}
+
+// NewFromFloat crea un decimal con la representación decimal más corta de value
+// (ej: 0.1 es exactamente 0.1). Prefiera Parse o New para valores conocidos.
+func NewFromFloat(value float64) Decimal {
+	d, err := Parse(strconv.FormatFloat(value, 'f', -1, 64))
+	if err != nil {
+		panic(fmt.Sprintf("decimal: float inválido %v", value))
+	}
+	return d
+}
+
+// Parse interpreta un número en notación decimal ("-1234.50"); no admite exponentes ni separadores de miles
+func Parse(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	digits := strings.TrimLeft(s, "+-")
+	if digits == "" || len(s)-len(digits) > 1 {
+		return Decimal{}, fmt.Errorf("decimal: número inválido %q", s)
+	}
+
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" {
+		return Decimal{}, fmt.Errorf("decimal: número inválido %q", s)
+	}
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return Decimal{}, fmt.Errorf("decimal: número inválido %q", s)
+		}
+	}
+
+	coef, ok := new(big.Int).SetString(intPart+fracPart, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("decimal: número inválido %q", s)
+	}
+	if strings.HasPrefix(s, "-") {
+		coef.Neg(coef)
+	}
+	return Decimal{coef: coef, scale: int32(len(fracPart))}, nil
+}
+
+// MustParse es como Parse pero entra en pánico si s no es un número válido
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Add retorna d + other
+func (d Decimal) Add(other Decimal) Decimal {
+	a, b, scale := align(d, other)
+	return Decimal{coef: a.Add(a, b), scale: scale}
+}
+
+// Sub retorna d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	a, b, scale := align(d, other)
+	return Decimal{coef: a.Sub(a, b), scale: scale}
+}
+
+// Mul retorna d × other sin pérdida de precisión
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.int(), other.int()), scale: d.scale + other.scale}
+}
+
+// Div retorna d ÷ other redondeado a places decimales. Entra en pánico si other es cero.
+func (d Decimal) Div(other Decimal, places int32, mode RoundingMode) Decimal {
+	if other.IsZero() {
+		panic("decimal: división por cero")
+	}
+
+	// d/other × 10^places = d.coef × 10^(places - d.scale + other.scale) / other.coef
+	num := new(big.Int).Set(d.int())
+	den := new(big.Int).Set(other.int())
+	if exp := places - d.scale + other.scale; exp >= 0 {
+		num.Mul(num, pow10(exp))
+	} else {
+		den.Mul(den, pow10(-exp))
+	}
+	return Decimal{coef: quoRound(num, den, mode), scale: places}
+}
+
+// Neg retorna -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+// Abs retorna |d|
+func (d Decimal) Abs() Decimal {
+	return Decimal{coef: new(big.Int).Abs(d.int()), scale: d.scale}
+}
+
+// Shift retorna d × 10^n, ej: para convertir un porcentaje en fracción use Shift(-2)
+func (d Decimal) Shift(n int32) Decimal {
+	if scale := d.scale - n; scale >= 0 {
+		return Decimal{coef: d.int(), scale: scale}
+	}
+	return Decimal{coef: new(big.Int).Mul(d.int(), pow10(n-d.scale)), scale: 0}
+}
+
+// Round redondea d a places decimales con el modo indicado.
+// Si d tiene menos decimales, se completan con ceros (Round(2) de 5 es 5.00).
+func (d Decimal) Round(places int32, mode RoundingMode) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if d.scale <= places {
+		return Decimal{coef: new(big.Int).Mul(d.int(), pow10(places-d.scale)), scale: places}
+	}
+	return Decimal{coef: quoRound(d.int(), pow10(d.scale-places), mode), scale: places}
+}
+
+// Sign retorna -1, 0 o 1 según el signo de d
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// IsZero indica si d es 0
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// IsNegative indica si d es menor que 0
+func (d Decimal) IsNegative() bool {
+	return d.Sign() < 0
+}
+
+// Cmp compara d con other: -1 si d < other, 0 si son iguales, 1 si d > other
+func (d Decimal) Cmp(other Decimal) int {
+	a, b, _ := align(d, other)
+	return a.Cmp(b)
+}
+
+// Equal indica si d y other representan el mismo número (1.5 es igual a 1.50)
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+// Scale retorna el número de decimales de d
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Float64 retorna la aproximación float64 de d (solo para presentación)
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String retorna d en notación decimal con todos sus decimales
+func (d Decimal) String() string {
+	coef := d.int()
+	digits := new(big.Int).Abs(coef).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		point := len(digits) - int(d.scale)
+		digits = digits[:point] + "." + digits[point:]
+	}
+	if coef.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// StringFixed retorna d redondeado (mitad hacia arriba) con exactamente places decimales
+func (d Decimal) StringFixed(places int32) string {
+	return d.Round(places, RoundHalfUp).String()
+}
+
+// MarshalText implementa encoding.TextMarshaler
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implementa encoding.TextUnmarshaler
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Sum retorna la suma de values
+func Sum(values ...Decimal) Decimal {
+	total := Zero
+	for _, v := range values {
+		total = total.Add(v)
+	}
+	return total
+}
+
+func (d Decimal) int() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// align retorna copias de los coeficientes de a y b llevados a la misma escala
+func align(a, b Decimal) (*big.Int, *big.Int, int32) {
+	x := new(big.Int).Set(a.int())
+	y := new(big.Int).Set(b.int())
+	switch {
+	case a.scale > b.scale:
+		y.Mul(y, pow10(a.scale-b.scale))
+		return x, y, a.scale
+	case b.scale > a.scale:
+		x.Mul(x, pow10(b.scale-a.scale))
+		return x, y, b.scale
+	}
+	return x, y, a.scale
+}
+
+// quoRound retorna num / den redondeado según mode
+func quoRound(num, den *big.Int, mode RoundingMode) *big.Int {
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+
+	// Signo del resultado exacto y comparación del residuo con la mitad del divisor
+	sign := num.Sign() * den.Sign()
+	cmpHalf := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(new(big.Int).Abs(den))
+
+	awayFromZero := false
+	switch mode {
+	case RoundHalfUp:
+		awayFromZero = cmpHalf >= 0
+	case RoundHalfEven:
+		awayFromZero = cmpHalf > 0 || (cmpHalf == 0 && quo.Bit(0) == 1)
+	case RoundUp:
+		awayFromZero = true
+	case RoundDown:
+	}
+	if awayFromZero {
+		quo.Add(quo, big.NewInt(int64(sign)))
+	}
+	return quo
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,292 @@
+package decimal
+
+import (
+	"encoding/xml"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		scale    int32
+	}{
+		{"0", "0", 0},
+		{"123", "123", 0},
+		{"-1234.50", "-1234.50", 2},
+		{"+19.99", "19.99", 2},
+		{"  7.5 ", "7.5", 1},
+		{".5", "0.5", 1},
+		{"-.05", "-0.05", 2},
+		{"1.", "1", 0},
+		{"0.000", "0.000", 3},
+		{"-0", "0", 0},
+		{"12345678901234567890.123456789", "12345678901234567890.123456789", 9},
+	}
+
+	for _, tt := range tests {
+		d, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if d.String() != tt.expected || d.Scale() != tt.scale {
+			t.Errorf("Parse(%q) = %s (escala %d), se esperaba %s (escala %d)", tt.input, d, d.Scale(), tt.expected, tt.scale)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	inputs := []string{
+		"",
+		" ",
+		"-",
+		"+",
+		".",
+		"-.",
+		"+-1",
+		"--1",
+		"1-",
+		"1.2.3",
+		"1,000.00",
+		"1e3",
+		"1.5E-2",
+		"0x10",
+		"abc",
+		"12 34",
+		"NaN",
+		"Inf",
+	}
+
+	for _, input := range inputs {
+		if d, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) = %s, se esperaba error", input, d)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		value    string
+		places   int32
+		mode     RoundingMode
+		expected string
+	}{
+		// Mitad hacia arriba: los empates se alejan de cero
+		{"2.345", 2, RoundHalfUp, "2.35"},
+		{"2.355", 2, RoundHalfUp, "2.36"},
+		{"-2.345", 2, RoundHalfUp, "-2.35"},
+		{"2.344", 2, RoundHalfUp, "2.34"},
+		{"-2.3449", 2, RoundHalfUp, "-2.34"},
+		{"0.5", 0, RoundHalfUp, "1"},
+		{"-0.5", 0, RoundHalfUp, "-1"},
+		{"0.49", 0, RoundHalfUp, "0"},
+
+		// Mitad al par: los empates van al dígito par
+		{"2.345", 2, RoundHalfEven, "2.34"},
+		{"2.355", 2, RoundHalfEven, "2.36"},
+		{"-2.345", 2, RoundHalfEven, "-2.34"},
+		{"-2.355", 2, RoundHalfEven, "-2.36"},
+		{"2.3451", 2, RoundHalfEven, "2.35"},
+		{"0.5", 0, RoundHalfEven, "0"},
+		{"1.5", 0, RoundHalfEven, "2"},
+		{"-2.5", 0, RoundHalfEven, "-2"},
+
+		// Truncar y alejar de cero
+		{"2.349", 2, RoundDown, "2.34"},
+		{"-2.349", 2, RoundDown, "-2.34"},
+		{"2.341", 2, RoundUp, "2.35"},
+		{"-2.341", 2, RoundUp, "-2.35"},
+		{"2.340", 2, RoundUp, "2.34"},
+
+		// Menos decimales que places: se completan con ceros
+		{"5", 2, RoundHalfUp, "5.00"},
+		{"-1.5", 3, RoundHalfUp, "-1.500"},
+		{"0", 2, RoundHalfEven, "0.00"},
+
+		// places negativo equivale a 0
+		{"12.5", -1, RoundHalfUp, "13"},
+	}
+
+	for _, tt := range tests {
+		got := MustParse(tt.value).Round(tt.places, tt.mode)
+		if got.String() != tt.expected {
+			t.Errorf("Round(%s, %d, %d) = %s, se esperaba %s", tt.value, tt.places, tt.mode, got, tt.expected)
+		}
+	}
+}
+
+func TestQuoRound(t *testing.T) {
+	tests := []struct {
+		num, den int64
+		mode     RoundingMode
+		expected int64
+	}{
+		{10, 4, RoundHalfUp, 3},
+		{10, 4, RoundHalfEven, 2},
+		{14, 4, RoundHalfEven, 4},
+		{-10, 4, RoundHalfUp, -3},
+		{10, -4, RoundHalfUp, -3},
+		{-10, -4, RoundHalfUp, 3},
+		{-10, 4, RoundHalfEven, -2},
+		{7, 3, RoundHalfUp, 2},
+		{8, 3, RoundHalfUp, 3},
+		{7, 3, RoundUp, 3},
+		{-7, 3, RoundUp, -3},
+		{8, 3, RoundDown, 2},
+		{-8, 3, RoundDown, -2},
+		{9, 3, RoundUp, 3},
+		{0, 3, RoundUp, 0},
+	}
+
+	for _, tt := range tests {
+		got := quoRound(big.NewInt(tt.num), big.NewInt(tt.den), tt.mode)
+		if got.Int64() != tt.expected {
+			t.Errorf("quoRound(%d, %d, %d) = %s, se esperaba %d", tt.num, tt.den, tt.mode, got, tt.expected)
+		}
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		places   int32
+		mode     RoundingMode
+		expected string
+	}{
+		{"10", "3", 2, RoundHalfUp, "3.33"},
+		{"20", "3", 2, RoundHalfUp, "6.67"},
+		{"-20", "3", 2, RoundHalfUp, "-6.67"},
+		{"20", "-3", 2, RoundDown, "-6.66"},
+		{"1", "8", 2, RoundHalfUp, "0.13"},
+		{"1", "8", 2, RoundHalfEven, "0.12"},
+		{"119", "1.19", 2, RoundHalfUp, "100.00"},
+		{"0.001", "1000", 2, RoundUp, "0.01"},
+		{"1234.5678", "0.01", 0, RoundHalfUp, "123457"},
+		{"0", "7", 4, RoundHalfUp, "0.0000"},
+	}
+
+	for _, tt := range tests {
+		got := MustParse(tt.a).Div(MustParse(tt.b), tt.places, tt.mode)
+		if got.String() != tt.expected {
+			t.Errorf("%s ÷ %s = %s, se esperaba %s", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestDivByZero(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Div por cero no entró en pánico")
+		}
+	}()
+	NewFromInt(1).Div(MustParse("0.00"), 2, RoundHalfUp)
+}
+
+func TestArithmetic(t *testing.T) {
+	a := MustParse("19.99")
+	b := MustParse("0.011")
+
+	if got := a.Add(b).String(); got != "20.001" {
+		t.Errorf("Add = %s", got)
+	}
+	if got := b.Sub(a).String(); got != "-19.979" {
+		t.Errorf("Sub = %s", got)
+	}
+	if got := a.Mul(b).String(); got != "0.21989" {
+		t.Errorf("Mul = %s", got)
+	}
+	if got := Sum(a, b, a.Neg()).String(); got != "0.011" {
+		t.Errorf("Sum = %s", got)
+	}
+	if !MustParse("1.5").Equal(MustParse("1.50")) {
+		t.Error("1.5 debería ser igual a 1.50")
+	}
+	if MustParse("-0.01").Cmp(Zero) != -1 || Zero.Cmp(New(1, 3)) != -1 {
+		t.Error("Cmp incorrecto")
+	}
+	// Los valores son inmutables
+	if a.String() != "19.99" || b.String() != "0.011" {
+		t.Errorf("los operandos cambiaron: %s, %s", a, b)
+	}
+}
+
+func TestShift(t *testing.T) {
+	tests := []struct {
+		value    string
+		n        int32
+		expected string
+	}{
+		{"19", -2, "0.19"},
+		{"19.5", -2, "0.195"},
+		{"0.195", 2, "19.5"},
+		{"1.5", 3, "1500"},
+		{"-2.5", 1, "-25"},
+		{"7", 0, "7"},
+		{"0.5", -3, "0.0005"},
+	}
+
+	for _, tt := range tests {
+		got := MustParse(tt.value).Shift(tt.n)
+		if got.String() != tt.expected {
+			t.Errorf("Shift(%s, %d) = %s, se esperaba %s", tt.value, tt.n, got, tt.expected)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		value    Decimal
+		expected string
+	}{
+		{Zero, "0"},
+		{Decimal{}, "0"},
+		{New(1999, 2), "19.99"},
+		{New(-5, 3), "-0.005"},
+		{New(5, 1), "0.5"},
+		{New(100, 2), "1.00"},
+		{New(12, -2), "1200"},
+		{NewFromInt(-42), "-42"},
+		{NewFromFloat(0.1), "0.1"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.value.String(); got != tt.expected {
+			t.Errorf("String() = %q, se esperaba %q", got, tt.expected)
+		}
+	}
+
+	if got := MustParse("2.345").StringFixed(2); got != "2.35" {
+		t.Errorf("StringFixed(2) = %s", got)
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	type line struct {
+		XMLName xml.Name `xml:"Line"`
+		Percent Decimal  `xml:"percent,attr"`
+		Amount  Decimal  `xml:"Amount"`
+	}
+
+	original := line{Percent: MustParse("19.00"), Amount: MustParse("-1234.050")}
+	data, err := xml.Marshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `<Line percent="19.00"><Amount>-1234.050</Amount></Line>`; string(data) != expected {
+		t.Errorf("xml = %s, se esperaba %s", data, expected)
+	}
+
+	var parsed line
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Percent.String() != "19.00" || parsed.Amount.String() != "-1234.050" {
+		t.Errorf("round trip = %s, %s", parsed.Percent, parsed.Amount)
+	}
+
+	if err := xml.Unmarshal([]byte(`<Line percent="19"><Amount>1e3</Amount></Line>`), &parsed); err == nil {
+		t.Error("se aceptó un monto con exponente")
+	}
+}
@@ -4,16 +4,29 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	xmlutil "github.com/diegofxm/go-dian/internal/xml"
 	"github.com/diegofxm/go-dian/pkg/invoice"
+	"github.com/diegofxm/go-dian/pkg/packaging"
 	"github.com/diegofxm/go-dian/pkg/signature"
+	"github.com/diegofxm/go-dian/pkg/soap"
+	"github.com/diegofxm/go-dian/pkg/wssecurity"
 )
 
 // Client representa el cliente para interactuar con DIAN
 type Client struct {
-	Config      Config
+	Config   Config
+	Packager *packaging.Packager
+
+	identity atomic.Pointer[identity]
+}
+
+// identity agrupa el certificado de firma y el cliente SOAP que lo presenta en mTLS y WS-Security.
+// Se reemplazan juntos para que un envío nunca firme con un certificado y se autentique con otro.
+type identity struct {
 	certManager *signature.CertificateManager
+	soapClient  *soap.Client
 }
 
 // NewClient crea una nueva instancia del cliente DIAN
@@ -22,29 +35,116 @@ func NewClient(config Config) (*Client, error) {
 		return nil, ErrInvalidNIT
 	}
 
-	if config.Certificate.PEMPath == "" && config.Certificate.CertPEM == "" {
+	if err := config.Environment.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEnvironment, err)
+	}
+
+	if !config.Certificate.configured() {
 		return nil, ErrMissingCertificate
 	}
+	if config.VerifyResponses && config.ResponseRoots == nil {
+		return nil, ErrMissingResponseRoots
+	}
+
+	certManager, err := loadCertificate(config.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		Config:   config,
+		Packager: packaging.NewPackager(config.NIT, config.ProviderCode),
+	}
+	if config.Sequence != nil {
+		client.Packager.Sequence = config.Sequence
+	}
+
+	id, err := client.newIdentity(certManager)
+	if err != nil {
+		return nil, err
+	}
+	client.identity.Store(id)
+	return client, nil
+}
+
+// newIdentity crea el cliente SOAP que usa el certificado para mTLS y WS-Security
+func (c *Client) newIdentity(certManager *signature.CertificateManager) (*identity, error) {
+	soapClient, err := soap.NewClientWithCertificate(c.Config.Environment, certManager.TLSCertificate())
+	if err != nil {
+		return nil, fmt.Errorf("error creando cliente SOAP: %w", err)
+	}
+	soapClient.Logger = c.Config.Logger
+	if c.Config.VerifyResponses {
+		soapClient.Verifier = wssecurity.NewVerifier(c.Config.ResponseRoots)
+		if c.Config.ResponseClockSkew > 0 {
+			soapClient.Verifier.ClockSkew = c.Config.ResponseClockSkew
+		}
+	}
+
+	return &identity{certManager: certManager, soapClient: soapClient}, nil
+}
+
+// loadCertificate carga el certificado y su cadena según la configuración
+func loadCertificate(cert Certificate) (*signature.CertificateManager, error) {
+	certManager, err := loadCertificateKey(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.ChainPEM != "" {
+		if err := certManager.AddChainPEM([]byte(cert.ChainPEM)); err != nil {
+			return nil, fmt.Errorf("error cargando cadena de certificados: %w", err)
+		}
+	}
+	return certManager, nil
+}
 
-	var certManager *signature.CertificateManager
-	var err error
+// loadCertificateKey carga el certificado desde un Signer externo, PKCS#12 o PEM según la configuración
+func loadCertificateKey(cert Certificate) (*signature.CertificateManager, error) {
+	password := cert.passwordFunc()
+
+	switch {
+	case cert.Signer != nil:
+		certManager, err := signature.NewCertManagerWithSigner(cert.CertPEM, cert.Signer)
+		if err != nil {
+			return nil, fmt.Errorf("error cargando certificado: %w", err)
+		}
+		return certManager, nil
+
+	case cert.P12Path != "" || len(cert.P12Data) > 0:
+		var pass []byte
+		if password != nil {
+			var err error
+			if pass, err = password(); err != nil {
+				return nil, fmt.Errorf("error obteniendo contraseña del certificado: %w", err)
+			}
+		}
+		var certManager *signature.CertificateManager
+		var err error
+		if cert.P12Path != "" {
+			certManager, err = signature.NewCertManagerFromPKCS12File(cert.P12Path, string(pass))
+		} else {
+			certManager, err = signature.NewCertManagerFromPKCS12(cert.P12Data, string(pass))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error cargando certificado: %w", err)
+		}
+		return certManager, nil
 
-	if config.Certificate.PEMPath != "" {
-		certManager, err = signature.NewCertificateManager(config.Certificate.PEMPath)
+	case cert.PEMPath != "":
+		certManager, err := signature.NewCertificateManagerWithPassword(cert.PEMPath, password)
 		if err != nil {
 			return nil, fmt.Errorf("error cargando certificado: %w", err)
 		}
-	} else if config.Certificate.CertPEM != "" && config.Certificate.KeyPEM != "" {
-		certManager, err = signature.NewCertManagerFromPEM(config.Certificate.CertPEM, config.Certificate.KeyPEM)
+		return certManager, nil
+
+	default:
+		certManager, err := signature.NewCertManagerFromEncryptedPEM(cert.CertPEM, cert.KeyPEM, password)
 		if err != nil {
 			return nil, fmt.Errorf("error cargando certificado PEM: %w", err)
 		}
+		return certManager, nil
 	}
-
-	return &Client{
-		Config:      config,
-		certManager: certManager,
-	}, nil
 }
 
 // GenerateInvoiceXML genera el XML de Invoice con DianExtensions (sin firmar)
@@ -53,8 +153,12 @@ func (c *Client) GenerateInvoiceXML(inv *invoice.Invoice) ([]byte, error) {
 		return nil, fmt.Errorf("factura inválida: %w", err)
 	}
 
+	// ProfileExecutionID y UUID@schemeID siempre siguen al ambiente del cliente
+	inv.ProfileExecutionID = c.Config.Environment.Code
+	inv.UUID.SchemeID = c.Config.Environment.Code
+
 	// Calcular CUFE
-	cufe, err := invoice.CalculateCUFE(inv, c.Config.NIT, c.Config.TechnicalKey, string(c.Config.Environment))
+	cufe, err := invoice.CalculateCUFE(inv, c.Config.NIT, c.Config.TechnicalKey, c.Config.Environment)
 	if err != nil {
 		return nil, fmt.Errorf("error calculando CUFE: %w", err)
 	}
@@ -63,6 +167,7 @@ func (c *Client) GenerateInvoiceXML(inv *invoice.Invoice) ([]byte, error) {
 	// Generar XML usando el generador modular
 	genConfig := invoice.GeneratorConfig{
 		NIT:                  c.Config.NIT,
+		Environment:          c.Config.Environment,
 		SoftwareID:           c.Config.SoftwareID,
 		PIN:                  c.Config.PIN,
 		InvoiceAuthorization: c.Config.InvoiceAuthorization,
@@ -78,30 +183,45 @@ func (c *Client) GenerateInvoiceXML(inv *invoice.Invoice) ([]byte, error) {
 
 // CalculateCUFE calcula el Código Único de Factura Electrónica
 func (c *Client) CalculateCUFE(inv *invoice.Invoice) (string, error) {
-	return invoice.CalculateCUFE(inv, c.Config.NIT, c.Config.TechnicalKey, string(c.Config.Environment))
+	return invoice.CalculateCUFE(inv, c.Config.NIT, c.Config.TechnicalKey, c.Config.Environment)
 }
 
 // SignXML firma cualquier XML con el certificado digital
 func (c *Client) SignXML(xmlData []byte) ([]byte, error) {
-	if c.certManager == nil {
+	// Se toma el certificado vigente una sola vez: una rotación concurrente no mezcla certificados
+	id := c.identity.Load()
+	if id == nil {
 		return nil, ErrMissingCertificate
 	}
+	return signXML(id.certManager, xmlData)
+}
 
-	// Crear firma XMLDSig
-	signatureXML, err := signature.SignXMLDocument(xmlData, c.certManager.GetCertificate(), c.certManager.GetPrivateKey())
-	if err != nil {
-		return nil, fmt.Errorf("error generando firma: %w", err)
+// signXML firma el XML con XAdES-EPES usando el certificado dado
+func signXML(certManager *signature.CertificateManager, xmlData []byte) ([]byte, error) {
+	// Un certificado vencido produce rechazo de DIAN en cada documento: fallar antes de enviar
+	cert := certManager.GetCertificate()
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, fmt.Errorf("%w: válido entre %s y %s", ErrCertificateExpired,
+			cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
 	}
 
-	// Insertar firma en UBLExtensions
-	signedXML, err := xmlutil.InsertSignature(xmlData, signatureXML)
+	// Firma XAdES-EPES insertada en UBLExtensions
+	signedXML, err := signature.SignDocument(xmlData, cert, certManager.GetSigner(), signature.SignOptions{
+		Chain: certManager.GetChain(),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error insertando firma: %w", err)
+		return nil, fmt.Errorf("error generando firma: %w", err)
 	}
 
 	return signedXML, nil
 }
 
+// CertificateHealth retorna el estado del certificado de firma (vigencia, uso de clave y cadena).
+// Se recomienda consultarlo periódicamente para alertar antes del vencimiento.
+func (c *Client) CertificateHealth(opts signature.HealthOptions) *signature.HealthReport {
+	return c.identity.Load().certManager.Health(opts)
+}
+
 // ValidateNIT valida el formato de un NIT colombiano
 func ValidateNIT(nit string) error {
 	nit = strings.ReplaceAll(nit, ".", "")
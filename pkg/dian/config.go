@@ -1,5 +1,16 @@
 package dian
 
+import (
+	"crypto"
+	"crypto/x509"
+	"log/slog"
+	"time"
+
+	"github.com/diegofxm/go-dian/pkg/environment"
+	"github.com/diegofxm/go-dian/pkg/packaging"
+	"github.com/diegofxm/go-dian/pkg/signature"
+)
+
 // Config contiene la configuración del cliente
 type Config struct {
 	NIT          string
@@ -9,6 +20,20 @@ type Config struct {
 	TestSetID    string
 	TechnicalKey string // Clave técnica del software (para CUFE)
 	PIN          string // PIN del software (para SoftwareSecurityCode)
+	ProviderCode string // Código PPP del proveedor tecnológico para nombres de archivo ("000" si es software propio)
+
+	// Sequence entrega el consecutivo de nombres de archivo. Si es nil se usa una secuencia en
+	// memoria que reinicia en 1 con cada proceso y repite nombres ya enviados a DIAN;
+	// en producción use packaging.NewFileSequence o una implementación persistente propia.
+	Sequence packaging.Sequence
+
+	// VerifyResponses rechaza respuestas DIAN sin firma WS-Security válida sobre Timestamp y Body.
+	// Requiere ResponseRoots: sin raíces cualquier certificado autofirmado sería aceptado.
+	VerifyResponses   bool
+	ResponseRoots     *x509.CertPool // Raíces de confianza del certificado con que DIAN firma sus respuestas
+	ResponseClockSkew time.Duration  // Tolerancia de reloj para el Timestamp de las respuestas. Por defecto 5 minutos
+
+	Logger *slog.Logger // Opcional: registra metadatos de cada envío SOAP
 
 	// Datos de autorización DIAN (específicos por empresa)
 	InvoiceAuthorization string // Número de autorización DIAN
@@ -19,17 +44,48 @@ type Config struct {
 	AuthTo               string // Consecutivo hasta
 }
 
-// Certificate representa el certificado digital (solo PEM)
+// Certificate representa el certificado digital (PEM o PKCS#12)
 type Certificate struct {
-	PEMPath string // Ruta a certificado PEM
-	CertPEM string // Certificado PEM como string (para BD)
-	KeyPEM  string // Clave privada PEM como string (para BD)
+	PEMPath  string // Ruta a certificado PEM
+	CertPEM  string // Certificado PEM como string (para BD)
+	KeyPEM   string // Clave privada PEM como string (para BD), preferiblemente cifrada ("ENCRYPTED PRIVATE KEY")
+	ChainPEM string // Certificados intermedios de la entidad certificadora (opcional)
+
+	// Signer firma con una clave que no está disponible localmente (HSM, KMS, remotesigner).
+	// Requiere CertPEM con el certificado correspondiente; KeyPEM se ignora.
+	Signer crypto.Signer
+
+	P12Path string // Ruta a archivo .p12/.pfx entregado por la entidad certificadora
+	P12Data []byte // Contenido del .p12/.pfx (para BD)
+	// Contraseña del .p12/.pfx o de la clave PEM cifrada ("ENCRYPTED PRIVATE KEY").
+	// PasswordFunc tiene prioridad y permite obtenerla de un gestor de secretos.
+	Password     string
+	PasswordFunc signature.PasswordFunc
+}
+
+// passwordFunc retorna la fuente de contraseña configurada, o nil si no hay ninguna
+func (c Certificate) passwordFunc() signature.PasswordFunc {
+	if c.PasswordFunc != nil {
+		return c.PasswordFunc
+	}
+	if c.Password != "" {
+		return signature.StaticPassword(c.Password)
+	}
+	return nil
+}
+
+// configured indica si se configuró alguna fuente de certificado
+func (c Certificate) configured() bool {
+	return c.PEMPath != "" || c.CertPEM != "" || c.P12Path != "" || len(c.P12Data) > 0 || c.Signer != nil
 }
 
-// Environment define el ambiente de DIAN
-type Environment string
+// Environment define el ambiente de DIAN. Determina el TipoAmbiente del CUFE,
+// el ProfileExecutionID, el endpoint SOAP y la URL del código QR.
+// Use EnvironmentTest.WithServiceURL(url) para apuntar a un emulador local.
+type Environment = environment.Environment
 
-const (
-	EnvironmentProduction Environment = "production"
-	EnvironmentTest       Environment = "test"
+// Ambientes de DIAN
+var (
+	EnvironmentProduction = environment.Production
+	EnvironmentTest       = environment.Test
 )
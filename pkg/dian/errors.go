@@ -7,4 +7,8 @@ var (
 	ErrInvalidNIT         = fmt.Errorf("NIT inválido")
 	ErrMissingCertificate = fmt.Errorf("certificado no configurado")
 	ErrInvalidInvoice     = fmt.Errorf("factura inválida")
+	ErrInvalidEnvironment = fmt.Errorf("ambiente DIAN inválido")
+	ErrCertificateExpired = fmt.Errorf("certificado fuera de vigencia")
+
+	ErrMissingResponseRoots = fmt.Errorf("VerifyResponses requiere ResponseRoots")
 )
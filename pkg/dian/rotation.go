@@ -0,0 +1,123 @@
+package dian
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/diegofxm/go-dian/pkg/signature"
+)
+
+// WatchOptions configura WatchCertificate
+type WatchOptions struct {
+	Interval time.Duration   // Frecuencia de revisión del archivo (por defecto 1 minuto)
+	OnReload func(err error) // Se llama tras cada intento de recarga; err es nil si el certificado se reemplazó
+}
+
+// ReplaceCertificate reemplaza de forma atómica el certificado de firma y de mTLS.
+// Es seguro llamarlo durante firmas y envíos concurrentes: cada documento se firma
+// completo con un solo certificado. Si el nuevo certificado no carga, su clave no
+// corresponde o está fuera de vigencia, se conserva el anterior y se retorna el error.
+func (c *Client) ReplaceCertificate(cert Certificate) error {
+	if !cert.configured() {
+		return ErrMissingCertificate
+	}
+
+	certManager, err := loadCertificate(cert)
+	if err != nil {
+		return err
+	}
+	return c.replaceCertManager(certManager)
+}
+
+// ReloadCertificate vuelve a cargar el certificado configurado en Config.Certificate
+// (por ejemplo, después de renovar el .p12 en disco)
+func (c *Client) ReloadCertificate() error {
+	return c.ReplaceCertificate(c.Config.Certificate)
+}
+
+// WatchCertificate revisa periódicamente el archivo PEMPath o P12Path de Config.Certificate
+// y recarga el certificado cuando su contenido cambia, hasta que ctx se cancele.
+// Una recarga fallida (ej: archivo escrito a medias) conserva el certificado anterior.
+func (c *Client) WatchCertificate(ctx context.Context, opts WatchOptions) error {
+	path := c.Config.Certificate.P12Path
+	if path == "" {
+		path = c.Config.Certificate.PEMPath
+	}
+	if path == "" {
+		return fmt.Errorf("el certificado no se cargó desde archivo; use ReplaceCertificate")
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	last, err := fileDigest(path)
+	if err != nil {
+		return fmt.Errorf("error leyendo certificado: %w", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			digest, err := fileDigest(path)
+			if err != nil || digest == last {
+				continue
+			}
+			last = digest
+
+			err = c.ReloadCertificate()
+			if opts.OnReload != nil {
+				opts.OnReload(err)
+			}
+		}
+	}()
+	return nil
+}
+
+// replaceCertManager valida el certificado y reemplaza en un solo paso el firmante y el cliente SOAP
+func (c *Client) replaceCertManager(certManager *signature.CertificateManager) error {
+	report := certManager.Health(signature.HealthOptions{})
+	for _, check := range report.Checks {
+		if check.Status != signature.HealthError {
+			continue
+		}
+		switch check.Name {
+		case signature.CheckKeyPair:
+			return fmt.Errorf("certificado inválido: %s", check.Message)
+		case signature.CheckValidity:
+			return fmt.Errorf("%w: %s", ErrCertificateExpired, check.Message)
+		}
+	}
+
+	id, err := c.newIdentity(certManager)
+	if err != nil {
+		return err
+	}
+
+	// Los envíos en curso terminan con el cliente SOAP anterior; sus conexiones ociosas se cierran
+	if previous := c.identity.Swap(id); previous != nil {
+		previous.soapClient.HTTPClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// fileDigest retorna el hash del contenido de un archivo
+func fileDigest(path string) ([sha256.Size]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
@@ -0,0 +1,71 @@
+package dian
+
+import (
+	"fmt"
+
+	"github.com/diegofxm/go-dian/pkg/extensions"
+	"github.com/diegofxm/go-dian/pkg/invoice"
+	"github.com/diegofxm/go-dian/pkg/packaging"
+	"github.com/diegofxm/go-dian/pkg/soap"
+)
+
+// SubmitResult contiene los artefactos y la respuesta de un envío a DIAN
+type SubmitResult struct {
+	CUFE      string         // Código Único de Factura Electrónica
+	QRCode    string         // Contenido del código QR incluido en DianExtensions
+	SignedXML []byte         // XML firmado
+	FileName  string         // Nombre del XML dentro del ZIP
+	ZipName   string         // Nombre del ZIP enviado
+	Zip       []byte         // Contenido del ZIP enviado
+	Response  *soap.Response // Respuesta de DIAN
+}
+
+// Accepted indica si DIAN validó el documento
+func (r *SubmitResult) Accepted() bool {
+	return r.Response != nil && r.Response.IsValid
+}
+
+// Submit valida, calcula el CUFE, genera, firma, empaqueta y envía la factura a DIAN.
+// Un documento rechazado no es un error: consulte SubmitResult.Accepted y Response.ErrorMessages.
+// Si el envío falla, el resultado parcial (XML firmado y ZIP) se retorna junto con el error.
+func (c *Client) Submit(inv *invoice.Invoice) (*SubmitResult, error) {
+	// Firma y envío usan el mismo certificado aunque se rote durante el envío
+	id := c.identity.Load()
+	if id == nil {
+		return nil, ErrMissingCertificate
+	}
+
+	// 1. Validar, calcular CUFE y generar XML
+	xmlData, err := c.GenerateInvoiceXML(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SubmitResult{
+		CUFE:   inv.UUID.Value,
+		QRCode: extensions.GenerateQRCode(c.Config.Environment, inv.UUID.Value),
+	}
+
+	// 2. Firmar
+	result.SignedXML, err = signXML(id.certManager, xmlData)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Empaquetar con nomenclatura DIAN
+	pkg, err := c.Packager.Package(packaging.Invoice, result.SignedXML)
+	if err != nil {
+		return nil, fmt.Errorf("error empaquetando factura: %w", err)
+	}
+	result.FileName = pkg.FileNames[0]
+	result.ZipName = pkg.ZipName
+	result.Zip = pkg.Data
+
+	// 4. Enviar y parsear respuesta
+	result.Response, err = id.soapClient.SendInvoice(pkg.ZipName, pkg.Data)
+	if err != nil {
+		return result, fmt.Errorf("error enviando factura: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,391 @@
+package dian
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/diegofxm/go-dian/internal/hash"
+	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
+	"github.com/diegofxm/go-dian/pkg/diantest"
+	"github.com/diegofxm/go-dian/pkg/invoice"
+	"github.com/diegofxm/go-dian/pkg/packaging"
+	"github.com/diegofxm/go-dian/pkg/signature"
+)
+
+const (
+	testNIT          = "900123456"
+	testCustomerNIT  = "800199436"
+	testTechnicalKey = "fc8eac422eba16e22ffd8c6f94b3f40a6e38162c"
+)
+
+// newTestCertificate genera un certificado autofirmado y su clave en PEM
+func newTestCertificate(t *testing.T) (certPEM, keyPEM string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "EMISOR DE PRUEBA", Country: []string{"CO"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	return certPEM, keyPEM
+}
+
+// newTestServer inicia el emulador y un cliente configurado contra él
+func newTestServer(t *testing.T) (*diantest.Server, *Client) {
+	t.Helper()
+
+	srv := diantest.NewServer(diantest.Config{TechnicalKey: testTechnicalKey})
+	t.Cleanup(srv.Close)
+
+	certPEM, keyPEM := newTestCertificate(t)
+	client, err := NewClient(Config{
+		NIT:          testNIT,
+		Environment:  srv.Environment(),
+		TechnicalKey: testTechnicalKey,
+		Certificate:  Certificate{CertPEM: certPEM, KeyPEM: keyPEM},
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return srv, client
+}
+
+// newTestInvoice crea una factura con una línea gravada con IVA 19% y otra con INC 8%
+func newTestInvoice(t *testing.T, id string) *invoice.Invoice {
+	t.Helper()
+
+	inv := invoice.NewInvoice(id)
+	inv.IssueDate = "2025-03-10"
+	inv.IssueTime = "10:53:10-05:00"
+	inv.AccountingSupplierParty.Party.PartyTaxScheme.CompanyID = common.IDType{Value: testNIT, SchemeName: "31"}
+	inv.AccountingCustomerParty.Party.PartyTaxScheme.CompanyID = common.IDType{Value: testCustomerNIT, SchemeName: "31"}
+	inv.PaymentMeans = []common.PaymentMeans{{ID: "1", PaymentMeansCode: "10"}}
+
+	lines := []*invoice.LineBuilder{
+		invoice.NewLineBuilder("1", invoice.Item{Description: "Servicio de consultoría"}).
+			UnitPrice(decimal.NewFromInt(100000)).
+			Taxes(invoice.IVA19),
+		invoice.NewLineBuilder("2", invoice.Item{Description: "Almuerzo ejecutivo"}).
+			Quantity(decimal.NewFromInt(3), "94").
+			UnitPrice(decimal.NewFromInt(25000)).
+			Taxes(invoice.INC8),
+	}
+	for _, builder := range lines {
+		line, err := builder.Build()
+		if err != nil {
+			t.Fatalf("Build: %v", err)
+		}
+		inv.AddLine(line)
+	}
+	inv.CalculateTotals()
+	return inv
+}
+
+// rules retorna los códigos de las reglas incumplidas de una respuesta
+func rules(messages []string) string {
+	codes := make([]string, 0, len(messages))
+	for _, message := range messages {
+		code, _, _ := strings.Cut(message, ":")
+		codes = append(codes, code)
+	}
+	return strings.Join(codes, ",")
+}
+
+func TestSubmitAccepted(t *testing.T) {
+	srv, client := newTestServer(t)
+
+	inv := newTestInvoice(t, "SETP990000001")
+	result, err := client.Submit(inv)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if !result.Accepted() {
+		t.Fatalf("factura rechazada: %v", result.Response.ErrorMessages)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("peticiones recibidas = %d, se esperaba 1", len(requests))
+	}
+	if requests[0].Err != nil {
+		t.Fatalf("el emulador detectó un error: %v", requests[0].Err)
+	}
+	if requests[0].FileName != result.ZipName {
+		t.Errorf("ZIP recibido %q, enviado %q", requests[0].FileName, result.ZipName)
+	}
+	if _, ok := requests[0].Files[result.FileName]; !ok {
+		t.Errorf("el ZIP no contiene %s", result.FileName)
+	}
+
+	report, err := signature.Verify(result.SignedXML)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !report.Valid {
+		t.Errorf("firma inválida: %v", report.Errors)
+	}
+}
+
+func TestSubmitCUFERoundTrip(t *testing.T) {
+	srv, client := newTestServer(t)
+
+	inv := newTestInvoice(t, "SETP990000002")
+	result, err := client.Submit(inv)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if !result.Accepted() {
+		t.Fatalf("factura rechazada: %v", result.Response.ErrorMessages)
+	}
+
+	// 100000 × 19% = 19000.00 de IVA; 75000 × 8% = 6000.00 de INC; sin ICA
+	expected := hash.CalculateCUFE(hash.CUFEData{
+		NumFac:       "SETP990000002",
+		FecFac:       "2025-03-10",
+		HorFac:       "10:53:10-05:00",
+		ValFac:       "175000.00",
+		ValImp1:      "19000.00",
+		ValImp2:      "6000.00",
+		ValImp3:      "0.00",
+		ValTot:       "200000.00",
+		NitOFE:       testNIT,
+		NumAdq:       testCustomerNIT,
+		ClTec:        testTechnicalKey,
+		TipoAmbiente: srv.Environment().Code,
+	})
+
+	if result.CUFE != expected {
+		t.Errorf("CUFE = %s, se esperaba %s", result.CUFE, expected)
+	}
+	if got := srv.Requests()[0].CUFE; got != result.CUFE {
+		t.Errorf("CUFE recibido por DIAN = %s, enviado %s", got, result.CUFE)
+	}
+	if result.Response.CUFE != result.CUFE {
+		t.Errorf("CUFE de la respuesta = %s, enviado %s", result.Response.CUFE, result.CUFE)
+	}
+	if !bytes.Contains(result.SignedXML, []byte(">"+expected+"<")) {
+		t.Error("el XML firmado no contiene el CUFE")
+	}
+	if qr := srv.Environment().QRURL(expected); result.QRCode != qr {
+		t.Errorf("QRCode = %s, se esperaba %s", result.QRCode, qr)
+	}
+	if !bytes.Contains(result.SignedXML, []byte("documentkey="+expected)) {
+		t.Error("el QRCode del XML firmado no usa el CUFE como documentkey")
+	}
+}
+
+func TestSubmitRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		send func(t *testing.T, client *Client) ([]string, error)
+		rule string
+	}{
+		{
+			name: "firma alterada",
+			rule: "ZE02",
+			send: func(t *testing.T, client *Client) ([]string, error) {
+				signedXML := signTestInvoice(t, client, "SETP990000010")
+				// El contenido cambia después de firmar sin afectar el CUFE
+				tampered := bytes.Replace(signedXML, []byte("Almuerzo ejecutivo"), []byte("Almuerzo ejecutivX"), 1)
+				if bytes.Equal(tampered, signedXML) {
+					t.Fatal("no se alteró el XML firmado")
+				}
+				pkg, err := client.Packager.Package(packaging.Invoice, tampered)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := client.identity.Load().soapClient.SendInvoice(pkg.ZipName, pkg.Data)
+				if err != nil {
+					return nil, err
+				}
+				return resp.ErrorMessages, nil
+			},
+		},
+		{
+			name: "nombre de ZIP fuera de nomenclatura",
+			rule: "ZE01",
+			send: func(t *testing.T, client *Client) ([]string, error) {
+				signedXML := signTestInvoice(t, client, "SETP990000011")
+				pkg, err := client.Packager.Package(packaging.Invoice, signedXML)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := client.identity.Load().soapClient.SendInvoice("factura.zip", pkg.Data)
+				if err != nil {
+					return nil, err
+				}
+				return resp.ErrorMessages, nil
+			},
+		},
+		{
+			name: "CUFE duplicado",
+			rule: "90",
+			send: func(t *testing.T, client *Client) ([]string, error) {
+				first, err := client.Submit(newTestInvoice(t, "SETP990000012"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !first.Accepted() {
+					t.Fatalf("primer envío rechazado: %v", first.Response.ErrorMessages)
+				}
+				second, err := client.Submit(newTestInvoice(t, "SETP990000012"))
+				if err != nil {
+					return nil, err
+				}
+				if second.Accepted() {
+					t.Error("el segundo envío fue aceptado")
+				}
+				return second.Response.ErrorMessages, nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, client := newTestServer(t)
+
+			messages, err := tt.send(t, client)
+			if err != nil {
+				t.Fatalf("envío: %v", err)
+			}
+			if got := rules(messages); got != tt.rule {
+				t.Errorf("reglas = %q, se esperaba %q (%v)", got, tt.rule, messages)
+			}
+
+			requests := srv.Requests()
+			if last := requests[len(requests)-1]; last.Err == nil {
+				t.Error("el emulador no registró el motivo del rechazo")
+			}
+		})
+	}
+}
+
+// signTestInvoice genera y firma una factura de prueba sin enviarla
+func signTestInvoice(t *testing.T, client *Client, id string) []byte {
+	t.Helper()
+
+	xmlData, err := client.GenerateInvoiceXML(newTestInvoice(t, id))
+	if err != nil {
+		t.Fatalf("GenerateInvoiceXML: %v", err)
+	}
+	signedXML, err := client.SignXML(xmlData)
+	if err != nil {
+		t.Fatalf("SignXML: %v", err)
+	}
+	return signedXML
+}
+
+func TestSubmitVerifyResponses(t *testing.T) {
+	srv := diantest.NewServer(diantest.Config{TechnicalKey: testTechnicalKey})
+	t.Cleanup(srv.Close)
+
+	certPEM, keyPEM := newTestCertificate(t)
+	config := Config{
+		NIT:             testNIT,
+		Environment:     srv.Environment(),
+		TechnicalKey:    testTechnicalKey,
+		Certificate:     Certificate{CertPEM: certPEM, KeyPEM: keyPEM},
+		VerifyResponses: true,
+	}
+
+	if _, err := NewClient(config); !errors.Is(err, ErrMissingResponseRoots) {
+		t.Fatalf("NewClient sin ResponseRoots: error = %v, se esperaba %v", err, ErrMissingResponseRoots)
+	}
+
+	config.ResponseRoots = x509.NewCertPool()
+	config.ResponseRoots.AddCert(srv.Certificate())
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	result, err := client.Submit(newTestInvoice(t, "SETP990000020"))
+	if err != nil {
+		t.Fatalf("Submit con respuesta firmada: %v", err)
+	}
+	if !result.Accepted() {
+		t.Fatalf("factura rechazada: %v", result.Response.ErrorMessages)
+	}
+
+	srv.SetResponse(diantest.Accepted().WithoutSignature())
+	if _, err := client.Submit(newTestInvoice(t, "SETP990000021")); err == nil {
+		t.Error("Submit aceptó una respuesta sin firma")
+	}
+}
+
+// TestSubmitDuringRotation verifica que cada envío firme el documento y el header WS-Security
+// con el mismo certificado aunque se rote en paralelo
+func TestSubmitDuringRotation(t *testing.T) {
+	srv, client := newTestServer(t)
+
+	certificates := make([]Certificate, 3)
+	for i := range certificates {
+		certPEM, keyPEM := newTestCertificate(t)
+		certificates[i] = Certificate{CertPEM: certPEM, KeyPEM: keyPEM}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			if err := client.ReplaceCertificate(certificates[i%len(certificates)]); err != nil {
+				t.Errorf("ReplaceCertificate: %v", err)
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := client.Submit(newTestInvoice(t, fmt.Sprintf("SETP99000010%d", i))); err != nil {
+				t.Errorf("Submit: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	<-done
+
+	for _, req := range srv.Requests() {
+		if req.Err != nil {
+			t.Fatalf("el emulador detectó un error: %v", req.Err)
+		}
+		for name, data := range req.Files {
+			report, err := signature.Verify(data)
+			if err != nil {
+				t.Fatalf("Verify %s: %v", name, err)
+			}
+			if !report.Certificate.Equal(req.Certificate) {
+				t.Errorf("%s se firmó con %s y el header WS-Security con %s",
+					name, report.Certificate.SerialNumber, req.Certificate.SerialNumber)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package diantest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/diegofxm/go-dian/internal/hash"
+	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
+)
+
+// ublDocument contiene los campos de un documento UBL necesarios para recalcular el CUFE
+type ublDocument struct {
+	ProfileExecutionID string `xml:"ProfileExecutionID"`
+	ID                 string `xml:"ID"`
+	UUID               string `xml:"UUID"`
+	IssueDate          string `xml:"IssueDate"`
+	IssueTime          string `xml:"IssueTime"`
+	TaxTotal           []struct {
+		TaxSubtotal []struct {
+			TaxAmount string `xml:"TaxAmount"`
+			SchemeID  string `xml:"TaxCategory>TaxScheme>ID"`
+		} `xml:"TaxSubtotal"`
+	} `xml:"TaxTotal"`
+	LegalMonetaryTotal struct {
+		LineExtensionAmount string `xml:"LineExtensionAmount"`
+		PayableAmount       string `xml:"PayableAmount"`
+	} `xml:"LegalMonetaryTotal"`
+	SupplierNIT string `xml:"AccountingSupplierParty>Party>PartyTaxScheme>CompanyID"`
+	CustomerNIT string `xml:"AccountingCustomerParty>Party>PartyTaxScheme>CompanyID"`
+}
+
+// unzipContent decodifica el contentFile en base64 y extrae los XML del ZIP
+func unzipContent(contentFile string) (map[string][]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(contentFile)
+	if err != nil {
+		return nil, fmt.Errorf("contentFile no es base64 válido: %w", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("contentFile no es un ZIP válido: %w", err)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range reader.File {
+		if !strings.HasSuffix(strings.ToLower(f.Name), ".xml") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("error abriendo %s: %w", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo %s: %w", f.Name, err)
+		}
+		files[f.Name] = content
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("el ZIP no contiene documentos XML")
+	}
+
+	return files, nil
+}
+
+func parseDocument(data []byte) (*ublDocument, error) {
+	var doc ublDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("XML inválido: %w", err)
+	}
+	if doc.UUID == "" {
+		return nil, fmt.Errorf("el documento no tiene UUID")
+	}
+	return &doc, nil
+}
+
+// cufe recalcula el CUFE del documento tal como lo hace DIAN: IVA (01), INC (04) e ICA (03)
+// son la suma de los TaxSubtotal de cada tributo, 0.00 si el documento no lo incluye
+func (d *ublDocument) cufe(technicalKey, environment string) string {
+	taxes := make(map[string]decimal.Decimal)
+	for _, total := range d.TaxTotal {
+		for _, subtotal := range total.TaxSubtotal {
+			amount, err := decimal.Parse(subtotal.TaxAmount)
+			if err != nil {
+				continue
+			}
+			taxes[subtotal.SchemeID] = taxes[subtotal.SchemeID].Add(amount)
+		}
+	}
+
+	return hash.CalculateCUFE(hash.CUFEData{
+		NumFac:       d.ID,
+		FecFac:       d.IssueDate,
+		HorFac:       d.IssueTime,
+		ValFac:       d.LegalMonetaryTotal.LineExtensionAmount,
+		ValImp1:      taxes[common.TaxSchemeIVA].StringFixed(2),
+		ValImp2:      taxes[common.TaxSchemeINC].StringFixed(2),
+		ValImp3:      taxes[common.TaxSchemeICA].StringFixed(2),
+		ValTot:       d.LegalMonetaryTotal.PayableAmount,
+		NitOFE:       d.SupplierNIT,
+		NumAdq:       d.CustomerNIT,
+		ClTec:        technicalKey,
+		TipoAmbiente: environment,
+	})
+}
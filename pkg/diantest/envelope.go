@@ -0,0 +1,51 @@
+package diantest
+
+import "encoding/xml"
+
+const (
+	soapNS    = "http://www.w3.org/2003/05/soap-envelope"
+	wcfNS     = "http://wcf.dian.colombia"
+	actionURI = "http://wcf.dian.colombia/IWcfDianCustomerServices/"
+	wsuNS     = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+)
+
+// requestEnvelope representa un envelope SOAP 1.2 recibido por el emulador
+type requestEnvelope struct {
+	XMLName xml.Name      `xml:"http://www.w3.org/2003/05/soap-envelope Envelope"`
+	Header  requestHeader `xml:"http://www.w3.org/2003/05/soap-envelope Header"`
+	Body    requestBody   `xml:"http://www.w3.org/2003/05/soap-envelope Body"`
+}
+
+type requestHeader struct {
+	Action string `xml:"http://www.w3.org/2005/08/addressing Action"`
+	To     struct {
+		ID    string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Id,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"http://www.w3.org/2005/08/addressing To"`
+}
+
+type requestBody struct {
+	SendBillSync  *sendBill `xml:"http://wcf.dian.colombia SendBillSync"`
+	SendBillAsync *sendBill `xml:"http://wcf.dian.colombia SendBillAsync"`
+	GetStatus     *struct {
+		TrackID string `xml:"http://wcf.dian.colombia trackId"`
+	} `xml:"http://wcf.dian.colombia GetStatus"`
+}
+
+type sendBill struct {
+	FileName    string `xml:"http://wcf.dian.colombia fileName"`
+	ContentFile string `xml:"http://wcf.dian.colombia contentFile"`
+}
+
+// operation retorna el nombre de la operación solicitada
+func (b requestBody) operation() string {
+	switch {
+	case b.SendBillSync != nil:
+		return "SendBillSync"
+	case b.SendBillAsync != nil:
+		return "SendBillAsync"
+	case b.GetStatus != nil:
+		return "GetStatus"
+	}
+	return ""
+}
@@ -0,0 +1,149 @@
+package diantest
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Response describe la respuesta que el emulador entrega a una petición
+type Response struct {
+	StatusCode    string        // "00" procesado correctamente, "99" validaciones con errores
+	StatusMessage string        // Descripción del estado
+	Rules         []Rule        // Reglas de validación incumplidas
+	Fault         *Fault        // Si no es nil se responde con un SOAP Fault
+	Delay         time.Duration // Retardo antes de responder
+	Unsigned      bool          // Omite el header WS-Security de la respuesta
+}
+
+// Rule representa una regla de validación DIAN (ej: FAD06, FAJ43b)
+type Rule struct {
+	Code        string
+	Description string
+}
+
+// Fault representa un SOAP 1.2 Fault
+type Fault struct {
+	Code   string // s:Sender o s:Receiver
+	Reason string
+}
+
+// Accepted retorna una respuesta de documento validado por la DIAN
+func Accepted() Response {
+	return Response{
+		StatusCode:    "00",
+		StatusMessage: "Procesado Correctamente.",
+	}
+}
+
+// Rejected retorna una respuesta de documento rechazado con las reglas dadas
+func Rejected(rules ...Rule) Response {
+	return Response{
+		StatusCode:    "99",
+		StatusMessage: "Validación contiene errores en campos mandatorios.",
+		Rules:         rules,
+	}
+}
+
+// FaultResponse retorna una respuesta que produce un SOAP Fault
+func FaultResponse(code, reason string) Response {
+	return Response{
+		Fault: &Fault{Code: code, Reason: reason},
+	}
+}
+
+// WithDelay retorna una copia de la respuesta que se entrega tras el retardo dado
+func (r Response) WithDelay(d time.Duration) Response {
+	r.Delay = d
+	return r
+}
+
+// WithoutSignature retorna una copia de la respuesta sin header WS-Security
+func (r Response) WithoutSignature() Response {
+	r.Unsigned = true
+	return r
+}
+
+// reject reemplaza la respuesta por un rechazo, conservando faults y retardos configurados
+func (r Response) reject(rule Rule) Response {
+	if r.Fault != nil {
+		return r
+	}
+	rejected := Rejected(rule)
+	rejected.Delay = r.Delay
+	rejected.Unsigned = r.Unsigned
+	return rejected
+}
+
+// applicationResponse genera el ApplicationResponse UBL de la respuesta
+func (r Response) applicationResponse(cufe string) []byte {
+	now := time.Now()
+	app := applicationResponse{
+		Xmlns:        "urn:oasis:names:specification:ubl:schema:xsd:ApplicationResponse-2",
+		XmlnsCbc:     "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2",
+		XmlnsCac:     "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
+		UBLVersionID: "UBL 2.1",
+		ID:           now.Format("20060102150405"),
+		IssueDate:    now.Format("2006-01-02"),
+		IssueTime:    now.Format("15:04:05-07:00"),
+		DocumentResponse: documentResponse{
+			Response: appResponse{
+				ResponseCode: r.StatusCode,
+				Description:  r.StatusMessage,
+				Status: &appStatus{
+					StatusReasonCode: r.StatusCode,
+					StatusReason:     r.StatusMessage,
+				},
+			},
+			DocumentReference: documentReference{UUID: cufe},
+		},
+	}
+
+	for _, rule := range r.Rules {
+		app.DocumentResponse.LineResponse = append(app.DocumentResponse.LineResponse, lineResponse{
+			Response: appResponse{
+				ResponseCode: rule.Code,
+				Description:  rule.Description,
+			},
+		})
+	}
+
+	data, _ := xml.Marshal(app)
+	return append([]byte(xml.Header), data...)
+}
+
+type applicationResponse struct {
+	XMLName          xml.Name         `xml:"ApplicationResponse"`
+	Xmlns            string           `xml:"xmlns,attr"`
+	XmlnsCbc         string           `xml:"xmlns:cbc,attr"`
+	XmlnsCac         string           `xml:"xmlns:cac,attr"`
+	UBLVersionID     string           `xml:"cbc:UBLVersionID"`
+	ID               string           `xml:"cbc:ID"`
+	IssueDate        string           `xml:"cbc:IssueDate"`
+	IssueTime        string           `xml:"cbc:IssueTime"`
+	DocumentResponse documentResponse `xml:"cac:DocumentResponse"`
+}
+
+type documentResponse struct {
+	Response          appResponse       `xml:"cac:Response"`
+	DocumentReference documentReference `xml:"cac:DocumentReference"`
+	LineResponse      []lineResponse    `xml:"cac:LineResponse,omitempty"`
+}
+
+type appResponse struct {
+	ResponseCode string     `xml:"cbc:ResponseCode"`
+	Description  string     `xml:"cbc:Description"`
+	Status       *appStatus `xml:"cac:Status,omitempty"`
+}
+
+type appStatus struct {
+	StatusReasonCode string `xml:"cbc:StatusReasonCode"`
+	StatusReason     string `xml:"cbc:StatusReason"`
+}
+
+type documentReference struct {
+	UUID string `xml:"cbc:UUID"`
+}
+
+type lineResponse struct {
+	Response appResponse `xml:"cac:Response"`
+}
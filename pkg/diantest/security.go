@@ -0,0 +1,73 @@
+package diantest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/diegofxm/go-dian/pkg/wssecurity"
+)
+
+// verifySecurity valida el header WS-Security generado por wssecurity.HeaderBuilder:
+// ventana del Timestamp, digests de Timestamp y wsa:To, y SignatureValue contra el BinarySecurityToken.
+// Retorna el certificado firmante. El emulador no conoce las entidades certificadoras del emisor, por eso no valida la cadena.
+func verifySecurity(message []byte, env requestEnvelope, now time.Time, skew time.Duration) (*x509.Certificate, error) {
+	verifier := &wssecurity.Verifier{
+		ClockSkew:         skew,
+		Now:               func() time.Time { return now },
+		InsecureSkipChain: true,
+		UnsignedBody:      true,
+	}
+
+	header, err := verifier.Verify(message)
+	if err != nil {
+		return nil, err
+	}
+	if !header.Signs(env.Header.To.ID) {
+		return nil, fmt.Errorf("la firma no cubre wsa:To")
+	}
+
+	return header.Certificate, nil
+}
+
+// newResponseCertificate genera el certificado autofirmado con el que el emulador firma sus respuestas
+func newResponseCertificate() (tls.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{
+			CommonName:   "diantest",
+			Organization: []string{"DIRECCION DE IMPUESTOS Y ADUANAS NACIONALES"},
+			Country:      []string{"CO"},
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
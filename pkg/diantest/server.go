@@ -0,0 +1,411 @@
+// Package diantest provee un emulador local de WcfDianCustomerServices.svc
+// para pruebas de integración sin acceso al ambiente de habilitación.
+package diantest
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diegofxm/go-dian/pkg/environment"
+	"github.com/diegofxm/go-dian/pkg/packaging"
+	"github.com/diegofxm/go-dian/pkg/signature"
+	"github.com/diegofxm/go-dian/pkg/wssecurity"
+	"github.com/google/uuid"
+)
+
+// Config contiene la configuración del emulador
+type Config struct {
+	TechnicalKey  string                  // Clave técnica usada para recalcular el CUFE
+	Environment   environment.Environment // Ambiente emulado (TipoAmbiente del CUFE). Por defecto habilitación
+	SkipSecurity  bool                    // Omite la verificación del header WS-Security
+	SkipSignature bool                    // Omite la verificación de la firma XAdES de los documentos
+	ClockSkew     time.Duration           // Tolerancia de reloj para el Timestamp. Por defecto 5 minutos
+	Now           func() time.Time
+	Certificate   *tls.Certificate // Certificado con el que se firman las respuestas. Por defecto uno autofirmado
+}
+
+// Request registra una petición recibida por el emulador
+type Request struct {
+	Operation   string            // SendBillSync, SendBillAsync o GetStatus
+	FileName    string            // Nombre del ZIP enviado
+	TrackID     string            // trackId consultado (GetStatus)
+	Files       map[string][]byte // Archivos contenidos en el ZIP
+	CUFE        string            // CUFE declarado en el documento
+	Certificate *x509.Certificate // Certificado que firmó el header WS-Security
+	Err         error             // Error de seguridad o de paquete detectado
+}
+
+// Server es un emulador de los servicios web de DIAN basado en httptest
+type Server struct {
+	URL string
+
+	config   Config
+	signer   *wssecurity.HeaderBuilder
+	srv      *httptest.Server
+	mu       sync.Mutex
+	response Response
+	queue    []Response
+	requests []Request
+	tracks   map[string]Response
+	accepted map[string]bool // CUFE de los documentos ya validados
+}
+
+// NewServer inicia un emulador HTTP con la configuración dada
+func NewServer(config Config) *Server {
+	s := newServer(config)
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	return s
+}
+
+// NewTLSServer inicia un emulador HTTPS con un certificado autofirmado
+func NewTLSServer(config Config) *Server {
+	s := newServer(config)
+	s.srv = httptest.NewTLSServer(s)
+	s.URL = s.srv.URL
+	return s
+}
+
+func newServer(config Config) *Server {
+	if config.Environment.Code == "" {
+		config.Environment = environment.Test
+	}
+	if config.ClockSkew == 0 {
+		config.ClockSkew = 5 * time.Minute
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	if config.Certificate == nil {
+		cert, err := newResponseCertificate()
+		if err != nil {
+			panic(fmt.Sprintf("diantest: error generando certificado: %v", err))
+		}
+		config.Certificate = &cert
+	}
+
+	signer, err := wssecurity.NewHeaderBuilder(*config.Certificate)
+	if err != nil {
+		panic(fmt.Sprintf("diantest: error creando header builder: %v", err))
+	}
+
+	return &Server{
+		config:   config,
+		signer:   signer,
+		response: Accepted(),
+		tracks:   make(map[string]Response),
+		accepted: make(map[string]bool),
+	}
+}
+
+// Certificate retorna el certificado con el que el emulador firma sus respuestas
+func (s *Server) Certificate() *x509.Certificate {
+	cert, _ := x509.ParseCertificate(s.config.Certificate.Certificate[0])
+	return cert
+}
+
+// Close detiene el emulador
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Environment retorna el ambiente emulado apuntando a la URL del emulador
+func (s *Server) Environment() environment.Environment {
+	return s.config.Environment.WithServiceURL(s.URL)
+}
+
+// Client retorna un cliente HTTP que confía en el certificado del emulador
+func (s *Server) Client() *http.Client {
+	return s.srv.Client()
+}
+
+// SetResponse define la respuesta por defecto para todas las peticiones
+func (s *Server) SetResponse(r Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.response = r
+}
+
+// Enqueue agrega respuestas que se entregan, en orden, antes de la respuesta por defecto
+func (s *Server) Enqueue(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, responses...)
+}
+
+// Requests retorna las peticiones recibidas hasta el momento
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ServeHTTP implementa http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeFault(w, FaultResponse("s:Receiver", "error leyendo petición"))
+		return
+	}
+
+	var env requestEnvelope
+	if err := xml.Unmarshal(body, &env); err != nil {
+		writeFault(w, FaultResponse("s:Sender", fmt.Sprintf("envelope SOAP inválido: %v", err)))
+		return
+	}
+
+	req := Request{}
+	if !s.config.SkipSecurity {
+		req.Certificate, err = verifySecurity(body, env, s.config.Now(), s.config.ClockSkew)
+		if err != nil {
+			req.Err = err
+			req.Operation = env.Body.operation()
+			s.record(req)
+			writeFault(w, FaultResponse("s:Sender", fmt.Sprintf("InvalidSecurity: %v", err)))
+			return
+		}
+	}
+
+	resp := s.next()
+
+	switch {
+	case env.Body.SendBillSync != nil:
+		req.Operation = "SendBillSync"
+		resp = s.validatePackage(&req, env.Body.SendBillSync.FileName, env.Body.SendBillSync.ContentFile, resp)
+		s.record(req)
+		if !wait(r, resp.Delay) {
+			return
+		}
+		if resp.Fault != nil {
+			writeFault(w, resp)
+			return
+		}
+		s.writeResult(w, "SendBillSync", base64.StdEncoding.EncodeToString(resp.applicationResponse(req.CUFE)), resp)
+
+	case env.Body.SendBillAsync != nil:
+		req.Operation = "SendBillAsync"
+		resp = s.validatePackage(&req, env.Body.SendBillAsync.FileName, env.Body.SendBillAsync.ContentFile, resp)
+		zipKey := uuid.New().String()
+		s.mu.Lock()
+		s.tracks[zipKey] = resp
+		if req.CUFE != "" {
+			s.tracks[req.CUFE] = resp
+		}
+		s.mu.Unlock()
+		s.record(req)
+		if !wait(r, resp.Delay) {
+			return
+		}
+		if resp.Fault != nil {
+			writeFault(w, resp)
+			return
+		}
+		s.writeResult(w, "SendBillAsync", fmt.Sprintf("<ZipKey>%s</ZipKey>", zipKey), resp)
+
+	case env.Body.GetStatus != nil:
+		req.Operation = "GetStatus"
+		req.TrackID = env.Body.GetStatus.TrackID
+		s.mu.Lock()
+		tracked, ok := s.tracks[req.TrackID]
+		s.mu.Unlock()
+		if ok && resp.Fault == nil {
+			resp = tracked
+		} else if !ok && resp.Fault == nil {
+			resp = Response{StatusCode: "66", StatusMessage: "NSU no encontrado"}
+		}
+		s.record(req)
+		if !wait(r, resp.Delay) {
+			return
+		}
+		if resp.Fault != nil {
+			writeFault(w, resp)
+			return
+		}
+		s.writeResult(w, "GetStatus", base64.StdEncoding.EncodeToString(resp.applicationResponse(req.TrackID)), resp)
+
+	default:
+		writeFault(w, FaultResponse("s:Sender", "operación no soportada"))
+	}
+}
+
+// next toma la siguiente respuesta encolada o la respuesta por defecto
+func (s *Server) next() Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) > 0 {
+		r := s.queue[0]
+		s.queue = s.queue[1:]
+		return r
+	}
+	return s.response
+}
+
+func (s *Server) record(req Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+}
+
+// validatePackage descomprime el ZIP y rechaza el documento si el nombre del archivo no cumple
+// la nomenclatura, si el CUFE no coincide, si la firma no es válida o si ya fue validado
+func (s *Server) validatePackage(req *Request, fileName, contentFile string, resp Response) Response {
+	req.FileName = fileName
+
+	files, err := unzipContent(contentFile)
+	if err != nil {
+		req.Err = err
+		return resp.reject(Rule{Code: "ZE02", Description: err.Error()})
+	}
+	req.Files = files
+
+	zipParts, err := packaging.ParseFileName(fileName)
+	if err == nil && zipParts.Type != packaging.Zip {
+		err = fmt.Errorf("el archivo enviado no es un ZIP: %q", fileName)
+	}
+	if err != nil {
+		req.Err = err
+		return resp.reject(Rule{Code: "ZE01", Description: "Nombre del archivo no cumple la nomenclatura DIAN"})
+	}
+
+	var cufes []string
+	for name, data := range files {
+		doc, err := parseDocument(data)
+		if err != nil {
+			req.Err = fmt.Errorf("%s: %w", name, err)
+			return resp.reject(Rule{Code: "ZE02", Description: req.Err.Error()})
+		}
+		req.CUFE = doc.UUID
+
+		if err := checkFileName(name, zipParts, doc.SupplierNIT); err != nil {
+			req.Err = fmt.Errorf("%s: %w", name, err)
+			return resp.reject(Rule{Code: "ZE01", Description: "Nombre del archivo no cumple la nomenclatura DIAN"})
+		}
+
+		if doc.ProfileExecutionID != s.config.Environment.Code {
+			req.Err = fmt.Errorf("%s: ProfileExecutionID %q no corresponde al ambiente %q", name, doc.ProfileExecutionID, s.config.Environment.Code)
+			return resp.reject(Rule{Code: "FAD04", Description: "ProfileExecutionID no corresponde al ambiente"})
+		}
+
+		expected := doc.cufe(s.config.TechnicalKey, s.config.Environment.Code)
+		if expected != doc.UUID {
+			req.Err = fmt.Errorf("%s: CUFE esperado %s, recibido %s", name, expected, doc.UUID)
+			return resp.reject(Rule{Code: "FAD06", Description: "Valor del CUFE no está calculado correctamente"})
+		}
+
+		if !s.config.SkipSignature {
+			report, err := signature.Verify(data)
+			if err == nil && !report.Valid {
+				err = fmt.Errorf("%s", strings.Join(report.Errors, "; "))
+			}
+			if err != nil {
+				req.Err = fmt.Errorf("%s: firma inválida: %w", name, err)
+				return resp.reject(Rule{Code: "ZE02", Description: "Valor de la firma inválido"})
+			}
+		}
+
+		s.mu.Lock()
+		duplicate := s.accepted[doc.UUID]
+		s.mu.Unlock()
+		if duplicate {
+			req.Err = fmt.Errorf("%s: documento con CUFE %s procesado anteriormente", name, doc.UUID)
+			return resp.reject(Rule{Code: "90", Description: "Documento procesado anteriormente"})
+		}
+		cufes = append(cufes, doc.UUID)
+	}
+
+	// Solo los documentos validados cuentan como procesados: un rechazo puede corregirse y reenviarse
+	if resp.Fault == nil && resp.StatusCode == "00" {
+		s.mu.Lock()
+		for _, cufe := range cufes {
+			s.accepted[cufe] = true
+		}
+		s.mu.Unlock()
+	}
+
+	return resp
+}
+
+// checkFileName valida que el nombre del XML siga la nomenclatura DIAN y corresponda
+// al emisor y al año del ZIP que lo contiene
+func checkFileName(name string, zipParts packaging.FileNameParts, supplierNIT string) error {
+	parts, err := packaging.ParseFileName(name)
+	if err != nil {
+		return err
+	}
+	if parts.Type == packaging.Zip {
+		return fmt.Errorf("el ZIP contiene otro ZIP")
+	}
+	if parts.NIT != zipParts.NIT || parts.ProviderCode != zipParts.ProviderCode || parts.Year != zipParts.Year {
+		return fmt.Errorf("el emisor, proveedor o año no corresponden al ZIP")
+	}
+	if nit := fmt.Sprintf("%010s", supplierNIT); parts.NIT != nit {
+		return fmt.Errorf("el NIT del nombre %s no corresponde al emisor %s", parts.NIT, supplierNIT)
+	}
+	return nil
+}
+
+// wait aplica el retardo configurado; retorna false si el cliente canceló la petición
+func wait(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	select {
+	case <-time.After(d):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// writeResult escribe la respuesta SOAP, firmada con WS-Security sobre Timestamp y Body
+// salvo que la respuesta sea Unsigned
+func (s *Server) writeResult(w http.ResponseWriter, operation, result string, resp Response) {
+	body := fmt.Sprintf(`<s:Body xmlns:s="%s" xmlns:wsu="%s" wsu:Id="_1"><%sResponse xmlns="%s"><%sResult>%s</%sResult></%sResponse></s:Body>`,
+		soapNS, wsuNS, operation, wcfNS, operation, result, operation, operation)
+
+	header := ""
+	if !resp.Unsigned {
+		wsHeader, err := s.signer.Build(s.URL, wssecurity.SignedPart{ID: "_1", XML: body})
+		if err != nil {
+			writeFault(w, FaultResponse("s:Receiver", fmt.Sprintf("error firmando respuesta: %v", err)))
+			return
+		}
+		header = fmt.Sprintf("<s:Header>%s</s:Header>", wsHeader.ToXML(actionURI+operation+"Response"))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(&buf, `<s:Envelope xmlns:s="%s">%s%s</s:Envelope>`, soapNS, header, body)
+
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func writeFault(w http.ResponseWriter, r Response) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(&buf, `<s:Envelope xmlns:s="%s"><s:Body><s:Fault><s:Code><s:Value>%s</s:Value></s:Code><s:Reason><s:Text xml:lang="es-CO">`,
+		soapNS, r.Fault.Code)
+	xml.EscapeText(&buf, []byte(r.Fault.Reason))
+	buf.WriteString(`</s:Text></s:Reason></s:Fault></s:Body></s:Envelope>`)
+
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,378 @@
+package diantest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diegofxm/go-dian/pkg/packaging"
+	"github.com/diegofxm/go-dian/pkg/signature"
+	"github.com/diegofxm/go-dian/pkg/wssecurity"
+)
+
+const (
+	testNIT          = "900123456"
+	testTechnicalKey = "fc8eac422eba16e22ffd8c6f94b3f40a6e38162c"
+)
+
+// testInvoice es una factura mínima con los campos que el emulador usa para recalcular el CUFE
+const testInvoice = `<Invoice xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2" xmlns:ext="urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2" xmlns:cbc="urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2" xmlns:cac="urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2">` +
+	`<ext:UBLExtensions><ext:UBLExtension><ext:ExtensionContent></ext:ExtensionContent></ext:UBLExtension></ext:UBLExtensions>` +
+	`<cbc:ProfileExecutionID>2</cbc:ProfileExecutionID><cbc:ID>%s</cbc:ID><cbc:UUID schemeName="CUFE-SHA384">%s</cbc:UUID>` +
+	`<cbc:IssueDate>2025-03-10</cbc:IssueDate><cbc:IssueTime>10:53:10-05:00</cbc:IssueTime><cbc:Note>Factura de prueba</cbc:Note>` +
+	`<cac:AccountingSupplierParty><cac:Party><cac:PartyTaxScheme><cbc:CompanyID>` + testNIT + `</cbc:CompanyID></cac:PartyTaxScheme></cac:Party></cac:AccountingSupplierParty>` +
+	`<cac:AccountingCustomerParty><cac:Party><cac:PartyTaxScheme><cbc:CompanyID>800199436</cbc:CompanyID></cac:PartyTaxScheme></cac:Party></cac:AccountingCustomerParty>` +
+	`<cac:TaxTotal><cbc:TaxAmount currencyID="COP">19000.00</cbc:TaxAmount><cac:TaxSubtotal><cbc:TaxAmount currencyID="COP">19000.00</cbc:TaxAmount>` +
+	`<cac:TaxCategory><cac:TaxScheme><cbc:ID>01</cbc:ID></cac:TaxScheme></cac:TaxCategory></cac:TaxSubtotal></cac:TaxTotal>` +
+	`<cac:LegalMonetaryTotal><cbc:LineExtensionAmount currencyID="COP">100000.00</cbc:LineExtensionAmount><cbc:PayableAmount currencyID="COP">119000.00</cbc:PayableAmount></cac:LegalMonetaryTotal>` +
+	`</Invoice>`
+
+// newTestCertificate genera el certificado del emisor, usado para la firma XAdES y WS-Security
+func newTestCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "EMISOR DE PRUEBA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// newTestDocument retorna la factura id firmada con el CUFE calculado con technicalKey
+func newTestDocument(t *testing.T, cert tls.Certificate, id, technicalKey string) ([]byte, string) {
+	t.Helper()
+
+	doc, err := parseDocument([]byte(fmt.Sprintf(testInvoice, id, "pendiente")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cufe := doc.cufe(technicalKey, "2")
+
+	signed, err := signature.SignDocument([]byte(fmt.Sprintf(testInvoice, id, cufe)), cert.Leaf, cert.PrivateKey.(*rsa.PrivateKey), signature.SignOptions{})
+	if err != nil {
+		t.Fatalf("SignDocument: %v", err)
+	}
+	return signed, cufe
+}
+
+// call envía una operación al emulador con un header WS-Security firmado con cert y retorna la respuesta
+func call(t *testing.T, srv *Server, cert tls.Certificate, operation, body string) (int, []byte) {
+	t.Helper()
+
+	builder, err := wssecurity.NewHeaderBuilder(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := builder.Build(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := fmt.Sprintf(`<s:Envelope xmlns:s="%s" xmlns:wcf="%s"><s:Header>%s</s:Header><s:Body>%s</s:Body></s:Envelope>`,
+		soapNS, wcfNS, header.ToXML(actionURI+operation), body)
+
+	resp, err := srv.Client().Post(srv.URL, "application/soap+xml;charset=UTF-8", strings.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+// newTestPackager crea un Packager del emisor de prueba con el reloj fijo en 2025
+func newTestPackager() *packaging.Packager {
+	p := packaging.NewPackager(testNIT, "")
+	p.Now = func() time.Time { return time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC) }
+	return p
+}
+
+// postBill empaqueta el documento con la nomenclatura DIAN y lo envía con SendBillSync o SendBillAsync
+func postBill(t *testing.T, srv *Server, cert tls.Certificate, operation string, signed []byte) (int, []byte) {
+	t.Helper()
+
+	pkg, err := newTestPackager().Package(packaging.Invoice, signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return call(t, srv, cert, operation, fmt.Sprintf(`<wcf:%s><wcf:fileName>%s</wcf:fileName><wcf:contentFile>%s</wcf:contentFile></wcf:%s>`,
+		operation, pkg.ZipName, base64.StdEncoding.EncodeToString(pkg.Data), operation))
+}
+
+// testResponse es el ApplicationResponse entregado por el emulador
+type testResponse struct {
+	ResponseCode string   `xml:"DocumentResponse>Response>ResponseCode"`
+	UUID         string   `xml:"DocumentResponse>DocumentReference>UUID"`
+	Rules        []string `xml:"DocumentResponse>LineResponse>Response>ResponseCode"`
+}
+
+// verifiedResult verifica la firma WS-Security de la respuesta y retorna el contenido de <operation>Result
+// leído del Body firmado
+func verifiedResult(t *testing.T, srv *Server, message []byte, operation string) string {
+	t.Helper()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	verified, err := wssecurity.NewVerifier(roots).Verify(message)
+	if err != nil {
+		t.Fatalf("respuesta sin firma válida: %v", err)
+	}
+
+	start := bytes.Index(verified.Body, []byte("<"+operation+"Result>"))
+	end := bytes.Index(verified.Body, []byte("</"+operation+"Result>"))
+	if start < 0 || end < start {
+		t.Fatalf("el Body firmado no contiene %sResult: %s", operation, verified.Body)
+	}
+	return string(verified.Body[start+len(operation)+len("<Result>") : end])
+}
+
+// decodeApplicationResponse decodifica el ApplicationResponse en base64 de un resultado
+func decodeApplicationResponse(t *testing.T, result string) testResponse {
+	t.Helper()
+
+	data, err := base64.StdEncoding.DecodeString(result)
+	if err != nil {
+		t.Fatalf("resultado no es base64: %v", err)
+	}
+	var resp testResponse
+	if err := xml.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("ApplicationResponse inválido: %v", err)
+	}
+	return resp
+}
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	srv := NewServer(Config{TechnicalKey: testTechnicalKey})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSendBillSyncAccepted(t *testing.T) {
+	srv := newTestServer(t)
+	cert := newTestCertificate(t)
+	signed, cufe := newTestDocument(t, cert, "SETP990000001", testTechnicalKey)
+
+	status, message := postBill(t, srv, cert, "SendBillSync", signed)
+	if status != http.StatusOK {
+		t.Fatalf("HTTP %d: %s", status, message)
+	}
+	resp := decodeApplicationResponse(t, verifiedResult(t, srv, message, "SendBillSync"))
+	if resp.ResponseCode != "00" || resp.UUID != cufe || len(resp.Rules) != 0 {
+		t.Errorf("respuesta = %+v", resp)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("se registraron %d peticiones", len(requests))
+	}
+	req := requests[0]
+	if req.Err != nil {
+		t.Errorf("Err = %v", req.Err)
+	}
+	if req.Operation != "SendBillSync" || req.CUFE != cufe || req.FileName != "z09001234560002500000001.zip" {
+		t.Errorf("petición = %s %s %s", req.Operation, req.FileName, req.CUFE)
+	}
+	if !req.Certificate.Equal(cert.Leaf) {
+		t.Error("no se registró el certificado del header WS-Security")
+	}
+	if !bytes.Equal(req.Files["fv09001234560002500000001.xml"], signed) {
+		t.Errorf("archivos del ZIP = %d", len(req.Files))
+	}
+}
+
+func TestSendBillSyncRejected(t *testing.T) {
+	cert := newTestCertificate(t)
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, srv *Server) []byte
+		rules []string
+	}{
+		{
+			name: "respuesta configurada",
+			setup: func(t *testing.T, srv *Server) []byte {
+				srv.SetResponse(Rejected(Rule{Code: "FAJ43b", Description: "Nombre o razón social no informado"}))
+				signed, _ := newTestDocument(t, cert, "SETP990000001", testTechnicalKey)
+				return signed
+			},
+			rules: []string{"FAJ43b"},
+		},
+		{
+			name: "CUFE con otra clave técnica",
+			setup: func(t *testing.T, srv *Server) []byte {
+				signed, _ := newTestDocument(t, cert, "SETP990000001", "otra-clave-tecnica")
+				return signed
+			},
+			rules: []string{"FAD06"},
+		},
+		{
+			name: "firma alterada",
+			setup: func(t *testing.T, srv *Server) []byte {
+				signed, _ := newTestDocument(t, cert, "SETP990000001", testTechnicalKey)
+				// La nota no participa en el CUFE: solo la firma detecta el cambio
+				return bytes.Replace(signed, []byte("Factura de prueba"), []byte("Factura alterada"), 1)
+			},
+			rules: []string{"ZE02"},
+		},
+		{
+			name: "documento procesado anteriormente",
+			setup: func(t *testing.T, srv *Server) []byte {
+				signed, _ := newTestDocument(t, cert, "SETP990000001", testTechnicalKey)
+				if status, message := postBill(t, srv, cert, "SendBillSync", signed); status != http.StatusOK {
+					t.Fatalf("primer envío: HTTP %d: %s", status, message)
+				}
+				return signed
+			},
+			rules: []string{"90"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t)
+			signed := tt.setup(t, srv)
+
+			status, message := postBill(t, srv, cert, "SendBillSync", signed)
+			if status != http.StatusOK {
+				t.Fatalf("HTTP %d: %s", status, message)
+			}
+			resp := decodeApplicationResponse(t, verifiedResult(t, srv, message, "SendBillSync"))
+			if resp.ResponseCode != "99" || strings.Join(resp.Rules, ",") != strings.Join(tt.rules, ",") {
+				t.Errorf("respuesta = %+v, se esperaban las reglas %v", resp, tt.rules)
+			}
+		})
+	}
+}
+
+func TestSendBillSyncInvalidSecurity(t *testing.T) {
+	srv := newTestServer(t)
+	cert := newTestCertificate(t)
+	signed, _ := newTestDocument(t, cert, "SETP990000001", testTechnicalKey)
+	pkg, err := newTestPackager().Package(packaging.Invoice, signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Envelope sin header WS-Security
+	message := fmt.Sprintf(`<s:Envelope xmlns:s="%s" xmlns:wcf="%s"><s:Body><wcf:SendBillSync><wcf:fileName>%s</wcf:fileName><wcf:contentFile>%s</wcf:contentFile></wcf:SendBillSync></s:Body></s:Envelope>`,
+		soapNS, wcfNS, pkg.ZipName, base64.StdEncoding.EncodeToString(pkg.Data))
+	resp, err := srv.Client().Post(srv.URL, "application/soap+xml;charset=UTF-8", strings.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError || !strings.Contains(string(body), "InvalidSecurity") {
+		t.Errorf("HTTP %d: %s", resp.StatusCode, body)
+	}
+	if requests := srv.Requests(); len(requests) != 1 || requests[0].Err == nil || requests[0].Operation != "SendBillSync" {
+		t.Errorf("peticiones = %+v", requests)
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	srv := newTestServer(t)
+	cert := newTestCertificate(t)
+	signed, cufe := newTestDocument(t, cert, "SETP990000001", testTechnicalKey)
+
+	status, message := postBill(t, srv, cert, "SendBillAsync", signed)
+	if status != http.StatusOK {
+		t.Fatalf("HTTP %d: %s", status, message)
+	}
+	result := verifiedResult(t, srv, message, "SendBillAsync")
+	zipKey := strings.TrimSuffix(strings.TrimPrefix(result, "<ZipKey>"), "</ZipKey>")
+	if zipKey == "" || zipKey == result {
+		t.Fatalf("SendBillAsync no retornó ZipKey: %s", result)
+	}
+
+	tests := []struct {
+		name    string
+		trackID string
+		code    string
+	}{
+		{name: "por ZipKey", trackID: zipKey, code: "00"},
+		{name: "por CUFE", trackID: cufe, code: "00"},
+		{name: "trackId desconocido", trackID: "no-existe", code: "66"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, message := call(t, srv, cert, "GetStatus", fmt.Sprintf(`<wcf:GetStatus><wcf:trackId>%s</wcf:trackId></wcf:GetStatus>`, tt.trackID))
+			if status != http.StatusOK {
+				t.Fatalf("HTTP %d: %s", status, message)
+			}
+			resp := decodeApplicationResponse(t, verifiedResult(t, srv, message, "GetStatus"))
+			if resp.ResponseCode != tt.code {
+				t.Errorf("ResponseCode = %s, se esperaba %s", resp.ResponseCode, tt.code)
+			}
+		})
+	}
+
+	requests := srv.Requests()
+	if last := requests[len(requests)-1]; last.Operation != "GetStatus" || last.TrackID != "no-existe" {
+		t.Errorf("última petición = %s %s", last.Operation, last.TrackID)
+	}
+}
+
+func TestSignedResponse(t *testing.T) {
+	srv := newTestServer(t)
+	cert := newTestCertificate(t)
+	signed, _ := newTestDocument(t, cert, "SETP990000001", testTechnicalKey)
+
+	_, message := postBill(t, srv, cert, "SendBillSync", signed)
+
+	// La respuesta solo verifica contra el certificado del emulador
+	other := x509.NewCertPool()
+	other.AddCert(cert.Leaf)
+	if _, err := wssecurity.NewVerifier(other).Verify(message); err == nil {
+		t.Error("la respuesta verificó contra otro certificado")
+	}
+	verifiedResult(t, srv, message, "SendBillSync")
+
+	// WithoutSignature omite el header WS-Security
+	srv.SetResponse(Accepted().WithoutSignature())
+	signed2, _ := newTestDocument(t, cert, "SETP990000002", testTechnicalKey)
+	status, message := postBill(t, srv, cert, "SendBillSync", signed2)
+	if status != http.StatusOK {
+		t.Fatalf("HTTP %d: %s", status, message)
+	}
+	if bytes.Contains(message, []byte("Security")) {
+		t.Error("la respuesta WithoutSignature incluye header WS-Security")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	if _, err := wssecurity.NewVerifier(roots).Verify(message); err == nil {
+		t.Error("se verificó una respuesta sin firma")
+	}
+}
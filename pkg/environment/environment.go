@@ -0,0 +1,76 @@
+// Package environment define los ambientes de DIAN (producción y habilitación).
+// Un único Environment determina el TipoAmbiente del CUFE, el ProfileExecutionID,
+// el endpoint del servicio web y la URL de consulta del código QR.
+package environment
+
+import "fmt"
+
+// Environment representa un ambiente de DIAN
+type Environment struct {
+	Name       string // Nombre descriptivo ("production", "test")
+	Code       string // TipoAmbiente / ProfileExecutionID: "1" producción, "2" pruebas
+	ServiceURL string // URL de WcfDianCustomerServices.svc
+	CatalogURL string // URL base de consulta de documentos (código QR)
+}
+
+var (
+	// Production es el ambiente de producción de DIAN
+	Production = Environment{
+		Name:       "production",
+		Code:       "1",
+		ServiceURL: "https://vpfe.dian.gov.co/WcfDianCustomerServices.svc",
+		CatalogURL: "https://catalogo-vpfe.dian.gov.co/document/searchqr",
+	}
+
+	// Test es el ambiente de habilitación (pruebas) de DIAN
+	Test = Environment{
+		Name:       "test",
+		Code:       "2",
+		ServiceURL: "https://vpfe-hab.dian.gov.co/WcfDianCustomerServices.svc",
+		CatalogURL: "https://catalogo-vpfe-hab.dian.gov.co/document/searchqr",
+	}
+)
+
+// Parse retorna el ambiente correspondiente a un nombre ("production"/"test") o código ("1"/"2")
+func Parse(s string) (Environment, error) {
+	switch s {
+	case Production.Name, Production.Code:
+		return Production, nil
+	case Test.Name, Test.Code, "habilitacion", "habilitación":
+		return Test, nil
+	}
+	return Environment{}, fmt.Errorf("ambiente DIAN desconocido: %q", s)
+}
+
+// WithServiceURL retorna una copia del ambiente apuntando a otro endpoint (ej: un emulador local).
+// El código de ambiente se conserva, por lo que CUFE y ProfileExecutionID no cambian.
+func (e Environment) WithServiceURL(url string) Environment {
+	e.ServiceURL = url
+	return e
+}
+
+// IsProduction indica si el ambiente genera documentos con validez fiscal
+func (e Environment) IsProduction() bool {
+	return e.Code == Production.Code
+}
+
+// Validate verifica que el ambiente tenga un código DIAN y un endpoint
+func (e Environment) Validate() error {
+	if e.Code != Production.Code && e.Code != Test.Code {
+		return fmt.Errorf("código de ambiente inválido: %q (debe ser %q o %q)", e.Code, Production.Code, Test.Code)
+	}
+	if e.ServiceURL == "" {
+		return fmt.Errorf("el ambiente %q no tiene URL de servicio", e.Name)
+	}
+	return nil
+}
+
+// QRURL retorna la URL de consulta del documento para el código QR
+func (e Environment) QRURL(documentKey string) string {
+	return fmt.Sprintf("%s?documentkey=%s", e.CatalogURL, documentKey)
+}
+
+// String implementa fmt.Stringer
+func (e Environment) String() string {
+	return e.Name
+}
@@ -1,7 +1,10 @@
 package extensions
 
+import "github.com/diegofxm/go-dian/pkg/environment"
+
 type ExtensionBuilder struct {
 	NIT                  string
+	Environment          environment.Environment
 	SoftwareID           string
 	PIN                  string
 	InvoiceAuthorization string
@@ -12,10 +15,11 @@ type ExtensionBuilder struct {
 	AuthTo               string
 }
 
-func NewExtensionBuilder(nit, softwareID string) *ExtensionBuilder {
+func NewExtensionBuilder(env environment.Environment, nit, softwareID string) *ExtensionBuilder {
 	return &ExtensionBuilder{
-		NIT:        nit,
-		SoftwareID: softwareID,
+		NIT:         nit,
+		Environment: env,
+		SoftwareID:  softwareID,
 	}
 }
 
@@ -79,6 +83,6 @@ func (eb *ExtensionBuilder) Build(invoiceID, uuid string) *DianExtensions {
 				SchemeID:         "4",
 			},
 		},
-		QRCode: GenerateQRCode(eb.NIT, invoiceID, uuid),
+		QRCode: GenerateQRCode(eb.Environment, uuid),
 	}
 }
@@ -1,11 +1,11 @@
 package extensions
 
 import (
-	"crypto/sha256"
 	"crypto/sha512"
-	"encoding/base64"
 	"encoding/xml"
 	"fmt"
+
+	"github.com/diegofxm/go-dian/pkg/environment"
 )
 
 type DianExtensions struct {
@@ -76,9 +76,8 @@ func GenerateSoftwareSecurityCode(softwareID, pin string) string {
 	return fmt.Sprintf("%x", hash)
 }
 
-func GenerateQRCode(nit, invoiceID, uuid string) string {
-	data := fmt.Sprintf("%s%s%s", nit, invoiceID, uuid)
-	hash := sha256.Sum256([]byte(data))
-	documentKey := base64.URLEncoding.EncodeToString(hash[:])
-	return fmt.Sprintf("https://catalogo-vpfe.dian.gov.co/document/searchqr?documentkey=%s", documentKey)
+// GenerateQRCode genera la URL de consulta del documento en el catálogo del ambiente dado.
+// El documentkey del catálogo DIAN es el CUFE/CUDE del documento.
+func GenerateQRCode(env environment.Environment, cufe string) string {
+	return env.QRURL(cufe)
 }
@@ -0,0 +1,226 @@
+package invoice
+
+import (
+	"fmt"
+
+	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
+)
+
+// TaxRate es una tarifa de impuesto aplicable a una línea
+type TaxRate struct {
+	SchemeID string           // Código del tributo (common.TaxScheme*)
+	Percent  decimal.Decimal  // Tarifa porcentual
+	PerUnit  *decimal.Decimal // Valor fijo por unidad; si se indica, Percent no aplica
+	Excluded bool             // Bien o servicio excluido: no genera subtotal del tributo
+	Exempt   bool             // Bien o servicio exento: tarifa 0% con código y motivo de exención
+
+	ExemptionReasonCode string // Motivo de exención (solo tarifa 0%)
+	ExemptionReason     string
+}
+
+// ExemptRate crea una tarifa exenta (0%) del tributo con el motivo de la exención.
+// El código y la descripción del motivo son obligatorios: Build rechaza la línea sin ellos.
+func ExemptRate(schemeID, reasonCode, reason string) TaxRate {
+	return TaxRate{SchemeID: schemeID, Percent: decimal.New(0, 2), Exempt: true, ExemptionReasonCode: reasonCode, ExemptionReason: reason}
+}
+
+// PerUnitRate crea una tarifa de valor fijo por unidad facturada
+// (ej: PerUnitRate(common.TaxSchemeINCBolsas, decimal.NewFromInt(66)))
+func PerUnitRate(schemeID string, amount decimal.Decimal) TaxRate {
+	return TaxRate{SchemeID: schemeID, PerUnit: &amount}
+}
+
+// Tarifas usuales de IVA e INC
+var (
+	IVA19       = TaxRate{SchemeID: common.TaxSchemeIVA, Percent: decimal.New(1900, 2)}
+	IVA5        = TaxRate{SchemeID: common.TaxSchemeIVA, Percent: decimal.New(500, 2)}
+	IVA0        = TaxRate{SchemeID: common.TaxSchemeIVA, Percent: decimal.New(0, 2)}
+	IVAExcluded = TaxRate{SchemeID: common.TaxSchemeIVA, Excluded: true}
+	INC8        = TaxRate{SchemeID: common.TaxSchemeINC, Percent: decimal.New(800, 2)}
+	INC16       = TaxRate{SchemeID: common.TaxSchemeINC, Percent: decimal.New(1600, 2)}
+)
+
+// LineBuilder construye una InvoiceLine calculando LineExtensionAmount, descuentos,
+// cargos, impuestos y retenciones a partir de cantidad, precio unitario y tarifas
+type LineBuilder struct {
+	line         InvoiceLine
+	currency     string
+	quantity     decimal.Decimal
+	unitCode     string
+	unitPrice    decimal.Decimal
+	rates        []TaxRate
+	withholdings []TaxRate
+
+	free           bool
+	priceTypeCode  string
+	referencePrice decimal.Decimal
+}
+
+// NewLineBuilder crea un builder para la línea id con el item indicado.
+// Por defecto la cantidad es 1 unidad (94) y la moneda COP.
+func NewLineBuilder(id string, item Item) *LineBuilder {
+	return &LineBuilder{
+		line:     InvoiceLine{ID: id, Item: item},
+		currency: common.CurrencyCOP,
+		quantity: decimal.NewFromInt(1),
+		unitCode: "94",
+	}
+}
+
+// Quantity define la cantidad facturada y su unidad de medida (ej: "94" unidad, "KGM" kilogramo)
+func (b *LineBuilder) Quantity(value decimal.Decimal, unitCode string) *LineBuilder {
+	b.quantity = value
+	b.unitCode = unitCode
+	return b
+}
+
+// UnitPrice define el precio unitario antes de impuestos. Se redondea a los decimales de
+// la moneda, que son los que se emiten en PriceAmount.
+func (b *LineBuilder) UnitPrice(value decimal.Decimal) *LineBuilder {
+	b.unitPrice = value
+	return b
+}
+
+// Currency define la moneda de los montos de la línea; debe ser la moneda del documento
+func (b *LineBuilder) Currency(currencyID string) *LineBuilder {
+	b.currency = currencyID
+	return b
+}
+
+// FreeOfCharge marca la línea como gratuita (muestra u obsequio): su valor es cero y los
+// impuestos se liquidan sobre cantidad × referencePrice. priceTypeCode es PriceTypeCommercialValue,
+// PriceTypeInventoryValue o PriceTypeOther.
+func (b *LineBuilder) FreeOfCharge(priceTypeCode string, referencePrice decimal.Decimal) *LineBuilder {
+	b.free = true
+	b.priceTypeCode = priceTypeCode
+	b.referencePrice = referencePrice
+	return b
+}
+
+// Discount agrega un descuento por valor fijo, que reduce la base gravable
+func (b *LineBuilder) Discount(reason string, amount decimal.Decimal) *LineBuilder {
+	b.line.AllowanceCharge = append(b.line.AllowanceCharge, common.NewAllowance("", reason, common.AmountType{Value: amount}))
+	return b
+}
+
+// DiscountPercent agrega un descuento porcentual sobre el valor bruto de la línea
+func (b *LineBuilder) DiscountPercent(reason string, percent decimal.Decimal) *LineBuilder {
+	b.line.AllowanceCharge = append(b.line.AllowanceCharge, common.NewPercentAllowance("", reason, percent))
+	return b
+}
+
+// Charge agrega un cargo por valor fijo, que aumenta la base gravable
+func (b *LineBuilder) Charge(reason string, amount decimal.Decimal) *LineBuilder {
+	b.line.AllowanceCharge = append(b.line.AllowanceCharge, common.NewCharge(reason, common.AmountType{Value: amount}))
+	return b
+}
+
+// ChargePercent agrega un cargo porcentual sobre el valor bruto de la línea
+func (b *LineBuilder) ChargePercent(reason string, percent decimal.Decimal) *LineBuilder {
+	b.line.AllowanceCharge = append(b.line.AllowanceCharge, common.NewPercentCharge(reason, percent))
+	return b
+}
+
+// Taxes define las tarifas de impuesto de la línea (ej: IVA19, INC8, IVAExcluded, PerUnitRate(...)).
+// Los tributos por unidad se liquidan sobre la cantidad facturada.
+func (b *LineBuilder) Taxes(rates ...TaxRate) *LineBuilder {
+	b.rates = append(b.rates, rates...)
+	return b
+}
+
+// Withholding agrega una retención porcentual. ReteIVA se calcula sobre el IVA de la línea;
+// las demás retenciones sobre LineExtensionAmount.
+func (b *LineBuilder) Withholding(schemeID string, percent decimal.Decimal) *LineBuilder {
+	b.withholdings = append(b.withholdings, TaxRate{SchemeID: schemeID, Percent: percent})
+	return b
+}
+
+// Build valida los datos y retorna la línea con todos sus valores calculados
+func (b *LineBuilder) Build() (InvoiceLine, error) {
+	if b.line.ID == "" {
+		return InvoiceLine{}, fmt.Errorf("el ID de la línea es requerido")
+	}
+	if b.line.Item.Description == "" {
+		return InvoiceLine{}, fmt.Errorf("línea %s: la descripción del item es requerida", b.line.ID)
+	}
+	if b.quantity.Sign() <= 0 {
+		return InvoiceLine{}, fmt.Errorf("línea %s: la cantidad debe ser mayor que cero", b.line.ID)
+	}
+	if b.unitPrice.IsNegative() {
+		return InvoiceLine{}, fmt.Errorf("línea %s: el precio unitario no puede ser negativo", b.line.ID)
+	}
+
+	line := b.line
+	line.AllowanceCharge = append([]common.AllowanceCharge(nil), b.line.AllowanceCharge...)
+	for idx := range line.AllowanceCharge {
+		line.AllowanceCharge[idx].Amount.CurrencyID = b.currency
+	}
+	line.InvoicedQuantity = common.Quantity{Value: b.quantity, UnitCode: b.unitCode}
+	line.Price = Price{
+		PriceAmount:  common.AmountType{Value: b.unitPrice, CurrencyID: b.currency},
+		BaseQuantity: common.Quantity{Value: decimal.NewFromInt(1), UnitCode: b.unitCode},
+	}
+
+	if b.free {
+		if !b.unitPrice.IsZero() || len(line.AllowanceCharge) > 0 {
+			return InvoiceLine{}, fmt.Errorf("línea %s: la línea gratuita no admite precio, descuentos ni cargos", line.ID)
+		}
+		line.SetFreeOfCharge(b.priceTypeCode, common.AmountType{Value: b.referencePrice, CurrencyID: b.currency})
+	}
+
+	// Un TaxTotal por tributo; CalculateAmounts calcula base y valor de cada subtotal
+	seen := make(map[string]bool)
+	for _, rate := range b.rates {
+		if common.IsWithholdingScheme(rate.SchemeID) {
+			return InvoiceLine{}, fmt.Errorf("línea %s: la retención %s debe agregarse con Withholding", line.ID, rate.SchemeID)
+		}
+		if seen[rate.SchemeID] {
+			return InvoiceLine{}, fmt.Errorf("línea %s: el tributo %s tiene más de una tarifa", line.ID, rate.SchemeID)
+		}
+		seen[rate.SchemeID] = true
+		if rate.Excluded {
+			continue
+		}
+		if rate.Exempt && (rate.ExemptionReasonCode == "" || rate.ExemptionReason == "") {
+			return InvoiceLine{}, fmt.Errorf("línea %s: la exención del tributo %s requiere código y motivo", line.ID, rate.SchemeID)
+		}
+		subtotal := common.NewPercentTaxSubtotal(rate.SchemeID, common.AmountType{CurrencyID: b.currency}, rate.Percent)
+		if rate.PerUnit != nil {
+			if rate.PerUnit.IsNegative() {
+				return InvoiceLine{}, fmt.Errorf("línea %s: el valor por unidad del tributo %s no puede ser negativo", line.ID, rate.SchemeID)
+			}
+			subtotal = common.NewPerUnitTaxSubtotal(rate.SchemeID, common.AmountType{CurrencyID: b.currency}, line.InvoicedQuantity, common.AmountType{Value: *rate.PerUnit, CurrencyID: b.currency})
+		}
+		subtotal.TaxCategory.TaxExemptionReasonCode = rate.ExemptionReasonCode
+		subtotal.TaxCategory.TaxExemptionReason = rate.ExemptionReason
+		if err := subtotal.Validate(); err != nil {
+			return InvoiceLine{}, fmt.Errorf("línea %s: %w", line.ID, err)
+		}
+		line.TaxTotal = append(line.TaxTotal, common.NewTaxTotal(subtotal))
+	}
+	line.CalculateAmounts()
+	if line.LineExtensionAmount.Value.IsNegative() {
+		return InvoiceLine{}, fmt.Errorf("línea %s: los descuentos superan el valor de la línea", line.ID)
+	}
+
+	if line.IsFreeOfCharge() {
+		if err := line.validateFreeOfCharge(); err != nil {
+			return InvoiceLine{}, fmt.Errorf("línea %s: %w", line.ID, err)
+		}
+	}
+
+	taxes := TaxAmountsByScheme(line.TaxTotal)
+	for _, rate := range b.withholdings {
+		if !common.IsWithholdingScheme(rate.SchemeID) {
+			return InvoiceLine{}, fmt.Errorf("línea %s: el tributo %s no es una retención", line.ID, rate.SchemeID)
+		}
+		base := line.LineExtensionAmount
+		if rate.SchemeID == common.TaxSchemeReteIVA {
+			base = common.AmountType{Value: taxes[common.TaxSchemeIVA], CurrencyID: b.currency}
+		}
+		subtotal := common.NewPercentTaxSubtotal(rate.SchemeID, base, rate.Percent)
+		line.WithholdingTaxTotal = append(line.WithholdingTaxTotal, common.NewTaxTotal(subtotal))
+	}
+	return line, nil
+}
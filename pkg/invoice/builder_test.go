@@ -0,0 +1,74 @@
+package invoice
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
+)
+
+func TestBuildExemptionRequiresReason(t *testing.T) {
+	tests := []struct {
+		name       string
+		reasonCode string
+		reason     string
+		valid      bool
+	}{
+		{name: "con código y motivo", reasonCode: "01", reason: "Bienes exentos del artículo 477 del Estatuto Tributario", valid: true},
+		{name: "sin código ni motivo", reasonCode: "", reason: ""},
+		{name: "sin motivo", reasonCode: "01", reason: ""},
+		{name: "sin código", reasonCode: "", reason: "Bienes exentos"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := NewLineBuilder("1", Item{Description: "Leche"}).
+				UnitPrice(decimal.NewFromInt(5000)).
+				Taxes(ExemptRate(common.TaxSchemeIVA, tt.reasonCode, tt.reason)).
+				Build()
+			if !tt.valid {
+				if err == nil || !strings.Contains(err.Error(), "requiere código y motivo") {
+					t.Errorf("error = %v, se esperaba que se rechazara la exención", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			if got := line.TaxTreatment(common.TaxSchemeIVA); got != common.TaxTreatmentExempt {
+				t.Errorf("tratamiento = %s, se esperaba exento", got)
+			}
+		})
+	}
+}
+
+func TestValidateExemptionReason(t *testing.T) {
+	tests := []struct {
+		name       string
+		percent    decimal.Decimal
+		reasonCode string
+		reason     string
+		err        string
+	}{
+		{name: "exención sin motivo", percent: decimal.New(0, 2), reasonCode: "01", err: "la exención requiere TaxExemptionReasonCode y TaxExemptionReason"},
+		{name: "exención sin código", percent: decimal.New(0, 2), reason: "Bienes exentos", err: "la exención requiere TaxExemptionReasonCode y TaxExemptionReason"},
+		{name: "motivo en tarifa gravada", percent: decimal.New(1900, 2), reasonCode: "01", reason: "Bienes exentos", err: "el motivo de exención solo aplica a tarifa 0%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := newTotalsInvoice(t, "100000")
+			subtotal := common.NewPercentTaxSubtotal(common.TaxSchemeIVA, copAmount("100000"), tt.percent)
+			subtotal.TaxCategory.TaxExemptionReasonCode = tt.reasonCode
+			subtotal.TaxCategory.TaxExemptionReason = tt.reason
+			inv.InvoiceLines[0].TaxTotal = []common.TaxTotal{common.NewTaxTotal(subtotal)}
+			inv.CalculateTotals()
+
+			err := inv.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, se esperaba %q", err, tt.err)
+			}
+		})
+	}
+}
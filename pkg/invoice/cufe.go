@@ -4,33 +4,48 @@ import (
 	"fmt"
 
 	"github.com/diegofxm/go-dian/internal/hash"
+	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
+	"github.com/diegofxm/go-dian/pkg/environment"
 )
 
-func CalculateCUFE(inv *Invoice, nit string, technicalKey string, environment string) (string, error) {
-	if len(inv.TaxTotal) == 0 {
-		return "", fmt.Errorf("la factura debe tener al menos un TaxTotal")
+// CalculateCUFE calcula el CUFE usando el código de ambiente (TipoAmbiente) de env
+func CalculateCUFE(inv *Invoice, nit string, technicalKey string, env environment.Environment) (string, error) {
+	if err := env.Validate(); err != nil {
+		return "", err
+	}
+	if inv.ProfileExecutionID != "" && inv.ProfileExecutionID != env.Code {
+		return "", fmt.Errorf("ProfileExecutionID %q no corresponde al ambiente %s (%s)", inv.ProfileExecutionID, env.Name, env.Code)
 	}
 
-	invoiceNumber := inv.ID
-	issueDate := inv.IssueDate
-	issueTime := inv.IssueTime
-	amount := fmt.Sprintf("%.2f", inv.LegalMonetaryTotal.LineExtensionAmount.Value)
-	taxAmount := fmt.Sprintf("%.2f", inv.TaxTotal[0].TaxAmount.Value)
-	totalAmount := fmt.Sprintf("%.2f", inv.LegalMonetaryTotal.PayableAmount.Value)
-	customerNIT := inv.AccountingCustomerParty.Party.PartyTaxScheme.CompanyID.Value
-
-	cufe := hash.CalculateCUFE(
-		invoiceNumber,
-		issueDate,
-		issueTime,
-		amount,
-		taxAmount,
-		totalAmount,
-		nit,
-		customerNIT,
-		technicalKey,
-		environment,
-	)
+	// El Anexo Técnico exige los valores con dos decimales y punto como separador
+	taxes := TaxAmountsByScheme(inv.TaxTotal)
+	cufe := hash.CalculateCUFE(hash.CUFEData{
+		NumFac:       inv.ID,
+		FecFac:       inv.IssueDate,
+		HorFac:       inv.IssueTime,
+		ValFac:       inv.LegalMonetaryTotal.LineExtensionAmount.Value.StringFixed(2),
+		ValImp1:      taxes[common.TaxSchemeIVA].StringFixed(2),
+		ValImp2:      taxes[common.TaxSchemeINC].StringFixed(2),
+		ValImp3:      taxes[common.TaxSchemeICA].StringFixed(2),
+		ValTot:       inv.LegalMonetaryTotal.PayableAmount.Value.StringFixed(2),
+		NitOFE:       nit,
+		NumAdq:       inv.AccountingCustomerParty.Party.PartyTaxScheme.CompanyID.Value,
+		ClTec:        technicalKey,
+		TipoAmbiente: env.Code,
+	})
 
 	return cufe, nil
 }
+
+// TaxAmountsByScheme suma el TaxAmount de los subtotales agrupado por código de tributo (TaxScheme ID)
+func TaxAmountsByScheme(taxTotals []common.TaxTotal) map[string]decimal.Decimal {
+	amounts := make(map[string]decimal.Decimal)
+	for _, total := range taxTotals {
+		for _, subtotal := range total.TaxSubtotal {
+			scheme := subtotal.TaxCategory.TaxScheme.ID
+			amounts[scheme] = amounts[scheme].Add(subtotal.TaxAmount.Value)
+		}
+	}
+	return amounts
+}
@@ -0,0 +1,168 @@
+package invoice
+
+import (
+	"testing"
+
+	"github.com/diegofxm/go-dian/internal/hash"
+	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
+	"github.com/diegofxm/go-dian/pkg/environment"
+)
+
+const anexoTechnicalKey = "693ff6f2a553c3646a063436fd4dd9ded0311471"
+
+// copAmount retorna un monto en pesos
+func copAmount(value string) common.AmountType {
+	return common.AmountType{Value: decimal.MustParse(value), CurrencyID: common.CurrencyCOP}
+}
+
+// taxTotal retorna un TaxTotal con un subtotal porcentual del tributo
+func taxTotal(scheme, base, percent string) common.TaxTotal {
+	return common.NewTaxTotal(common.NewPercentTaxSubtotal(scheme, copAmount(base), decimal.MustParse(percent)))
+}
+
+// anexoInvoice retorna la factura del ejemplo de CUFE del Anexo Técnico con los impuestos indicados
+func anexoInvoice(lineExtension, payable string, taxes ...common.TaxTotal) *Invoice {
+	inv := NewInvoice("323200000129")
+	inv.IssueDate = "2019-01-16"
+	inv.IssueTime = "10:53:10-05:00"
+	inv.AccountingCustomerParty.Party.PartyTaxScheme.CompanyID = common.IDType{Value: "800199436", SchemeName: "31"}
+	inv.LegalMonetaryTotal.LineExtensionAmount = copAmount(lineExtension)
+	inv.LegalMonetaryTotal.PayableAmount = copAmount(payable)
+	inv.TaxTotal = taxes
+	return inv
+}
+
+func TestCalculateCUFE(t *testing.T) {
+	iva := taxTotal(common.TaxSchemeIVA, "1500000", "19.00")
+	inc := taxTotal(common.TaxSchemeINC, "200000", "8.00")
+	ica := taxTotal(common.TaxSchemeICA, "1500000", "0.966")
+
+	withholding := anexoInvoice("1500000.00", "1785000.00", iva)
+	withholding.WithholdingTaxTotal = []common.TaxTotal{taxTotal(common.TaxSchemeReteRenta, "1500000", "2.50")}
+
+	tests := []struct {
+		name     string
+		inv      *Invoice
+		expected hash.CUFEData
+	}{
+		{
+			name: "ejemplo del Anexo Técnico (solo IVA)",
+			inv:  anexoInvoice("1500000.00", "1785000.00", iva),
+			expected: hash.CUFEData{
+				ValFac: "1500000.00", ValImp1: "285000.00", ValImp2: "0.00", ValImp3: "0.00", ValTot: "1785000.00",
+			},
+		},
+		{
+			name: "IVA e INC",
+			inv:  anexoInvoice("1700000.00", "2001000.00", iva, inc),
+			expected: hash.CUFEData{
+				ValFac: "1700000.00", ValImp1: "285000.00", ValImp2: "16000.00", ValImp3: "0.00", ValTot: "2001000.00",
+			},
+		},
+		{
+			name: "IVA, INC e ICA",
+			inv:  anexoInvoice("1700000.00", "2015490.00", ica, inc, iva),
+			expected: hash.CUFEData{
+				ValFac: "1700000.00", ValImp1: "285000.00", ValImp2: "16000.00", ValImp3: "14490.00", ValTot: "2015490.00",
+			},
+		},
+		{
+			name: "las retenciones no hacen parte del CUFE",
+			inv:  withholding,
+			expected: hash.CUFEData{
+				ValFac: "1500000.00", ValImp1: "285000.00", ValImp2: "0.00", ValImp3: "0.00", ValTot: "1785000.00",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.expected
+			data.NumFac, data.FecFac, data.HorFac = "323200000129", "2019-01-16", "10:53:10-05:00"
+			data.NitOFE, data.NumAdq, data.ClTec, data.TipoAmbiente = "700085371", "800199436", anexoTechnicalKey, "1"
+
+			cufe, err := CalculateCUFE(tt.inv, "700085371", anexoTechnicalKey, environment.Production)
+			if err != nil {
+				t.Fatalf("CalculateCUFE: %v", err)
+			}
+			if expected := hash.CalculateCUFE(data); cufe != expected {
+				t.Errorf("CUFE = %s, se esperaba %s (%s)", cufe, expected, data)
+			}
+		})
+	}
+
+	// Valor publicado en el Anexo Técnico
+	cufe, err := CalculateCUFE(anexoInvoice("1500000.00", "1785000.00", iva), "700085371", anexoTechnicalKey, environment.Production)
+	if err != nil {
+		t.Fatalf("CalculateCUFE: %v", err)
+	}
+	if expected := "8bb918b19ba22a694f1da11c643b5e9de39adf60311cf179179e9b33381030bcd4c3c3f156c506ed5908f9276f5bd9b4"; cufe != expected {
+		t.Errorf("CUFE del Anexo Técnico = %s, se esperaba %s", cufe, expected)
+	}
+}
+
+func TestCalculateCUFEEnvironment(t *testing.T) {
+	inv := anexoInvoice("1500000.00", "1785000.00")
+	inv.ProfileExecutionID = environment.Test.Code
+	if _, err := CalculateCUFE(inv, "700085371", anexoTechnicalKey, environment.Production); err == nil {
+		t.Error("CalculateCUFE aceptó un ProfileExecutionID de otro ambiente")
+	}
+}
+
+func TestTaxAmountsByScheme(t *testing.T) {
+	tests := []struct {
+		name     string
+		totals   []common.TaxTotal
+		expected map[string]string
+	}{
+		{
+			name:     "sin impuestos",
+			expected: map[string]string{},
+		},
+		{
+			name:     "solo IVA",
+			totals:   []common.TaxTotal{taxTotal(common.TaxSchemeIVA, "100000", "19.00")},
+			expected: map[string]string{common.TaxSchemeIVA: "19000.00"},
+		},
+		{
+			name: "IVA con dos tarifas",
+			totals: []common.TaxTotal{common.NewTaxTotal(
+				common.NewPercentTaxSubtotal(common.TaxSchemeIVA, copAmount("100000"), decimal.MustParse("19.00")),
+				common.NewPercentTaxSubtotal(common.TaxSchemeIVA, copAmount("50000"), decimal.MustParse("5.00")),
+			)},
+			expected: map[string]string{common.TaxSchemeIVA: "21500.00"},
+		},
+		{
+			name: "IVA e INC",
+			totals: []common.TaxTotal{
+				taxTotal(common.TaxSchemeIVA, "100000", "19.00"),
+				taxTotal(common.TaxSchemeINC, "75000", "8.00"),
+			},
+			expected: map[string]string{common.TaxSchemeIVA: "19000.00", common.TaxSchemeINC: "6000.00"},
+		},
+		{
+			name: "IVA, INC e ICA",
+			totals: []common.TaxTotal{
+				taxTotal(common.TaxSchemeIVA, "100000", "19.00"),
+				taxTotal(common.TaxSchemeINC, "75000", "8.00"),
+				taxTotal(common.TaxSchemeICA, "175000", "0.966"),
+			},
+			expected: map[string]string{common.TaxSchemeIVA: "19000.00", common.TaxSchemeINC: "6000.00", common.TaxSchemeICA: "1690.50"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amounts := TaxAmountsByScheme(tt.totals)
+			if len(amounts) != len(tt.expected) {
+				t.Errorf("tributos = %v, se esperaban %v", amounts, tt.expected)
+			}
+			for scheme, expected := range tt.expected {
+				if got := amounts[scheme].StringFixed(2); got != expected {
+					t.Errorf("tributo %s = %s, se esperaba %s", scheme, got, expected)
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"fmt"
 
+	"github.com/diegofxm/go-dian/pkg/environment"
 	"github.com/diegofxm/go-dian/pkg/extensions"
 )
 
@@ -12,6 +13,12 @@ func GenerateXML(inv *Invoice, config GeneratorConfig) ([]byte, error) {
 		return nil, fmt.Errorf("factura inválida: %w", err)
 	}
 
+	if err := config.Environment.Validate(); err != nil {
+		return nil, fmt.Errorf("ambiente inválido: %w", err)
+	}
+	inv.ProfileExecutionID = config.Environment.Code
+	inv.UUID.SchemeID = config.Environment.Code
+
 	inv.UBLExtensions = buildExtensions(inv, config)
 
 	invoiceXML, err := xml.MarshalIndent(inv, "", "  ")
@@ -24,6 +31,7 @@ func GenerateXML(inv *Invoice, config GeneratorConfig) ([]byte, error) {
 
 type GeneratorConfig struct {
 	NIT                  string
+	Environment          environment.Environment
 	SoftwareID           string
 	PIN                  string
 	InvoiceAuthorization string
@@ -84,7 +92,7 @@ func buildExtensions(inv *Invoice, config GeneratorConfig) *UBLExtensions {
 				SchemeID:         "4",
 			},
 		},
-		QRCode: extensions.GenerateQRCode(config.NIT, inv.ID, inv.UUID.Value),
+		QRCode: extensions.GenerateQRCode(config.Environment, inv.UUID.Value),
 	}
 
 	dianExtXML, _ := xml.Marshal(dianExt)
@@ -0,0 +1,112 @@
+package invoice
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
+	"github.com/diegofxm/go-dian/pkg/environment"
+)
+
+// newUSDInvoice crea una factura en dólares con una línea de 250.50 USD gravada con IVA 19%
+func newUSDInvoice(t *testing.T) *Invoice {
+	t.Helper()
+
+	line, err := NewLineBuilder("1", Item{Description: "Licencia de software"}).
+		Currency("USD").
+		UnitPrice(decimal.MustParse("250.50")).
+		Taxes(IVA19).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	inv := NewInvoice("SETP990000001")
+	inv.IssueDate = "2024-05-10"
+	inv.IssueTime = "10:53:10-05:00"
+	inv.AccountingSupplierParty.Party.PartyTaxScheme.CompanyID = common.IDType{Value: "900123456", SchemeName: "31"}
+	inv.AccountingCustomerParty.Party.PartyTaxScheme.CompanyID = common.IDType{Value: "800199436", SchemeName: "31"}
+	inv.PaymentMeans = []common.PaymentMeans{{ID: "1", PaymentMeansCode: "10"}}
+	inv.SetCurrency("USD", decimal.MustParse("3950.25"), "2024-05-10")
+	inv.AddLine(line)
+	inv.CalculateTotals()
+	return inv
+}
+
+func TestGenerateXMLForeignCurrency(t *testing.T) {
+	data, err := GenerateXML(newUSDInvoice(t), GeneratorConfig{NIT: "900123456", Environment: environment.Test})
+	if err != nil {
+		t.Fatalf("GenerateXML: %v", err)
+	}
+	xml := string(data)
+
+	// Totales e impuestos en la moneda del documento, con la tasa de cambio a COP
+	for _, expected := range []string{
+		`ISO 4217 Alpha">USD</cbc:DocumentCurrencyCode>`,
+		`<cbc:SourceCurrencyCode>USD</cbc:SourceCurrencyCode>`,
+		`<cbc:TargetCurrencyCode>COP</cbc:TargetCurrencyCode>`,
+		`<cbc:CalculationRate>3950.25</cbc:CalculationRate>`,
+		`<cbc:Date>2024-05-10</cbc:Date>`,
+		`<cbc:TaxAmount currencyID="USD">47.60</cbc:TaxAmount>`,
+		`<cbc:TaxableAmount currencyID="USD">250.50</cbc:TaxableAmount>`,
+		`<cbc:LineExtensionAmount currencyID="USD">250.50</cbc:LineExtensionAmount>`,
+		`<cbc:PayableAmount currencyID="USD">298.10</cbc:PayableAmount>`,
+	} {
+		if !strings.Contains(xml, expected) {
+			t.Errorf("el XML no contiene %s", expected)
+		}
+	}
+	if strings.Contains(xml, `currencyID="COP"`) {
+		t.Error("el XML contiene montos en COP en una factura en USD")
+	}
+}
+
+func TestTaxTotalsInCOP(t *testing.T) {
+	inv := newUSDInvoice(t)
+
+	totals, err := inv.TaxTotalsInCOP()
+	if err != nil {
+		t.Fatalf("TaxTotalsInCOP: %v", err)
+	}
+	if len(totals) != 1 || len(totals[0].TaxSubtotal) != 1 {
+		t.Fatalf("TaxTotalsInCOP = %+v, se esperaba un tributo con un subtotal", totals)
+	}
+
+	// 250.50 USD × 3950.25 = 989537.625 → 989537.63; 47.60 USD × 3950.25 = 188031.90
+	subtotal := totals[0].TaxSubtotal[0]
+	for _, c := range []struct {
+		field  string
+		amount common.AmountType
+		want   string
+	}{
+		{"TaxableAmount", subtotal.TaxableAmount, "989537.63"},
+		{"TaxAmount del subtotal", subtotal.TaxAmount, "188031.90"},
+		{"TaxAmount del tributo", totals[0].TaxAmount, "188031.90"},
+	} {
+		if c.amount.CurrencyID != common.CurrencyCOP || c.amount.String() != c.want {
+			t.Errorf("%s = %s %s, se esperaba %s COP", c.field, c.amount, c.amount.CurrencyID, c.want)
+		}
+	}
+	if got := inv.TaxTotal[0].TaxAmount.String(); got != "47.60" || inv.TaxTotal[0].TaxAmount.CurrencyID != "USD" {
+		t.Errorf("TaxTotalsInCOP modificó el TaxTotal del documento: %s %s", got, inv.TaxTotal[0].TaxAmount.CurrencyID)
+	}
+
+	inv.PaymentExchangeRate = nil
+	if _, err := inv.TaxTotalsInCOP(); err == nil {
+		t.Error("TaxTotalsInCOP aceptó una factura en USD sin PaymentExchangeRate")
+	}
+}
+
+func TestTaxTotalsInCOPDomestic(t *testing.T) {
+	inv := NewInvoice("SETP990000002")
+	inv.TaxTotal = []common.TaxTotal{taxTotal(common.TaxSchemeIVA, "100000", "19.00")}
+
+	totals, err := inv.TaxTotalsInCOP()
+	if err != nil {
+		t.Fatalf("TaxTotalsInCOP: %v", err)
+	}
+	if len(totals) != 1 || totals[0].TaxAmount.String() != "19000.00" {
+		t.Errorf("TaxTotalsInCOP = %+v, se esperaba el TaxTotal sin cambios", totals)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
 )
 
 type Invoice struct {
@@ -31,19 +32,26 @@ type Invoice struct {
 	DocumentCurrencyCode DocumentCurrencyType `xml:"cbc:DocumentCurrencyCode"`
 	LineCountNumeric     int                  `xml:"cbc:LineCountNumeric"`
 
-	InvoicePeriod           *InvoicePeriod            `xml:"cac:InvoicePeriod,omitempty"`
-	BillingReference        []BillingReference        `xml:"cac:BillingReference,omitempty"`
-	AccountingSupplierParty AccountingSupplierParty   `xml:"cac:AccountingSupplierParty"`
-	AccountingCustomerParty AccountingCustomerParty   `xml:"cac:AccountingCustomerParty"`
-	TaxRepresentativeParty  *TaxRepresentativeParty   `xml:"cac:TaxRepresentativeParty,omitempty"`
-	Delivery                *Delivery                 `xml:"cac:Delivery,omitempty"`
-	DeliveryTerms           *DeliveryTerms            `xml:"cac:DeliveryTerms,omitempty"`
-	PaymentMeans            []common.PaymentMeans     `xml:"cac:PaymentMeans,omitempty"`
-	PaymentTerms            []common.PaymentTerms     `xml:"cac:PaymentTerms,omitempty"`
-	PrepaidPayment          []common.PrepaidPayment   `xml:"cac:PrepaidPayment,omitempty"`
-	TaxTotal                []common.TaxTotal         `xml:"cac:TaxTotal"`
-	LegalMonetaryTotal      common.LegalMonetaryTotal `xml:"cac:LegalMonetaryTotal"`
-	InvoiceLines            []InvoiceLine             `xml:"cac:InvoiceLine"`
+	InvoicePeriod           *InvoicePeriod              `xml:"cac:InvoicePeriod,omitempty"`
+	BillingReference        []BillingReference          `xml:"cac:BillingReference,omitempty"`
+	AccountingSupplierParty AccountingSupplierParty     `xml:"cac:AccountingSupplierParty"`
+	AccountingCustomerParty AccountingCustomerParty     `xml:"cac:AccountingCustomerParty"`
+	TaxRepresentativeParty  *TaxRepresentativeParty     `xml:"cac:TaxRepresentativeParty,omitempty"`
+	Delivery                *Delivery                   `xml:"cac:Delivery,omitempty"`
+	DeliveryTerms           *DeliveryTerms              `xml:"cac:DeliveryTerms,omitempty"`
+	PaymentMeans            []common.PaymentMeans       `xml:"cac:PaymentMeans,omitempty"`
+	PaymentTerms            []common.PaymentTerms       `xml:"cac:PaymentTerms,omitempty"`
+	PrepaidPayment          []common.PrepaidPayment     `xml:"cac:PrepaidPayment,omitempty"`
+	AllowanceCharge         []common.AllowanceCharge    `xml:"cac:AllowanceCharge,omitempty"`
+	PaymentExchangeRate     *common.PaymentExchangeRate `xml:"cac:PaymentExchangeRate,omitempty"`
+	TaxTotal                []common.TaxTotal           `xml:"cac:TaxTotal"`
+	WithholdingTaxTotal     []common.TaxTotal           `xml:"cac:WithholdingTaxTotal,omitempty"`
+	LegalMonetaryTotal      common.LegalMonetaryTotal   `xml:"cac:LegalMonetaryTotal"`
+	InvoiceLines            []InvoiceLine               `xml:"cac:InvoiceLine"`
+
+	// documentWithholdings son las retenciones agregadas con AddWithholding; CalculateTotals
+	// las suma a las de las líneas
+	documentWithholdings []common.TaxSubtotal
 }
 
 type UBLExtensions struct {
@@ -125,6 +133,10 @@ type DeliveryLocation struct {
 	ID common.IDType `xml:"cbc:ID"`
 }
 
+// Validate verifica los datos obligatorios, la moneda, los tributos y las líneas de la factura.
+// También verifica el cuadre de LegalMonetaryTotal con ValidateTotals: si los totales se
+// asignan a mano en lugar de calcularlos con CalculateTotals, deben cumplir las mismas reglas
+// de DIAN, incluidos los anticipos y el ajuste por redondeo.
 func (i *Invoice) Validate() error {
 	if i.ID == "" {
 		return fmt.Errorf("ID de factura es requerido")
@@ -141,30 +153,150 @@ func (i *Invoice) Validate() error {
 	if len(i.InvoiceLines) == 0 {
 		return fmt.Errorf("debe haber al menos una línea de factura")
 	}
+	if i.Currency() != common.CurrencyCOP {
+		if err := i.validateExchangeRate(); err != nil {
+			return err
+		}
+	}
+	if err := validateCurrency(i); err != nil {
+		return err
+	}
+	if err := i.ValidateTotals(); err != nil {
+		return fmt.Errorf("totales: %w", err)
+	}
+	if err := validateTaxSchemes(i.TaxTotal, i.WithholdingTaxTotal); err != nil {
+		return err
+	}
+	for _, ac := range i.AllowanceCharge {
+		if err := validateAllowanceCharge(ac); err != nil {
+			return err
+		}
+		if !ac.ChargeIndicator && !common.IsDiscountCode(ac.AllowanceChargeReasonCode) {
+			return fmt.Errorf("descuento %s: código de descuento %q inválido", ac.ID, ac.AllowanceChargeReasonCode)
+		}
+	}
+	for _, line := range i.InvoiceLines {
+		for _, ac := range line.AllowanceCharge {
+			if err := validateAllowanceCharge(ac); err != nil {
+				return fmt.Errorf("línea %s: %w", line.ID, err)
+			}
+		}
+		if line.IsFreeOfCharge() {
+			if err := line.validateFreeOfCharge(); err != nil {
+				return fmt.Errorf("línea %s: %w", line.ID, err)
+			}
+		}
+		if err := validateTaxSchemes(line.TaxTotal, line.WithholdingTaxTotal); err != nil {
+			return fmt.Errorf("línea %s: %w", line.ID, err)
+		}
+		for _, total := range append(append([]common.TaxTotal(nil), line.TaxTotal...), line.WithholdingTaxTotal...) {
+			if err := validateTaxTotal(total); err != nil {
+				return fmt.Errorf("línea %s: %w", line.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateAllowanceCharge verifica que un descuento o cargo tenga valor y base no negativos
+func validateAllowanceCharge(ac common.AllowanceCharge) error {
+	kind := "descuento"
+	if ac.ChargeIndicator {
+		kind = "cargo"
+	}
+	if ac.Amount.Value.IsNegative() || ac.BaseAmount.Value.IsNegative() {
+		return fmt.Errorf("%s %s: el valor y la base no pueden ser negativos", kind, ac.ID)
+	}
+	if ac.ChargeIndicator && ac.AllowanceChargeReasonCode != "" {
+		return fmt.Errorf("cargo %s: AllowanceChargeReasonCode solo aplica a descuentos", ac.ID)
+	}
+	return nil
+}
+
+// validateCurrency verifica que los montos del documento y de las líneas estén en la moneda del documento
+func validateCurrency(i *Invoice) error {
+	currency := i.Currency()
+	check := func(field string, amount common.AmountType) error {
+		if amount.CurrencyID != currency {
+			return fmt.Errorf("%s está en %q y la moneda del documento es %s", field, amount.CurrencyID, currency)
+		}
+		return nil
+	}
+
+	if err := check("PayableAmount", i.LegalMonetaryTotal.PayableAmount); err != nil {
+		return err
+	}
+	for _, total := range i.TaxTotal {
+		if err := check("TaxTotal", total.TaxAmount); err != nil {
+			return err
+		}
+	}
+	for _, payment := range i.PrepaidPayment {
+		if err := check("el anticipo "+payment.ID, payment.PaidAmount); err != nil {
+			return err
+		}
+	}
+	for _, line := range i.InvoiceLines {
+		if err := check("LineExtensionAmount de la línea "+line.ID, line.LineExtensionAmount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTaxTotal verifica cada subtotal y que TaxAmount sea la suma de los subtotales
+func validateTaxTotal(total common.TaxTotal) error {
+	sum := decimal.Zero
+	for _, subtotal := range total.TaxSubtotal {
+		if err := subtotal.Validate(); err != nil {
+			return err
+		}
+		sum = sum.Add(subtotal.TaxAmount.Value)
+	}
+	if !total.TaxAmount.Value.Equal(sum) {
+		return fmt.Errorf("TaxAmount %s no es la suma de sus subtotales (%s)", total.TaxAmount.Value, sum)
+	}
+	return nil
+}
+
+// validateTaxSchemes verifica que las retenciones (05, 06, 07) estén solo en WithholdingTaxTotal
+func validateTaxSchemes(taxTotals, withholdingTotals []common.TaxTotal) error {
+	for _, total := range taxTotals {
+		for _, subtotal := range total.TaxSubtotal {
+			if id := subtotal.TaxCategory.TaxScheme.ID; common.IsWithholdingScheme(id) {
+				return fmt.Errorf("la retención %s debe reportarse en WithholdingTaxTotal", id)
+			}
+		}
+	}
+	for _, total := range withholdingTotals {
+		for _, subtotal := range total.TaxSubtotal {
+			if id := subtotal.TaxCategory.TaxScheme.ID; !common.IsWithholdingScheme(id) {
+				return fmt.Errorf("el tributo %s no es una retención y debe reportarse en TaxTotal", id)
+			}
+		}
+	}
 	return nil
 }
 
 func NewInvoice(id string) *Invoice {
 	now := time.Now()
 	return &Invoice{
-		XmlnsCbc:           "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2",
-		XmlnsExt:           "urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2",
-		XmlnsCac:           "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
-		XmlnsSts:           "dian:gov:co:facturaelectronica:Structures-2-1",
-		UBLVersionID:       "UBL 2.1",
-		CustomizationID:    "10",
-		ProfileID:          "DIAN 2.1: Factura Electrónica de Venta",
-		ProfileExecutionID: "2",
-		ID:                 id,
+		XmlnsCbc:        "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2",
+		XmlnsExt:        "urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2",
+		XmlnsCac:        "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
+		XmlnsSts:        "dian:gov:co:facturaelectronica:Structures-2-1",
+		UBLVersionID:    "UBL 2.1",
+		CustomizationID: "10",
+		ProfileID:       "DIAN 2.1: Factura Electrónica de Venta",
+		ID:              id,
 		UUID: UUIDType{
-			SchemeID:   "2",
 			SchemeName: "CUFE-SHA384",
 		},
 		IssueDate:       now.Format("2006-01-02"),
 		IssueTime:       now.Format("15:04:05-07:00"),
 		InvoiceTypeCode: "01",
 		DocumentCurrencyCode: DocumentCurrencyType{
-			Value:          "COP",
+			Value:          common.CurrencyCOP,
 			ListAgencyID:   "6",
 			ListAgencyName: "United Nations Economic Commission for Europe",
 			ListID:         "ISO 4217 Alpha",
@@ -175,69 +307,74 @@ func NewInvoice(id string) *Invoice {
 	}
 }
 
-func (i *Invoice) AddLine(line InvoiceLine) {
-	i.InvoiceLines = append(i.InvoiceLines, line)
-	i.LineCountNumeric = len(i.InvoiceLines)
+// SetCurrency define la moneda del documento. Para monedas distintas de COP registra la
+// tasa de cambio (PaymentExchangeRate) a la fecha indicada (YYYY-MM-DD); ej:
+// SetCurrency("USD", decimal.MustParse("3950.25"), "2024-05-10")
+func (i *Invoice) SetCurrency(currencyID string, rate decimal.Decimal, date string) {
+	i.DocumentCurrencyCode.Value = currencyID
+	i.PaymentExchangeRate = nil
+	if currencyID != common.CurrencyCOP {
+		i.PaymentExchangeRate = common.NewPaymentExchangeRate(currencyID, rate, date)
+	}
 }
 
-func (i *Invoice) CalculateTotals() {
-	var lineExtension, taxExclusive, taxInclusive float64
+// Currency retorna la moneda del documento (COP si no se ha definido)
+func (i *Invoice) Currency() string {
+	if i.DocumentCurrencyCode.Value == "" {
+		return common.CurrencyCOP
+	}
+	return i.DocumentCurrencyCode.Value
+}
 
-	// Agrupar impuestos por tipo (ID) y porcentaje
-	taxMap := make(map[string]*common.TaxSubtotal)
+// TaxTotalsInCOP retorna los impuestos del documento convertidos a pesos con PaymentExchangeRate,
+// tal como DIAN los liquida en facturas en moneda extranjera. Cada subtotal se convierte y
+// redondea por separado, y el total de cada tributo es la suma de sus subtotales convertidos.
+// Para facturas en COP retorna TaxTotal sin cambios.
+func (i *Invoice) TaxTotalsInCOP() ([]common.TaxTotal, error) {
+	if i.Currency() == common.CurrencyCOP {
+		return i.TaxTotal, nil
+	}
+	if err := i.validateExchangeRate(); err != nil {
+		return nil, err
+	}
 
-	for _, line := range i.InvoiceLines {
-		lineExtension += line.LineExtensionAmount.Value
-
-		for _, lineTax := range line.TaxTotal {
-			for _, subtotal := range lineTax.TaxSubtotal {
-				key := subtotal.TaxCategory.TaxScheme.ID + "_" + fmt.Sprintf("%.2f", subtotal.TaxCategory.Percent)
-
-				if existing, ok := taxMap[key]; ok {
-					existing.TaxableAmount.Value += subtotal.TaxableAmount.Value
-					existing.TaxAmount.Value += subtotal.TaxAmount.Value
-				} else {
-					taxMap[key] = &common.TaxSubtotal{
-						TaxableAmount: common.AmountType{
-							Value:      subtotal.TaxableAmount.Value,
-							CurrencyID: "COP",
-						},
-						TaxAmount: common.AmountType{
-							Value:      subtotal.TaxAmount.Value,
-							CurrencyID: "COP",
-						},
-						TaxCategory: subtotal.TaxCategory,
-					}
-				}
+	rate := *i.PaymentExchangeRate
+	converted := make([]common.TaxTotal, 0, len(i.TaxTotal))
+	for _, total := range i.TaxTotal {
+		subtotals := make([]common.TaxSubtotal, 0, len(total.TaxSubtotal))
+		for _, subtotal := range total.TaxSubtotal {
+			subtotal.TaxableAmount = rate.Convert(subtotal.TaxableAmount)
+			subtotal.TaxAmount = rate.Convert(subtotal.TaxAmount)
+			if subtotal.PerUnitAmount != nil {
+				perUnit := rate.Convert(*subtotal.PerUnitAmount)
+				subtotal.PerUnitAmount = &perUnit
 			}
+			subtotals = append(subtotals, subtotal)
 		}
+		converted = append(converted, common.NewTaxTotal(subtotals...))
 	}
+	return converted, nil
+}
 
-	taxExclusive = lineExtension
-
-	// Calcular total de impuestos y crear TaxTotal con subtotales
-	var totalTax float64
-	var taxSubtotals []common.TaxSubtotal
-	for _, subtotal := range taxMap {
-		totalTax += subtotal.TaxAmount.Value
-		taxSubtotals = append(taxSubtotals, *subtotal)
-	}
-
-	taxInclusive = lineExtension + totalTax
-
-	i.LegalMonetaryTotal = common.LegalMonetaryTotal{
-		LineExtensionAmount: common.AmountType{Value: lineExtension, CurrencyID: "COP"},
-		TaxExclusiveAmount:  common.AmountType{Value: taxExclusive, CurrencyID: "COP"},
-		TaxInclusiveAmount:  common.AmountType{Value: taxInclusive, CurrencyID: "COP"},
-		PayableAmount:       common.AmountType{Value: taxInclusive, CurrencyID: "COP"},
+// validateExchangeRate verifica la tasa de cambio de una factura en moneda extranjera
+func (i *Invoice) validateExchangeRate() error {
+	rate := i.PaymentExchangeRate
+	switch {
+	case rate == nil:
+		return fmt.Errorf("la factura en %s requiere PaymentExchangeRate", i.Currency())
+	case rate.SourceCurrencyCode != i.Currency():
+		return fmt.Errorf("la moneda origen de la tasa de cambio (%s) no corresponde a la del documento (%s)", rate.SourceCurrencyCode, i.Currency())
+	case rate.TargetCurrencyCode != common.CurrencyCOP:
+		return fmt.Errorf("la moneda destino de la tasa de cambio debe ser %s", common.CurrencyCOP)
+	case rate.CalculationRate.Sign() <= 0:
+		return fmt.Errorf("la tasa de cambio debe ser mayor que cero")
+	case rate.Date == "":
+		return fmt.Errorf("la fecha de la tasa de cambio es requerida")
 	}
+	return nil
+}
 
-	if totalTax > 0 {
-		i.TaxTotal = []common.TaxTotal{
-			{
-				TaxAmount:   common.AmountType{Value: totalTax, CurrencyID: "COP"},
-				TaxSubtotal: taxSubtotals,
-			},
-		}
-	}
+func (i *Invoice) AddLine(line InvoiceLine) {
+	i.InvoiceLines = append(i.InvoiceLines, line)
+	i.LineCountNumeric = len(i.InvoiceLines)
 }
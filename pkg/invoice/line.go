@@ -1,6 +1,12 @@
 package invoice
 
-import "github.com/diegofxm/go-dian/pkg/common"
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
+)
 
 // InvoiceLine representa una línea de factura
 type InvoiceLine struct {
@@ -11,12 +17,195 @@ type InvoiceLine struct {
 	Delivery              *InvoiceLineDelivery       `xml:"cac:Delivery,omitempty"`
 	AllowanceCharge       []common.AllowanceCharge   `xml:"cac:AllowanceCharge,omitempty"`
 	TaxTotal              []common.TaxTotal          `xml:"cac:TaxTotal,omitempty"`
+	WithholdingTaxTotal   []common.TaxTotal          `xml:"cac:WithholdingTaxTotal,omitempty"`
 	DocumentReference     []common.DocumentReference `xml:"cac:DocumentReference,omitempty"`
 	PricingReference      *PricingReference          `xml:"cac:PricingReference,omitempty"`
 	Item                  Item                       `xml:"cac:Item"`
 	Price                 Price                      `xml:"cac:Price"`
 }
 
+// Tipos de precio de referencia (PriceTypeCode) para líneas gratuitas, tabla 13.3.10 del Anexo Técnico
+const (
+	PriceTypeCommercialValue = "01" // Valor comercial
+	PriceTypeInventoryValue  = "02" // Valor en inventarios
+	PriceTypeOther           = "03" // Otro valor
+)
+
+// PriceTypeNames son las descripciones (PriceType) de los tipos de precio de referencia
+var PriceTypeNames = map[string]string{
+	PriceTypeCommercialValue: "Valor comercial",
+	PriceTypeInventoryValue:  "Valor en inventarios",
+	PriceTypeOther:           "Otro valor",
+}
+
+// SetFreeOfCharge marca la línea como gratuita (muestra, obsequio o bonificación) con el
+// precio unitario de referencia sobre el que se liquidan sus impuestos
+func (l *InvoiceLine) SetFreeOfCharge(priceTypeCode string, referencePrice common.AmountType) {
+	free := true
+	l.FreeOfChargeIndicator = &free
+	l.PricingReference = &PricingReference{
+		AlternativeConditionPrice: []AlternativeConditionPrice{{
+			PriceAmount:   referencePrice,
+			PriceTypeCode: priceTypeCode,
+			PriceType:     PriceTypeNames[priceTypeCode],
+		}},
+	}
+	l.Price.PriceAmount = common.AmountType{Value: decimal.Zero, CurrencyID: referencePrice.CurrencyID}
+}
+
+// IsFreeOfCharge indica si la línea es gratuita
+func (l InvoiceLine) IsFreeOfCharge() bool {
+	return l.FreeOfChargeIndicator != nil && *l.FreeOfChargeIndicator
+}
+
+// ReferencePrice retorna el precio de referencia de una línea gratuita
+func (l InvoiceLine) ReferencePrice() (AlternativeConditionPrice, bool) {
+	if l.PricingReference == nil || len(l.PricingReference.AlternativeConditionPrice) == 0 {
+		return AlternativeConditionPrice{}, false
+	}
+	return l.PricingReference.AlternativeConditionPrice[0], true
+}
+
+// CalculateAmounts recalcula el valor de la línea a partir de cantidad y precio:
+// LineExtensionAmount = InvoicedQuantity × PriceAmount / BaseQuantity − descuentos + cargos.
+// Los descuentos y cargos porcentuales se calculan sobre el valor bruto, y los impuestos
+// de TaxTotal se recalculan sobre el nuevo valor, de modo que los descuentos de línea
+// reducen la base gravable; los tributos por unidad conservan su BaseUnitMeasure.
+// Las retenciones no se modifican.
+//
+// En las líneas gratuitas LineExtensionAmount es cero y los impuestos se liquidan sobre
+// el valor comercial: InvoicedQuantity × precio de referencia / BaseQuantity.
+//
+// PriceAmount y el precio de referencia se emiten con los decimales de la moneda, por eso
+// se redondean antes de multiplicar: el valor de la línea es el que DIAN recalcula con los
+// precios del XML (ej: 3 × 33,335 se factura como 3 × 33,34 = 100,02).
+func (l *InvoiceLine) CalculateAmounts() {
+	l.Price.PriceAmount = l.Price.PriceAmount.Rounded()
+	currency := l.Price.PriceAmount.CurrencyID
+	unitPrice := l.Price.PriceAmount.Value
+	free := l.IsFreeOfCharge()
+	if reference, ok := l.ReferencePrice(); free && ok {
+		reference.PriceAmount = reference.PriceAmount.Rounded()
+		l.PricingReference.AlternativeConditionPrice[0] = reference
+		unitPrice = reference.PriceAmount.Value
+		currency = reference.PriceAmount.CurrencyID
+	}
+
+	baseQuantity := l.Price.BaseQuantity.Value
+	if baseQuantity.IsZero() {
+		baseQuantity = decimal.NewFromInt(1)
+	}
+	precision := common.PrecisionFor(currency)
+	gross := common.NewAmount(l.InvoicedQuantity.Value.Mul(unitPrice).Div(baseQuantity, precision.Places, precision.Mode), currency)
+
+	taxBase := gross
+	if free {
+		l.LineExtensionAmount = common.AmountType{Value: decimal.Zero, CurrencyID: currency}
+	} else {
+		value := gross.Value
+		for idx := range l.AllowanceCharge {
+			ac := l.AllowanceCharge[idx].Calculate(gross)
+			if ac.ID == "" {
+				ac.ID = strconv.Itoa(idx + 1)
+			}
+			l.AllowanceCharge[idx] = ac
+			value = value.Add(ac.SignedAmount())
+		}
+		l.LineExtensionAmount = common.NewAmount(value, currency)
+		taxBase = l.LineExtensionAmount
+	}
+
+	for idx := range l.TaxTotal {
+		total := &l.TaxTotal[idx]
+		for j, subtotal := range total.TaxSubtotal {
+			scheme := subtotal.TaxCategory.TaxScheme.ID
+			switch {
+			case !subtotal.IsPerUnit():
+				total.TaxSubtotal[j] = common.NewPercentTaxSubtotal(scheme, taxBase, subtotal.TaxCategory.Percent)
+			case subtotal.BaseUnitMeasure != nil:
+				total.TaxSubtotal[j] = common.NewPerUnitTaxSubtotal(scheme, taxBase, *subtotal.BaseUnitMeasure, *subtotal.PerUnitAmount)
+			default:
+				continue
+			}
+			total.TaxSubtotal[j].TaxCategory = subtotal.TaxCategory
+		}
+		*total = common.NewTaxTotal(total.TaxSubtotal...)
+	}
+}
+
+// validateFreeOfCharge verifica las reglas de DIAN para líneas gratuitas: valor de la línea
+// en cero, precio de referencia con PriceTypeCode 01 a 03 y base gravable igual al valor comercial
+func (l InvoiceLine) validateFreeOfCharge() error {
+	if !l.LineExtensionAmount.Value.IsZero() {
+		return fmt.Errorf("la línea gratuita debe tener LineExtensionAmount en cero")
+	}
+	if len(l.AllowanceCharge) > 0 {
+		return fmt.Errorf("la línea gratuita no admite descuentos ni cargos")
+	}
+	reference, ok := l.ReferencePrice()
+	if !ok {
+		return fmt.Errorf("la línea gratuita requiere PricingReference con el precio de referencia")
+	}
+	if _, ok := PriceTypeNames[reference.PriceTypeCode]; !ok {
+		return fmt.Errorf("PriceTypeCode %q inválido: debe ser 01, 02 o 03", reference.PriceTypeCode)
+	}
+	if reference.PriceAmount.Value.Sign() <= 0 {
+		return fmt.Errorf("el precio de referencia de la línea gratuita debe ser mayor que cero")
+	}
+
+	baseQuantity := l.Price.BaseQuantity.Value
+	if baseQuantity.IsZero() {
+		baseQuantity = decimal.NewFromInt(1)
+	}
+	precision := common.PrecisionFor(reference.PriceAmount.CurrencyID)
+	commercial := l.InvoicedQuantity.Value.Mul(reference.PriceAmount.Rounded().Value).Div(baseQuantity, precision.Places, precision.Mode)
+	for _, total := range l.TaxTotal {
+		for _, subtotal := range total.TaxSubtotal {
+			if !subtotal.TaxableAmount.Value.Equal(commercial) {
+				return fmt.Errorf("la base gravable %s de la línea gratuita debe ser el valor comercial %s", subtotal.TaxableAmount.Value, commercial)
+			}
+		}
+	}
+	return nil
+}
+
+// amountsPending indica si la línea tiene precio (o es gratuita) pero aún no tiene sus
+// valores calculados: LineExtensionAmount y bases gravables en cero
+func (l InvoiceLine) amountsPending() bool {
+	if l.Price.PriceAmount.Value.IsZero() && !l.IsFreeOfCharge() {
+		return false
+	}
+	return l.LineExtensionAmount.Value.IsZero() && l.taxableBase().IsZero()
+}
+
+// TaxTreatment retorna el tratamiento de la línea frente al tributo schemeID:
+// excluida si no tiene subtotal del tributo, exenta, gravada a 0% o gravada
+func (l InvoiceLine) TaxTreatment(schemeID string) common.TaxTreatment {
+	treatment := common.TaxTreatmentExcluded
+	for _, total := range l.TaxTotal {
+		for _, subtotal := range total.TaxSubtotal {
+			if subtotal.TaxCategory.TaxScheme.ID == schemeID && subtotal.Treatment() > treatment {
+				treatment = subtotal.Treatment()
+			}
+		}
+	}
+	return treatment
+}
+
+// taxableBase retorna la base gravable de la línea: el mayor TaxableAmount de sus
+// impuestos, o cero si la línea está excluida de todos los tributos
+func (l InvoiceLine) taxableBase() decimal.Decimal {
+	base := decimal.Zero
+	for _, total := range l.TaxTotal {
+		for _, subtotal := range total.TaxSubtotal {
+			if subtotal.TaxableAmount.Value.Cmp(base) > 0 {
+				base = subtotal.TaxableAmount.Value
+			}
+		}
+	}
+	return base
+}
+
 // InvoiceLineDelivery representa la entrega de una línea
 type InvoiceLineDelivery struct {
 	DeliveryLocation *DeliveryLocation `xml:"cac:DeliveryLocation,omitempty"`
@@ -0,0 +1,175 @@
+package invoice
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
+)
+
+// newFreeLine construye una línea gratuita de 2 unidades con precio de referencia 20000 e IVA 19%
+func newFreeLine(t *testing.T, id string) InvoiceLine {
+	t.Helper()
+
+	line, err := NewLineBuilder(id, Item{Description: "Muestra"}).
+		Quantity(decimal.NewFromInt(2), "94").
+		FreeOfCharge(PriceTypeCommercialValue, decimal.NewFromInt(20000)).
+		Taxes(IVA19).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	return line
+}
+
+func TestBuildFreeOfCharge(t *testing.T) {
+	line := newFreeLine(t, "1")
+
+	if !line.IsFreeOfCharge() {
+		t.Error("la línea no quedó marcada como gratuita")
+	}
+	if line.LineExtensionAmount.String() != "0.00" || line.Price.PriceAmount.String() != "0.00" {
+		t.Errorf("LineExtensionAmount = %s, PriceAmount = %s, se esperaba 0.00", line.LineExtensionAmount, line.Price.PriceAmount)
+	}
+	reference, ok := line.ReferencePrice()
+	if !ok || reference.PriceAmount.String() != "20000.00" || reference.PriceTypeCode != "01" || reference.PriceType != "Valor comercial" {
+		t.Errorf("precio de referencia = %+v", reference)
+	}
+
+	// Los impuestos se liquidan sobre el valor comercial: 2 × 20000
+	subtotal := line.TaxTotal[0].TaxSubtotal[0]
+	if subtotal.TaxableAmount.String() != "40000.00" || subtotal.TaxAmount.String() != "7600.00" {
+		t.Errorf("IVA = %s sobre %s, se esperaba 7600.00 sobre 40000.00", subtotal.TaxAmount, subtotal.TaxableAmount)
+	}
+}
+
+func TestBuildFreeOfChargeInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(b *LineBuilder) *LineBuilder
+		err       string
+	}{
+		{
+			name:      "sin precio de referencia",
+			configure: func(b *LineBuilder) *LineBuilder { return b.FreeOfCharge(PriceTypeCommercialValue, decimal.Zero) },
+			err:       "el precio de referencia de la línea gratuita debe ser mayor que cero",
+		},
+		{
+			name:      "tipo de precio inválido",
+			configure: func(b *LineBuilder) *LineBuilder { return b.FreeOfCharge("04", decimal.NewFromInt(20000)) },
+			err:       `PriceTypeCode "04" inválido`,
+		},
+		{
+			name: "con precio unitario",
+			configure: func(b *LineBuilder) *LineBuilder {
+				return b.UnitPrice(decimal.NewFromInt(1000)).FreeOfCharge(PriceTypeCommercialValue, decimal.NewFromInt(20000))
+			},
+			err: "la línea gratuita no admite precio, descuentos ni cargos",
+		},
+		{
+			name: "con descuento",
+			configure: func(b *LineBuilder) *LineBuilder {
+				return b.Discount("Descuento", decimal.NewFromInt(1000)).FreeOfCharge(PriceTypeCommercialValue, decimal.NewFromInt(20000))
+			},
+			err: "la línea gratuita no admite precio, descuentos ni cargos",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewLineBuilder("1", Item{Description: "Muestra"}).Taxes(IVA19)
+			_, err := tt.configure(builder).Build()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, se esperaba %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCalculateTotalsFreeOfCharge(t *testing.T) {
+	inv := newTotalsInvoice(t, "100000")
+	inv.AddLine(newFreeLine(t, "2"))
+
+	// Línea gratuita armada a mano, sin valores calculados: CalculateTotals la liquida
+	manual := InvoiceLine{
+		ID:               "3",
+		InvoicedQuantity: common.Quantity{Value: decimal.NewFromInt(1), UnitCode: "94"},
+		TaxTotal:         []common.TaxTotal{common.NewTaxTotal(common.NewPercentTaxSubtotal(common.TaxSchemeIVA, copAmount("0"), decimal.New(1900, 2)))},
+		Item:             Item{Description: "Obsequio"},
+	}
+	manual.SetFreeOfCharge(PriceTypeInventoryValue, copAmount("5000"))
+	inv.AddLine(manual)
+	inv.CalculateTotals()
+
+	if got := inv.InvoiceLines[2].TaxTotal[0].TaxAmount.String(); got != "950.00" {
+		t.Errorf("IVA de la línea gratuita manual = %s, se esperaba 950.00", got)
+	}
+
+	// Las líneas gratuitas no suman al valor de las líneas ni se cobran, pero su valor
+	// comercial es base gravable y su IVA hace parte del TaxTotal
+	total := inv.LegalMonetaryTotal
+	expected := map[string][2]string{
+		"LineExtensionAmount": {total.LineExtensionAmount.String(), "100000.00"},
+		"TaxExclusiveAmount":  {total.TaxExclusiveAmount.String(), "145000.00"},
+		"TaxInclusiveAmount":  {total.TaxInclusiveAmount.String(), "127550.00"},
+		"PayableAmount":       {total.PayableAmount.String(), "127550.00"},
+		"IVA":                 {inv.TaxTotal[0].TaxAmount.String(), "27550.00"},
+	}
+	for field, values := range expected {
+		if values[0] != values[1] {
+			t.Errorf("%s = %s, se esperaba %s", field, values[0], values[1])
+		}
+	}
+	if err := inv.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestValidateFreeOfCharge(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(line *InvoiceLine)
+		err    string
+	}{
+		{
+			name:   "sin precio de referencia",
+			modify: func(line *InvoiceLine) { line.PricingReference = nil },
+			err:    "la línea gratuita requiere PricingReference",
+		},
+		{
+			name: "precio de referencia en cero",
+			modify: func(line *InvoiceLine) {
+				line.PricingReference.AlternativeConditionPrice[0].PriceAmount = copAmount("0")
+			},
+			err: "debe ser mayor que cero",
+		},
+		{
+			name:   "valor de la línea distinto de cero",
+			modify: func(line *InvoiceLine) { line.LineExtensionAmount = copAmount("40000") },
+			err:    "la línea gratuita debe tener LineExtensionAmount en cero",
+		},
+		{
+			name: "base gravable distinta del valor comercial",
+			modify: func(line *InvoiceLine) {
+				line.TaxTotal = []common.TaxTotal{common.NewTaxTotal(common.NewPercentTaxSubtotal(common.TaxSchemeIVA, copAmount("20000"), decimal.New(1900, 2)))}
+			},
+			err: "debe ser el valor comercial 40000.00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := newTotalsInvoice(t, "100000")
+			line := newFreeLine(t, "2")
+			tt.modify(&line)
+			inv.AddLine(line)
+			inv.CalculateTotals()
+
+			err := inv.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, se esperaba %q", err, tt.err)
+			}
+		})
+	}
+}
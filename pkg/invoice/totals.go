@@ -0,0 +1,303 @@
+package invoice
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
+)
+
+// CalculateTotals calcula LegalMonetaryTotal, TaxTotal y WithholdingTaxTotal a partir de las líneas.
+// Los montos se expresan en la moneda del documento (DocumentCurrencyCode).
+// Las líneas con precio (o gratuitas) sin valores calculados se liquidan con
+// InvoiceLine.CalculateAmounts; las que ya tienen LineExtensionAmount o bases gravables
+// (ej: construidas con LineBuilder o con montos asignados por el llamador) se conservan.
+// Si se modifica una línea ya calculada, llame a su CalculateAmounts antes de los totales.
+// Las líneas gratuitas no suman a LineExtensionAmount, pero su valor comercial sí hace
+// parte de la base gravable y sus impuestos del TaxTotal; si el vendedor asume esos
+// impuestos puede registrarlos como descuento del documento con common.DiscountTaxAssumed.
+//
+// TaxExclusiveAmount es la suma de las bases gravables de las líneas con algún impuesto,
+// incluidas las exentas y las de tarifa 0%; las líneas excluidas no suman.
+//
+// Los descuentos y cargos del documento (AllowanceCharge) no modifican la base gravable:
+// se reportan en AllowanceTotalAmount y ChargeTotalAmount y ajustan PayableAmount.
+// Los descuentos que deben reducir la base gravable se registran en las líneas.
+//
+// Los anticipos (PrepaidPayment) se suman en PrepaidAmount y se descuentan de PayableAmount.
+// CalculateTotals elimina el ajuste por redondeo; use RoundPayable después de calcular.
+//
+// Las retenciones del documento son las de las líneas más las agregadas con AddWithholding,
+// agrupadas por tributo y tarifa. Si no hay ninguna de las dos se conserva WithholdingTaxTotal
+// tal como fue asignado. Las retenciones no afectan PayableAmount: el adquiriente las
+// descuenta al pagar.
+func (i *Invoice) CalculateTotals() {
+	currency := i.Currency()
+	lineExtension, taxExclusive := decimal.Zero, decimal.Zero
+
+	taxes := newTaxAggregator(currency)
+	withholdings := newTaxAggregator(currency)
+	for idx := range i.InvoiceLines {
+		line := &i.InvoiceLines[idx]
+		if line.amountsPending() {
+			line.CalculateAmounts()
+		}
+		lineExtension = lineExtension.Add(line.LineExtensionAmount.Value)
+		taxExclusive = taxExclusive.Add(line.taxableBase())
+		taxes.add(line.TaxTotal)
+		withholdings.add(line.WithholdingTaxTotal)
+	}
+	if len(i.documentWithholdings) > 0 {
+		withholdings.add([]common.TaxTotal{{TaxSubtotal: i.documentWithholdings}})
+	}
+	if withholdings.empty() {
+		withholdings.add(i.WithholdingTaxTotal)
+	}
+
+	// Los totales se calculan sobre montos ya redondeados a la precisión de la moneda,
+	// para que las sumas coincidan exactamente con los valores emitidos en el XML
+	lineExtension = common.RoundAmount(lineExtension, currency)
+	taxExclusive = common.RoundAmount(taxExclusive, currency)
+
+	taxTotals, totalTax := taxes.totals()
+	taxInclusive := lineExtension.Add(totalTax)
+
+	// Descuentos y cargos del documento; los porcentuales se calculan sobre LineExtensionAmount
+	allowances, charges := decimal.Zero, decimal.Zero
+	for idx := range i.AllowanceCharge {
+		ac := i.AllowanceCharge[idx].Calculate(common.AmountType{Value: lineExtension, CurrencyID: currency})
+		if ac.ID == "" {
+			ac.ID = strconv.Itoa(idx + 1)
+		}
+		i.AllowanceCharge[idx] = ac
+		if ac.ChargeIndicator {
+			charges = charges.Add(ac.Amount.Value)
+		} else {
+			allowances = allowances.Add(ac.Amount.Value)
+		}
+	}
+
+	prepaid := decimal.Zero
+	for _, payment := range i.PrepaidPayment {
+		prepaid = prepaid.Add(payment.PaidAmount.Value)
+	}
+	prepaid = common.RoundAmount(prepaid, currency)
+
+	i.LegalMonetaryTotal = common.LegalMonetaryTotal{
+		LineExtensionAmount: common.AmountType{Value: lineExtension, CurrencyID: currency},
+		TaxExclusiveAmount:  common.AmountType{Value: taxExclusive, CurrencyID: currency},
+		TaxInclusiveAmount:  common.AmountType{Value: taxInclusive, CurrencyID: currency},
+		PayableAmount:       common.AmountType{Value: taxInclusive.Sub(allowances).Add(charges).Sub(prepaid), CurrencyID: currency},
+	}
+	if len(i.AllowanceCharge) > 0 {
+		i.LegalMonetaryTotal.AllowanceTotalAmount = &common.AmountType{Value: allowances, CurrencyID: currency}
+		i.LegalMonetaryTotal.ChargeTotalAmount = &common.AmountType{Value: charges, CurrencyID: currency}
+	}
+	if len(i.PrepaidPayment) > 0 {
+		i.LegalMonetaryTotal.PrepaidAmount = &common.AmountType{Value: prepaid, CurrencyID: currency}
+	}
+
+	i.TaxTotal = taxTotals
+	i.WithholdingTaxTotal, _ = withholdings.totals()
+}
+
+// RoundPayable redondea PayableAmount al múltiplo de increment más cercano (ej: 50 o 100 pesos
+// para pagos en efectivo) y registra la diferencia en PayableRoundingAmount.
+// Debe llamarse después de CalculateTotals.
+func (i *Invoice) RoundPayable(increment decimal.Decimal) {
+	total := &i.LegalMonetaryTotal
+	payable := total.PayableAmount.Value
+	if total.PayableRoundingAmount != nil {
+		payable = payable.Sub(total.PayableRoundingAmount.Value)
+	}
+	if increment.Sign() <= 0 {
+		total.PayableAmount.Value = payable
+		total.PayableRoundingAmount = nil
+		return
+	}
+
+	rounded := payable.Div(increment, 0, decimal.RoundHalfUp).Mul(increment)
+	rounded = common.RoundAmount(rounded, total.PayableAmount.CurrencyID)
+	total.PayableAmount.Value = rounded
+	total.PayableRoundingAmount = &common.AmountType{Value: rounded.Sub(payable), CurrencyID: total.PayableAmount.CurrencyID}
+}
+
+// ValidateTotals verifica que LegalMonetaryTotal cuadre con las líneas, impuestos,
+// descuentos, cargos y anticipos del documento, según las reglas de DIAN:
+//
+//	LineExtensionAmount = Σ LineExtensionAmount de las líneas
+//	TaxExclusiveAmount  = Σ bases gravables de las líneas con impuestos
+//	TaxInclusiveAmount  = LineExtensionAmount + Σ TaxTotal
+//	AllowanceTotalAmount, ChargeTotalAmount = Σ descuentos y cargos del documento
+//	PrepaidAmount       = Σ PrepaidPayment
+//	PayableAmount       = TaxInclusiveAmount − AllowanceTotalAmount + ChargeTotalAmount − PrepaidAmount + PayableRoundingAmount
+func (i *Invoice) ValidateTotals() error {
+	total := i.LegalMonetaryTotal
+	currency := i.Currency()
+	round := func(value decimal.Decimal) decimal.Decimal { return common.RoundAmount(value, currency) }
+	check := func(field string, actual, expected decimal.Decimal) error {
+		if !actual.Equal(expected) {
+			return fmt.Errorf("%s %s no cuadra: se esperaba %s", field, actual, round(expected))
+		}
+		return nil
+	}
+	optional := func(amount *common.AmountType) decimal.Decimal {
+		if amount == nil {
+			return decimal.Zero
+		}
+		return amount.Value
+	}
+
+	lineExtension, taxExclusive := decimal.Zero, decimal.Zero
+	for _, line := range i.InvoiceLines {
+		lineExtension = lineExtension.Add(line.LineExtensionAmount.Value)
+		taxExclusive = taxExclusive.Add(line.taxableBase())
+	}
+	totalTax := decimal.Zero
+	for _, tax := range i.TaxTotal {
+		totalTax = totalTax.Add(tax.TaxAmount.Value)
+	}
+	allowances, charges := decimal.Zero, decimal.Zero
+	for _, ac := range i.AllowanceCharge {
+		if ac.ChargeIndicator {
+			charges = charges.Add(ac.Amount.Value)
+		} else {
+			allowances = allowances.Add(ac.Amount.Value)
+		}
+	}
+	prepaid := decimal.Zero
+	for _, payment := range i.PrepaidPayment {
+		prepaid = prepaid.Add(payment.PaidAmount.Value)
+	}
+
+	payable := total.TaxInclusiveAmount.Value.
+		Sub(optional(total.AllowanceTotalAmount)).
+		Add(optional(total.ChargeTotalAmount)).
+		Sub(optional(total.PrepaidAmount)).
+		Add(optional(total.PayableRoundingAmount))
+
+	checks := []struct {
+		field            string
+		actual, expected decimal.Decimal
+	}{
+		{"LineExtensionAmount", total.LineExtensionAmount.Value, round(lineExtension)},
+		{"TaxExclusiveAmount", total.TaxExclusiveAmount.Value, round(taxExclusive)},
+		{"TaxInclusiveAmount", total.TaxInclusiveAmount.Value, total.LineExtensionAmount.Value.Add(totalTax)},
+		{"AllowanceTotalAmount", optional(total.AllowanceTotalAmount), allowances},
+		{"ChargeTotalAmount", optional(total.ChargeTotalAmount), charges},
+		{"PrepaidAmount", optional(total.PrepaidAmount), round(prepaid)},
+		{"PayableAmount", total.PayableAmount.Value, payable},
+	}
+	for _, c := range checks {
+		if err := check(c.field, c.actual, c.expected); err != nil {
+			return err
+		}
+	}
+	if total.PayableAmount.Value.IsNegative() {
+		return fmt.Errorf("PayableAmount no puede ser negativo: los descuentos y anticipos superan el total")
+	}
+	return nil
+}
+
+// AddWithholding agrega una retención a nivel de documento, agrupada por tributo
+// (ej: common.NewPercentTaxSubtotal(common.TaxSchemeReteRenta, base, decimal.New(25, 1)))
+// y sumada a las retenciones de las líneas en CalculateTotals
+func (i *Invoice) AddWithholding(subtotal common.TaxSubtotal) {
+	i.documentWithholdings = append(i.documentWithholdings, subtotal)
+	for idx := range i.WithholdingTaxTotal {
+		total := &i.WithholdingTaxTotal[idx]
+		if len(total.TaxSubtotal) > 0 && total.TaxSubtotal[0].TaxCategory.TaxScheme.ID == subtotal.TaxCategory.TaxScheme.ID {
+			total.TaxSubtotal = append(total.TaxSubtotal, subtotal)
+			total.TaxAmount.Value = total.TaxAmount.Value.Add(subtotal.TaxAmount.Value)
+			return
+		}
+	}
+	i.WithholdingTaxTotal = append(i.WithholdingTaxTotal, common.NewTaxTotal(subtotal))
+}
+
+// taxAggregator agrupa subtotales por tributo y tarifa (porcentual o por unidad),
+// conservando el orden de aparición
+type taxAggregator struct {
+	currency  string
+	subtotals map[string]*common.TaxSubtotal
+	keys      []string
+}
+
+func newTaxAggregator(currency string) *taxAggregator {
+	return &taxAggregator{currency: currency, subtotals: make(map[string]*common.TaxSubtotal)}
+}
+
+func (a *taxAggregator) empty() bool {
+	return len(a.keys) == 0
+}
+
+func (a *taxAggregator) add(taxTotals []common.TaxTotal) {
+	for _, total := range taxTotals {
+		for _, subtotal := range total.TaxSubtotal {
+			key := taxKey(subtotal)
+			existing, ok := a.subtotals[key]
+			if !ok {
+				existing = &common.TaxSubtotal{
+					TaxableAmount: common.AmountType{CurrencyID: a.currency},
+					TaxAmount:     common.AmountType{CurrencyID: a.currency},
+					TaxCategory:   subtotal.TaxCategory,
+				}
+				if subtotal.IsPerUnit() {
+					perUnit := *subtotal.PerUnitAmount
+					existing.PerUnitAmount = &perUnit
+					existing.BaseUnitMeasure = &common.Quantity{}
+					if subtotal.BaseUnitMeasure != nil {
+						existing.BaseUnitMeasure.UnitCode = subtotal.BaseUnitMeasure.UnitCode
+					}
+				}
+				a.subtotals[key] = existing
+				a.keys = append(a.keys, key)
+			}
+
+			existing.TaxableAmount.Value = existing.TaxableAmount.Value.Add(subtotal.TaxableAmount.Value)
+			existing.TaxAmount.Value = existing.TaxAmount.Value.Add(subtotal.TaxAmount.Value)
+			if subtotal.IsPerUnit() && subtotal.BaseUnitMeasure != nil {
+				existing.BaseUnitMeasure.Value = existing.BaseUnitMeasure.Value.Add(subtotal.BaseUnitMeasure.Value)
+			}
+		}
+	}
+}
+
+// totals retorna un TaxTotal por tributo con sus subtotales redondeados, y la suma de todos ellos
+func (a *taxAggregator) totals() ([]common.TaxTotal, decimal.Decimal) {
+	sum := decimal.Zero
+	var taxTotals []common.TaxTotal
+	schemeIndex := make(map[string]int)
+	for _, key := range a.keys {
+		subtotal := a.subtotals[key]
+		subtotal.TaxableAmount = subtotal.TaxableAmount.Rounded()
+		subtotal.TaxAmount = subtotal.TaxAmount.Rounded()
+		sum = sum.Add(subtotal.TaxAmount.Value)
+
+		scheme := subtotal.TaxCategory.TaxScheme.ID
+		idx, ok := schemeIndex[scheme]
+		if !ok {
+			idx = len(taxTotals)
+			schemeIndex[scheme] = idx
+			taxTotals = append(taxTotals, common.TaxTotal{TaxAmount: common.AmountType{Value: decimal.Zero, CurrencyID: a.currency}})
+		}
+		taxTotals[idx].TaxAmount.Value = taxTotals[idx].TaxAmount.Value.Add(subtotal.TaxAmount.Value)
+		taxTotals[idx].TaxSubtotal = append(taxTotals[idx].TaxSubtotal, *subtotal)
+	}
+	return taxTotals, sum
+}
+
+// taxKey identifica la tarifa de un subtotal: tributo y porcentaje (con el motivo de
+// exención, si lo hay), o tributo y valor por unidad
+func taxKey(subtotal common.TaxSubtotal) string {
+	scheme := subtotal.TaxCategory.TaxScheme.ID
+	if subtotal.IsPerUnit() {
+		return scheme + "_u_" + subtotal.PerUnitAmount.Value.StringFixed(6) + "_" + subtotal.PerUnitAmount.CurrencyID
+	}
+	key := scheme + "_" + subtotal.TaxCategory.Percent.StringFixed(6)
+	if subtotal.Treatment() == common.TaxTreatmentExempt {
+		key += "_e_" + subtotal.TaxCategory.TaxExemptionReasonCode + "_" + subtotal.TaxCategory.TaxExemptionReason
+	}
+	return key
+}
@@ -0,0 +1,422 @@
+package invoice
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/diegofxm/go-dian/pkg/common"
+	"github.com/diegofxm/go-dian/pkg/decimal"
+)
+
+func TestCalculateAmountsPricePrecision(t *testing.T) {
+	line, err := NewLineBuilder("1", Item{Description: "Tornillo"}).
+		Quantity(decimal.NewFromInt(3), "94").
+		UnitPrice(decimal.MustParse("33.335")).
+		Taxes(IVA19).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	// El XML emite PriceAmount con dos decimales: LineExtensionAmount debe ser 3 × 33.34
+	if got := line.Price.PriceAmount.String(); got != "33.34" {
+		t.Errorf("PriceAmount = %s, se esperaba 33.34", got)
+	}
+	if got := line.LineExtensionAmount.String(); got != "100.02" {
+		t.Errorf("LineExtensionAmount = %s, se esperaba 100.02", got)
+	}
+	if got := line.TaxTotal[0].TaxAmount.String(); got != "19.00" {
+		t.Errorf("IVA = %s, se esperaba 19.00", got)
+	}
+}
+
+func TestCalculateTotalsKeepsLineAmounts(t *testing.T) {
+	cop := func(value string) common.AmountType {
+		return common.AmountType{Value: decimal.MustParse(value), CurrencyID: common.CurrencyCOP}
+	}
+
+	// Línea con montos asignados por el llamador: valor pactado distinto de cantidad × precio
+	set := InvoiceLine{
+		ID:                  "1",
+		InvoicedQuantity:    common.Quantity{Value: decimal.NewFromInt(1), UnitCode: "94"},
+		LineExtensionAmount: cop("95000.00"),
+		TaxTotal:            []common.TaxTotal{common.NewTaxTotal(common.NewPercentTaxSubtotal(common.TaxSchemeIVA, cop("95000.00"), decimal.New(1900, 2)))},
+		Item:                Item{Description: "Servicio pactado"},
+		Price:               Price{PriceAmount: cop("100000.00")},
+	}
+	// Línea con precio y tarifa pero sin valores calculados
+	pending := InvoiceLine{
+		ID:               "2",
+		InvoicedQuantity: common.Quantity{Value: decimal.NewFromInt(2), UnitCode: "94"},
+		TaxTotal:         []common.TaxTotal{common.NewTaxTotal(common.NewPercentTaxSubtotal(common.TaxSchemeIVA, cop("0"), decimal.New(1900, 2)))},
+		Item:             Item{Description: "Producto"},
+		Price:            Price{PriceAmount: cop("1000.00")},
+	}
+
+	inv := NewInvoice("SETP990000001")
+	inv.AddLine(set)
+	inv.AddLine(pending)
+	inv.CalculateTotals()
+
+	if got := inv.InvoiceLines[0].LineExtensionAmount.String(); got != "95000.00" {
+		t.Errorf("LineExtensionAmount de la línea asignada = %s, se esperaba 95000.00", got)
+	}
+	if got := inv.InvoiceLines[1].LineExtensionAmount.String(); got != "2000.00" {
+		t.Errorf("LineExtensionAmount de la línea pendiente = %s, se esperaba 2000.00", got)
+	}
+	if got := inv.LegalMonetaryTotal.LineExtensionAmount.String(); got != "97000.00" {
+		t.Errorf("LegalMonetaryTotal.LineExtensionAmount = %s, se esperaba 97000.00", got)
+	}
+	if got := inv.TaxTotal[0].TaxAmount.String(); got != "18430.00" {
+		t.Errorf("IVA = %s, se esperaba 18430.00", got)
+	}
+	if err := inv.ValidateTotals(); err != nil {
+		t.Errorf("ValidateTotals: %v", err)
+	}
+}
+
+func TestCalculateTotalsMergesWithholdings(t *testing.T) {
+	line, err := NewLineBuilder("1", Item{Description: "Servicio"}).
+		UnitPrice(decimal.NewFromInt(100000)).
+		Taxes(IVA19).
+		Withholding(common.TaxSchemeReteIVA, decimal.New(1500, 2)).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	inv := NewInvoice("SETP990000001")
+	inv.AddLine(line)
+	inv.AddWithholding(common.NewPercentTaxSubtotal(common.TaxSchemeReteRenta, copAmount("100000"), decimal.New(250, 2)))
+	inv.AddWithholding(common.NewPercentTaxSubtotal(common.TaxSchemeReteIVA, copAmount("10000"), decimal.New(1500, 2)))
+
+	// CalculateTotals es idempotente: las retenciones del documento no se suman dos veces
+	inv.CalculateTotals()
+	inv.CalculateTotals()
+
+	expected := map[string][2]string{
+		common.TaxSchemeReteIVA:   {"29000.00", "4350.00"},
+		common.TaxSchemeReteRenta: {"100000.00", "2500.00"},
+	}
+	if len(inv.WithholdingTaxTotal) != len(expected) {
+		t.Fatalf("WithholdingTaxTotal tiene %d tributos, se esperaban %d", len(inv.WithholdingTaxTotal), len(expected))
+	}
+	for _, total := range inv.WithholdingTaxTotal {
+		if len(total.TaxSubtotal) != 1 {
+			t.Errorf("la retención tiene %d subtotales, se esperaba 1", len(total.TaxSubtotal))
+			continue
+		}
+		subtotal := total.TaxSubtotal[0]
+		amounts := expected[subtotal.TaxCategory.TaxScheme.ID]
+		if subtotal.TaxableAmount.String() != amounts[0] || total.TaxAmount.String() != amounts[1] {
+			t.Errorf("retención %s: base %s valor %s, se esperaba base %s valor %s", subtotal.TaxCategory.TaxScheme.ID,
+				subtotal.TaxableAmount, total.TaxAmount, amounts[0], amounts[1])
+		}
+	}
+}
+
+// newTotalsInvoice crea una factura en pesos con los datos obligatorios y una línea
+// de unitPrice gravada con IVA 19%, con los totales calculados
+func newTotalsInvoice(t *testing.T, unitPrice string, prepaid ...string) *Invoice {
+	t.Helper()
+
+	line, err := NewLineBuilder("1", Item{Description: "Servicio"}).
+		UnitPrice(decimal.MustParse(unitPrice)).
+		Taxes(IVA19).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	inv := NewInvoice("SETP990000001")
+	inv.AccountingSupplierParty.Party.PartyTaxScheme.CompanyID = common.IDType{Value: "900123456", SchemeName: "31"}
+	inv.AccountingCustomerParty.Party.PartyTaxScheme.CompanyID = common.IDType{Value: "800199436", SchemeName: "31"}
+	inv.AddLine(line)
+	for idx, amount := range prepaid {
+		inv.PrepaidPayment = append(inv.PrepaidPayment, common.PrepaidPayment{ID: strconv.Itoa(idx + 1), PaidAmount: copAmount(amount)})
+	}
+	inv.CalculateTotals()
+	return inv
+}
+
+func TestCalculateTotalsPrepaid(t *testing.T) {
+	inv := newTotalsInvoice(t, "100000", "50000", "20000.50")
+
+	total := inv.LegalMonetaryTotal
+	if total.TaxInclusiveAmount.String() != "119000.00" {
+		t.Errorf("TaxInclusiveAmount = %s, se esperaba 119000.00", total.TaxInclusiveAmount)
+	}
+	if total.PrepaidAmount == nil || total.PrepaidAmount.String() != "70000.50" {
+		t.Errorf("PrepaidAmount = %v, se esperaba 70000.50", total.PrepaidAmount)
+	}
+	if total.PayableAmount.String() != "48999.50" {
+		t.Errorf("PayableAmount = %s, se esperaba 48999.50", total.PayableAmount)
+	}
+	if err := inv.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	// Anticipos mayores que el total dejan un valor a pagar negativo
+	inv = newTotalsInvoice(t, "100000", "120000")
+	if err := inv.Validate(); err == nil || !strings.Contains(err.Error(), "PayableAmount no puede ser negativo") {
+		t.Errorf("error = %v, se esperaba PayableAmount negativo", err)
+	}
+}
+
+func TestRoundPayable(t *testing.T) {
+	// 100028.01 + IVA 19005.32 = 119033.33
+	inv := newTotalsInvoice(t, "100028.01")
+
+	tests := []struct {
+		increment string
+		payable   string
+		rounding  string
+	}{
+		{increment: "50", payable: "119050.00", rounding: "16.67"},
+		{increment: "100", payable: "119000.00", rounding: "-33.33"},
+		{increment: "1000", payable: "119000.00", rounding: "-33.33"},
+		{increment: "0", payable: "119033.33"},
+	}
+
+	// RoundPayable parte siempre del valor sin redondear: puede llamarse varias veces
+	for _, tt := range tests {
+		t.Run(tt.increment, func(t *testing.T) {
+			inv.RoundPayable(decimal.MustParse(tt.increment))
+
+			total := inv.LegalMonetaryTotal
+			if total.PayableAmount.String() != tt.payable {
+				t.Errorf("PayableAmount = %s, se esperaba %s", total.PayableAmount, tt.payable)
+			}
+			switch {
+			case tt.rounding == "" && total.PayableRoundingAmount != nil:
+				t.Errorf("PayableRoundingAmount = %s, se esperaba nil", total.PayableRoundingAmount)
+			case tt.rounding != "" && (total.PayableRoundingAmount == nil || total.PayableRoundingAmount.String() != tt.rounding):
+				t.Errorf("PayableRoundingAmount = %v, se esperaba %s", total.PayableRoundingAmount, tt.rounding)
+			}
+			if err := inv.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		})
+	}
+
+	// CalculateTotals elimina el ajuste por redondeo
+	inv.RoundPayable(decimal.NewFromInt(50))
+	inv.CalculateTotals()
+	if inv.LegalMonetaryTotal.PayableRoundingAmount != nil || inv.LegalMonetaryTotal.PayableAmount.String() != "119033.33" {
+		t.Errorf("después de CalculateTotals: PayableAmount = %s, PayableRoundingAmount = %v",
+			inv.LegalMonetaryTotal.PayableAmount, inv.LegalMonetaryTotal.PayableRoundingAmount)
+	}
+}
+
+func TestValidateTotalsMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(inv *Invoice)
+		field  string
+	}{
+		{
+			name:   "valor de las líneas",
+			modify: func(inv *Invoice) { inv.LegalMonetaryTotal.LineExtensionAmount = copAmount("99999.99") },
+			field:  "LineExtensionAmount",
+		},
+		{
+			name:   "base gravable",
+			modify: func(inv *Invoice) { inv.LegalMonetaryTotal.TaxExclusiveAmount = copAmount("0") },
+			field:  "TaxExclusiveAmount",
+		},
+		{
+			name:   "impuestos",
+			modify: func(inv *Invoice) { inv.TaxTotal[0].TaxAmount = copAmount("18999.99") },
+			field:  "TaxInclusiveAmount",
+		},
+		{
+			name:   "anticipo sin PrepaidAmount",
+			modify: func(inv *Invoice) { inv.LegalMonetaryTotal.PrepaidAmount = nil },
+			field:  "PrepaidAmount",
+		},
+		{
+			name: "anticipo no descontado",
+			modify: func(inv *Invoice) {
+				inv.LegalMonetaryTotal.PayableAmount = inv.LegalMonetaryTotal.TaxInclusiveAmount
+			},
+			field: "PayableAmount",
+		},
+		{
+			name: "redondeo sin ajustar el valor a pagar",
+			modify: func(inv *Invoice) {
+				rounding := copAmount("0.50")
+				inv.LegalMonetaryTotal.PayableRoundingAmount = &rounding
+			},
+			field: "PayableAmount",
+		},
+		{
+			name: "descuento del documento sin AllowanceCharge",
+			modify: func(inv *Invoice) {
+				allowance := copAmount("1000")
+				inv.LegalMonetaryTotal.AllowanceTotalAmount = &allowance
+				inv.LegalMonetaryTotal.PayableAmount = copAmount("68000.00")
+			},
+			field: "AllowanceTotalAmount",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := newTotalsInvoice(t, "100000", "50000")
+			if err := inv.ValidateTotals(); err != nil {
+				t.Fatalf("ValidateTotals antes de modificar: %v", err)
+			}
+			tt.modify(inv)
+
+			err := inv.ValidateTotals()
+			if err == nil || !strings.HasPrefix(err.Error(), tt.field+" ") {
+				t.Fatalf("error = %v, se esperaba un error de %s", err, tt.field)
+			}
+			// Validate incluye el cuadre de los totales
+			if err := inv.Validate(); err == nil || !strings.Contains(err.Error(), "totales: "+tt.field) {
+				t.Errorf("Validate = %v, se esperaba el error de %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestCalculateTotalsPerUnitTaxes(t *testing.T) {
+	bag := func(id string, quantity int64, perUnit string) InvoiceLine {
+		line, err := NewLineBuilder(id, Item{Description: "Bolsa plástica"}).
+			Quantity(decimal.NewFromInt(quantity), "94").
+			UnitPrice(decimal.NewFromInt(100)).
+			Taxes(IVA19, PerUnitRate(common.TaxSchemeINCBolsas, decimal.MustParse(perUnit))).
+			Build()
+		if err != nil {
+			t.Fatalf("Build: %v", err)
+		}
+		return line
+	}
+
+	inv := newTotalsInvoice(t, "10000")
+	inv.AddLine(bag("2", 3, "66"))
+	inv.AddLine(bag("3", 2, "66"))
+	inv.AddLine(bag("4", 1, "70"))
+	inv.CalculateTotals()
+
+	// El tributo por unidad se liquida sobre la cantidad, no sobre el valor de la línea
+	if got := inv.InvoiceLines[1].TaxTotal[1].TaxAmount.String(); got != "198.00" {
+		t.Errorf("INC bolsas de la línea 2 = %s, se esperaba 3 × 66 = 198.00", got)
+	}
+
+	// Un TaxTotal por tributo; las tarifas por unidad distintas son subtotales separados
+	if len(inv.TaxTotal) != 2 {
+		t.Fatalf("TaxTotal tiene %d tributos, se esperaban 2 (IVA e INC bolsas)", len(inv.TaxTotal))
+	}
+	iva, bags := inv.TaxTotal[0], inv.TaxTotal[1]
+	if iva.TaxSubtotal[0].TaxCategory.TaxScheme.ID != common.TaxSchemeIVA || iva.TaxAmount.String() != "2014.00" {
+		t.Errorf("IVA = %s, se esperaba 19%% de 10600.00 = 2014.00", iva.TaxAmount)
+	}
+	if bags.TaxAmount.String() != "400.00" || len(bags.TaxSubtotal) != 2 {
+		t.Fatalf("INC bolsas = %s con %d subtotales, se esperaba 400.00 con 2", bags.TaxAmount, len(bags.TaxSubtotal))
+	}
+
+	expected := []struct{ quantity, perUnit, taxable, amount string }{
+		{quantity: "5", perUnit: "66", taxable: "500.00", amount: "330.00"},
+		{quantity: "1", perUnit: "70", taxable: "100.00", amount: "70.00"},
+	}
+	for idx, e := range expected {
+		subtotal := bags.TaxSubtotal[idx]
+		if subtotal.TaxCategory.TaxScheme.ID != common.TaxSchemeINCBolsas || !subtotal.IsPerUnit() {
+			t.Errorf("subtotal %d: tributo %s, por unidad %v", idx, subtotal.TaxCategory.TaxScheme.ID, subtotal.IsPerUnit())
+			continue
+		}
+		if !subtotal.BaseUnitMeasure.Value.Equal(decimal.MustParse(e.quantity)) || subtotal.BaseUnitMeasure.UnitCode != "94" ||
+			!subtotal.PerUnitAmount.Value.Equal(decimal.MustParse(e.perUnit)) ||
+			subtotal.TaxableAmount.String() != e.taxable || subtotal.TaxAmount.String() != e.amount {
+			t.Errorf("subtotal %d: %s unidades × %s = %s sobre %s, se esperaba %s × %s = %s sobre %s", idx,
+				subtotal.BaseUnitMeasure.Value, subtotal.PerUnitAmount, subtotal.TaxAmount, subtotal.TaxableAmount,
+				e.quantity, e.perUnit, e.amount, e.taxable)
+		}
+		if err := subtotal.Validate(); err != nil {
+			t.Errorf("subtotal %d: %v", idx, err)
+		}
+	}
+
+	// 10600 + IVA 2014 + INC bolsas 400
+	if got := inv.LegalMonetaryTotal.PayableAmount.String(); got != "13014.00" {
+		t.Errorf("PayableAmount = %s, se esperaba 13014.00", got)
+	}
+	if err := inv.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestCalculateTotalsTaxTreatments(t *testing.T) {
+	line := func(id, price string, rate TaxRate) InvoiceLine {
+		line, err := NewLineBuilder(id, Item{Description: "Producto " + id}).
+			UnitPrice(decimal.MustParse(price)).
+			Taxes(rate).
+			Build()
+		if err != nil {
+			t.Fatalf("Build: %v", err)
+		}
+		return line
+	}
+
+	inv := newTotalsInvoice(t, "100000")
+	inv.AddLine(line("2", "50000", ExemptRate(common.TaxSchemeIVA, "01", "Bienes exentos del artículo 477 del Estatuto Tributario")))
+	inv.AddLine(line("3", "30000", IVA0))
+	inv.AddLine(line("4", "20000", IVAExcluded))
+	inv.AddLine(line("5", "10000", ExemptRate(common.TaxSchemeIVA, "02", "Servicios exentos del artículo 481 del Estatuto Tributario")))
+	inv.AddLine(line("6", "5000", ExemptRate(common.TaxSchemeIVA, "01", "Bienes exentos del artículo 477 del Estatuto Tributario")))
+	inv.CalculateTotals()
+
+	treatments := []common.TaxTreatment{
+		common.TaxTreatmentTaxed, common.TaxTreatmentExempt, common.TaxTreatmentZeroRated,
+		common.TaxTreatmentExcluded, common.TaxTreatmentExempt, common.TaxTreatmentExempt,
+	}
+	for idx, expected := range treatments {
+		if got := inv.InvoiceLines[idx].TaxTreatment(common.TaxSchemeIVA); got != expected {
+			t.Errorf("línea %s: tratamiento %s, se esperaba %s", inv.InvoiceLines[idx].ID, got, expected)
+		}
+	}
+	if len(inv.InvoiceLines[3].TaxTotal) != 0 {
+		t.Errorf("la línea excluida tiene %d TaxTotal, se esperaba ninguno", len(inv.InvoiceLines[3].TaxTotal))
+	}
+
+	// Las líneas exentas y a tarifa 0% suman a la base gravable; la excluida solo al valor de las líneas
+	total := inv.LegalMonetaryTotal
+	if total.LineExtensionAmount.String() != "215000.00" {
+		t.Errorf("LineExtensionAmount = %s, se esperaba 215000.00", total.LineExtensionAmount)
+	}
+	if total.TaxExclusiveAmount.String() != "195000.00" {
+		t.Errorf("TaxExclusiveAmount = %s, se esperaba 195000.00 (sin la línea excluida)", total.TaxExclusiveAmount)
+	}
+	if total.TaxInclusiveAmount.String() != "234000.00" {
+		t.Errorf("TaxInclusiveAmount = %s, se esperaba 234000.00", total.TaxInclusiveAmount)
+	}
+
+	// Un subtotal por tarifa: 19%, 0% y cada motivo de exención por separado
+	if len(inv.TaxTotal) != 1 {
+		t.Fatalf("TaxTotal tiene %d tributos, se esperaba 1", len(inv.TaxTotal))
+	}
+	expected := []struct{ taxable, reasonCode string }{
+		{taxable: "100000.00"},
+		{taxable: "55000.00", reasonCode: "01"},
+		{taxable: "30000.00"},
+		{taxable: "10000.00", reasonCode: "02"},
+	}
+	subtotals := inv.TaxTotal[0].TaxSubtotal
+	if len(subtotals) != len(expected) {
+		t.Fatalf("IVA tiene %d subtotales, se esperaban %d", len(subtotals), len(expected))
+	}
+	for idx, e := range expected {
+		if subtotals[idx].TaxableAmount.String() != e.taxable || subtotals[idx].TaxCategory.TaxExemptionReasonCode != e.reasonCode {
+			t.Errorf("subtotal %d: base %s motivo %q, se esperaba base %s motivo %q", idx,
+				subtotals[idx].TaxableAmount, subtotals[idx].TaxCategory.TaxExemptionReasonCode, e.taxable, e.reasonCode)
+		}
+	}
+	if inv.TaxTotal[0].TaxAmount.String() != "19000.00" {
+		t.Errorf("IVA = %s, se esperaba 19000.00", inv.TaxTotal[0].TaxAmount)
+	}
+	if err := inv.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+// Package packaging empaqueta documentos electrónicos en archivos ZIP
+// con la nomenclatura de nombres exigida por DIAN
+package packaging
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DocumentType define el prefijo de nombre de archivo según el tipo de documento
+type DocumentType string
+
+const (
+	Invoice             DocumentType = "fv" // Factura electrónica de venta
+	CreditNote          DocumentType = "nc" // Nota crédito
+	DebitNote           DocumentType = "nd" // Nota débito
+	AttachedDocument    DocumentType = "ad" // Contenedor electrónico (AttachedDocument)
+	ApplicationResponse DocumentType = "ar" // ApplicationResponse
+	Zip                 DocumentType = "z"  // Archivo ZIP
+)
+
+// maxConsecutive es el mayor consecutivo representable en 8 dígitos hexadecimales
+const maxConsecutive = 0xFFFFFFFF
+
+// FileName construye el nombre de archivo DIAN sin extensión:
+// prefijo + NIT (10 dígitos, sin DV) + PPP (código del proveedor tecnológico) + AA (año) + consecutivo hexadecimal de 8 dígitos
+func FileName(docType DocumentType, nit, providerCode string, year int, consecutive uint64) (string, error) {
+	paddedNIT, err := normalizeNIT(nit)
+	if err != nil {
+		return "", err
+	}
+
+	if len(providerCode) != 3 || !isDigits(providerCode) {
+		return "", fmt.Errorf("el código del proveedor tecnológico debe tener 3 dígitos: %q", providerCode)
+	}
+
+	if consecutive == 0 || consecutive > maxConsecutive {
+		return "", fmt.Errorf("consecutivo fuera de rango: %d", consecutive)
+	}
+
+	return fmt.Sprintf("%s%s%s%02d%08x", docType, paddedNIT, providerCode, year%100, consecutive), nil
+}
+
+// normalizeNIT elimina separadores y dígito de verificación, y completa con ceros a 10 dígitos
+func normalizeNIT(nit string) (string, error) {
+	nit = strings.ReplaceAll(nit, ".", "")
+	if i := strings.Index(nit, "-"); i >= 0 {
+		nit = nit[:i]
+	}
+	nit = strings.TrimSpace(nit)
+
+	if nit == "" || len(nit) > 10 || !isDigits(nit) {
+		return "", fmt.Errorf("NIT inválido para nombre de archivo: %q", nit)
+	}
+
+	return fmt.Sprintf("%010s", nit), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FileNameParts son los componentes de un nombre de archivo DIAN
+type FileNameParts struct {
+	Type         DocumentType
+	NIT          string // NIT del emisor, 10 dígitos con ceros a la izquierda
+	ProviderCode string
+	Year         int // Año en dos dígitos
+	Consecutive  uint64
+}
+
+// ParseFileName descompone un nombre de archivo DIAN, con o sin extensión .xml o .zip.
+// Retorna error si el nombre no cumple la nomenclatura de FileName.
+func ParseFileName(name string) (FileNameParts, error) {
+	base := strings.TrimSuffix(strings.TrimSuffix(name, ".xml"), ".zip")
+
+	var parts FileNameParts
+	for _, docType := range []DocumentType{Invoice, CreditNote, DebitNote, AttachedDocument, ApplicationResponse, Zip} {
+		if strings.HasPrefix(base, string(docType)) {
+			parts.Type = docType
+			break
+		}
+	}
+	rest := strings.TrimPrefix(base, string(parts.Type))
+	if parts.Type == "" || len(rest) != 23 {
+		return FileNameParts{}, fmt.Errorf("nombre de archivo fuera de la nomenclatura DIAN: %q", name)
+	}
+	if !isDigits(rest[:15]) {
+		return FileNameParts{}, fmt.Errorf("nombre de archivo fuera de la nomenclatura DIAN: %q", name)
+	}
+	consecutive, err := strconv.ParseUint(rest[15:], 16, 32)
+	if err != nil || consecutive == 0 || strings.ToLower(rest[15:]) != rest[15:] {
+		return FileNameParts{}, fmt.Errorf("consecutivo inválido en nombre de archivo: %q", name)
+	}
+	if (parts.Type == Zip) != strings.HasSuffix(name, ".zip") {
+		return FileNameParts{}, fmt.Errorf("extensión no corresponde al tipo de archivo: %q", name)
+	}
+
+	parts.NIT = rest[:10]
+	parts.ProviderCode = rest[10:13]
+	parts.Year, _ = strconv.Atoi(rest[13:15])
+	parts.Consecutive = consecutive
+	return parts, nil
+}
@@ -0,0 +1,121 @@
+package packaging
+
+import "testing"
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		name        string
+		docType     DocumentType
+		nit         string
+		provider    string
+		year        int
+		consecutive uint64
+		expected    string
+	}{
+		{name: "factura", docType: Invoice, nit: "900123456", provider: "000", year: 2025, consecutive: 1, expected: "fv09001234560002500000001"},
+		{name: "NIT con puntos y DV", docType: CreditNote, nit: "900.123.456-7", provider: "123", year: 2025, consecutive: 10, expected: "nc0900123456123250000000a"},
+		{name: "NIT de 10 dígitos", docType: DebitNote, nit: "1234567890", provider: "001", year: 2030, consecutive: 255, expected: "nd123456789000130000000ff"},
+		{name: "ZIP con año de dos dígitos", docType: Zip, nit: "8001", provider: "999", year: 2109, consecutive: maxConsecutive, expected: "z000000800199909ffffffff"},
+		{name: "AttachedDocument", docType: AttachedDocument, nit: " 900123456 ", provider: "000", year: 2025, consecutive: 0x1000, expected: "ad09001234560002500001000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FileName(tt.docType, tt.nit, tt.provider, tt.year, tt.consecutive)
+			if err != nil {
+				t.Fatalf("FileName: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("FileName = %s, se esperaba %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFileNameInvalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		nit         string
+		provider    string
+		consecutive uint64
+	}{
+		{name: "NIT vacío", nit: "", provider: "000", consecutive: 1},
+		{name: "NIT de 11 dígitos", nit: "12345678901", provider: "000", consecutive: 1},
+		{name: "NIT con letras", nit: "90012345A", provider: "000", consecutive: 1},
+		{name: "proveedor de 2 dígitos", nit: "900123456", provider: "00", consecutive: 1},
+		{name: "proveedor con letras", nit: "900123456", provider: "0A0", consecutive: 1},
+		{name: "consecutivo cero", nit: "900123456", provider: "000", consecutive: 0},
+		{name: "consecutivo de 9 dígitos hexadecimales", nit: "900123456", provider: "000", consecutive: maxConsecutive + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if name, err := FileName(Invoice, tt.nit, tt.provider, 2025, tt.consecutive); err == nil {
+				t.Errorf("FileName = %s, se esperaba error", name)
+			}
+		})
+	}
+}
+
+func TestParseFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected FileNameParts
+	}{
+		{name: "fv09001234560002500000001.xml", expected: FileNameParts{Type: Invoice, NIT: "0900123456", ProviderCode: "000", Year: 25, Consecutive: 1}},
+		{name: "nc0900123456123250000000a", expected: FileNameParts{Type: CreditNote, NIT: "0900123456", ProviderCode: "123", Year: 25, Consecutive: 10}},
+		{name: "ar09001234560002500000002.xml", expected: FileNameParts{Type: ApplicationResponse, NIT: "0900123456", ProviderCode: "000", Year: 25, Consecutive: 2}},
+		{name: "z000000800199909ffffffff.zip", expected: FileNameParts{Type: Zip, NIT: "0000008001", ProviderCode: "999", Year: 9, Consecutive: maxConsecutive}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := ParseFileName(tt.name)
+			if err != nil {
+				t.Fatalf("ParseFileName: %v", err)
+			}
+			if parts != tt.expected {
+				t.Errorf("ParseFileName = %+v, se esperaba %+v", parts, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseFileNameRoundTrip(t *testing.T) {
+	for _, docType := range []DocumentType{Invoice, CreditNote, DebitNote, AttachedDocument, ApplicationResponse} {
+		name, err := FileName(docType, "900123456", "042", 2025, 0xabc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts, err := ParseFileName(name + ".xml")
+		if err != nil {
+			t.Fatalf("ParseFileName(%s): %v", name, err)
+		}
+		if parts.Type != docType || parts.Consecutive != 0xabc || parts.ProviderCode != "042" {
+			t.Errorf("ParseFileName(%s) = %+v", name, parts)
+		}
+	}
+}
+
+func TestParseFileNameInvalid(t *testing.T) {
+	names := []string{
+		"",
+		"fv.xml",
+		"xx09001234560002500000001.xml",
+		"fv0900123456000250000001.xml",   // 7 dígitos de consecutivo
+		"fv090012345600025000000001.xml", // 9 dígitos de consecutivo
+		"fv09001234560002500000000.xml",  // consecutivo cero
+		"fv0900123456000250000000A.xml",  // hexadecimal en mayúscula
+		"fv0900123456000250000000g.xml",  // no hexadecimal
+		"fv09001234A60002500000001.xml",  // NIT con letras
+		"fv09001234560002500000001.zip",  // un XML con extensión .zip
+		"z09001234560002500000001.xml",   // un ZIP con extensión .xml
+		"z09001234560002500000001",       // un ZIP sin extensión
+	}
+
+	for _, name := range names {
+		if parts, err := ParseFileName(name); err == nil {
+			t.Errorf("ParseFileName(%q) = %+v, se esperaba error", name, parts)
+		}
+	}
+}
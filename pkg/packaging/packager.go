@@ -0,0 +1,154 @@
+package packaging
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Límites por defecto de los paquetes enviados a DIAN
+const (
+	DefaultMaxZipSize   = 2 * 1024 * 1024 // 2 MB
+	DefaultMaxDocuments = 50              // documentos por ZIP en envíos por lote
+)
+
+// Error types
+var (
+	ErrNoDocuments         = errors.New("el paquete no contiene documentos")
+	ErrTooManyDocuments    = errors.New("el paquete excede el número máximo de documentos")
+	ErrPackageTooLarge     = errors.New("el paquete excede el tamaño máximo permitido")
+	ErrInvalidDocumentType = errors.New("tipo de documento inválido")
+)
+
+// Document representa un documento XML firmado a empaquetar
+type Document struct {
+	Type DocumentType
+	XML  []byte
+}
+
+// Package representa un ZIP listo para enviar a DIAN
+type Package struct {
+	ZipName   string   // Nombre del ZIP, ej: z0900123456000250000000a.zip
+	FileNames []string // Nombres de los XML dentro del ZIP
+	Data      []byte   // Contenido del ZIP
+}
+
+// Packager construye ZIPs con la nomenclatura DIAN para un emisor
+type Packager struct {
+	NIT          string   // NIT del emisor (sin DV)
+	ProviderCode string   // Código PPP del proveedor tecnológico ("000" si es software propio)
+	Sequence     Sequence // Consecutivo anual de nombres de archivo
+	MaxZipSize   int64
+	MaxDocuments int
+	Now          func() time.Time
+}
+
+// NewPackager crea un Packager con secuencia en memoria y límites por defecto
+func NewPackager(nit, providerCode string) *Packager {
+	if providerCode == "" {
+		providerCode = "000"
+	}
+	return &Packager{
+		NIT:          nit,
+		ProviderCode: providerCode,
+		Sequence:     NewMemorySequence(),
+		MaxZipSize:   DefaultMaxZipSize,
+		MaxDocuments: DefaultMaxDocuments,
+		Now:          time.Now,
+	}
+}
+
+// Package empaqueta un único documento (SendBillSync)
+func (p *Packager) Package(docType DocumentType, xmlData []byte) (*Package, error) {
+	return p.PackageBatch([]Document{{Type: docType, XML: xmlData}})
+}
+
+// PackageBatch empaqueta varios documentos en un mismo ZIP (SendBillAsync / SendTestSetAsync).
+// Los tipos de documento, el NIT y el tamaño se validan antes de tomar consecutivos de Sequence,
+// para que un paquete rechazado no deje huecos en la numeración.
+func (p *Packager) PackageBatch(docs []Document) (*Package, error) {
+	if len(docs) == 0 {
+		return nil, ErrNoDocuments
+	}
+	if p.MaxDocuments > 0 && len(docs) > p.MaxDocuments {
+		return nil, fmt.Errorf("%w: %d (máximo %d)", ErrTooManyDocuments, len(docs), p.MaxDocuments)
+	}
+	for _, doc := range docs {
+		if doc.Type == "" || doc.Type == Zip {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidDocumentType, doc.Type)
+		}
+	}
+
+	year := p.Now().Year()
+
+	// El consecutivo siempre ocupa 8 dígitos hexadecimales, así que el tamaño del ZIP no depende
+	// de él: se mide con nombres provisionales antes de consumir consecutivos
+	names := make([]string, len(docs))
+	for i, doc := range docs {
+		name, err := FileName(doc.Type, p.NIT, p.ProviderCode, year, 1)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = name + ".xml"
+	}
+	data, err := writeZip(names, docs)
+	if err != nil {
+		return nil, err
+	}
+	if p.MaxZipSize > 0 && int64(len(data)) > p.MaxZipSize {
+		return nil, fmt.Errorf("%w: %d bytes (máximo %d)", ErrPackageTooLarge, len(data), p.MaxZipSize)
+	}
+
+	for i, doc := range docs {
+		name, err := p.nextName(doc.Type, year)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = name + ".xml"
+	}
+	zipName, err := p.nextName(Zip, year)
+	if err != nil {
+		return nil, err
+	}
+	data, err = writeZip(names, docs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Package{
+		ZipName:   zipName + ".zip",
+		FileNames: names,
+		Data:      data,
+	}, nil
+}
+
+// writeZip escribe cada documento en el ZIP con el nombre correspondiente de names
+func writeZip(names []string, docs []Document) ([]byte, error) {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+
+	for i, doc := range docs {
+		fileWriter, err := zipWriter.Create(names[i])
+		if err != nil {
+			return nil, fmt.Errorf("error creando archivo en ZIP: %w", err)
+		}
+		if _, err := fileWriter.Write(doc.XML); err != nil {
+			return nil, fmt.Errorf("error escribiendo contenido: %w", err)
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("error cerrando ZIP: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *Packager) nextName(docType DocumentType, year int) (string, error) {
+	consecutive, err := p.Sequence.Next(docType, year)
+	if err != nil {
+		return "", err
+	}
+	return FileName(docType, p.NIT, p.ProviderCode, year, consecutive)
+}
@@ -0,0 +1,212 @@
+package packaging
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestPackager crea un Packager con el reloj fijo en 2025
+func newTestPackager() *Packager {
+	p := NewPackager("900123456", "")
+	p.Now = func() time.Time { return time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC) }
+	return p
+}
+
+// readZip retorna el contenido de cada archivo del ZIP por nombre
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestPackageBatch(t *testing.T) {
+	p := newTestPackager()
+
+	pkg, err := p.PackageBatch([]Document{
+		{Type: Invoice, XML: []byte("<Invoice/>")},
+		{Type: Invoice, XML: []byte("<Invoice>2</Invoice>")},
+		{Type: CreditNote, XML: []byte("<CreditNote/>")},
+	})
+	if err != nil {
+		t.Fatalf("PackageBatch: %v", err)
+	}
+
+	expectedNames := []string{
+		"fv09001234560002500000001.xml",
+		"fv09001234560002500000002.xml",
+		"nc09001234560002500000001.xml",
+	}
+	if pkg.ZipName != "z09001234560002500000001.zip" {
+		t.Errorf("ZipName = %s", pkg.ZipName)
+	}
+	files := readZip(t, pkg.Data)
+	for i, name := range expectedNames {
+		if pkg.FileNames[i] != name {
+			t.Errorf("FileNames[%d] = %s, se esperaba %s", i, pkg.FileNames[i], name)
+		}
+	}
+	if files["fv09001234560002500000002.xml"] != "<Invoice>2</Invoice>" || files["nc09001234560002500000001.xml"] != "<CreditNote/>" || len(files) != 3 {
+		t.Errorf("contenido del ZIP = %v", files)
+	}
+
+	// Los consecutivos continúan en el siguiente paquete
+	next, err := p.Package(Invoice, []byte("<Invoice/>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.FileNames[0] != "fv09001234560002500000003.xml" || next.ZipName != "z09001234560002500000002.zip" {
+		t.Errorf("siguiente paquete = %s, %v", next.ZipName, next.FileNames)
+	}
+}
+
+func TestPackageBatchRejectsWithoutConsumingConsecutives(t *testing.T) {
+	random := make([]byte, 4096)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		configure func(p *Packager)
+		docs      []Document
+		err       error
+	}{
+		{name: "sin documentos", err: ErrNoDocuments},
+		{
+			name:      "demasiados documentos",
+			configure: func(p *Packager) { p.MaxDocuments = 1 },
+			docs:      []Document{{Type: Invoice}, {Type: Invoice}},
+			err:       ErrTooManyDocuments,
+		},
+		{
+			name: "tipo vacío después de un documento válido",
+			docs: []Document{{Type: Invoice, XML: []byte("<Invoice/>")}, {XML: []byte("<Invoice/>")}},
+			err:  ErrInvalidDocumentType,
+		},
+		{
+			name: "documento con tipo ZIP",
+			docs: []Document{{Type: Zip, XML: []byte("<Invoice/>")}},
+			err:  ErrInvalidDocumentType,
+		},
+		{
+			name:      "ZIP demasiado grande",
+			configure: func(p *Packager) { p.MaxZipSize = 1024 },
+			docs:      []Document{{Type: Invoice, XML: random}},
+			err:       ErrPackageTooLarge,
+		},
+		{
+			name:      "NIT inválido",
+			configure: func(p *Packager) { p.NIT = "NIT" },
+			docs:      []Document{{Type: Invoice, XML: []byte("<Invoice/>")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPackager()
+			if tt.configure != nil {
+				tt.configure(p)
+			}
+
+			_, err := p.PackageBatch(tt.docs)
+			if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("error = %v, se esperaba %v", err, tt.err)
+			}
+
+			// Ningún consecutivo se consumió: el próximo de cada tipo sigue siendo 1
+			for _, docType := range []DocumentType{Invoice, Zip} {
+				if next, _ := p.Sequence.Next(docType, 2025); next != 1 {
+					t.Errorf("el paquete rechazado consumió consecutivos de %s: siguiente = %d", docType, next)
+				}
+			}
+		})
+	}
+}
+
+func TestPackageBatchSizeLimit(t *testing.T) {
+	docs := []Document{{Type: Invoice, XML: bytes.Repeat([]byte("<cbc:Note>Factura</cbc:Note>"), 100)}}
+
+	unlimited := newTestPackager()
+	unlimited.MaxZipSize = 0
+	pkg, err := unlimited.PackageBatch(docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len(pkg.Data))
+
+	// El tamaño medido con nombres provisionales es el del ZIP final: el límite exacto se acepta
+	p := newTestPackager()
+	p.MaxZipSize = size
+	if _, err := p.PackageBatch(docs); err != nil {
+		t.Errorf("ZIP de %d bytes con límite %d: %v", size, size, err)
+	}
+	p.MaxZipSize = size - 1
+	if _, err := p.PackageBatch(docs); !errors.Is(err, ErrPackageTooLarge) {
+		t.Errorf("ZIP de %d bytes con límite %d: error = %v", size, size-1, err)
+	}
+}
+
+func TestSequenceLimits(t *testing.T) {
+	memory := NewMemorySequence()
+	memory.Set(Invoice, 2025, maxConsecutive-1)
+
+	files := NewFileSequence(filepath.Join(t.TempDir(), "secuencia.json"))
+	if err := files.Set(Invoice, 2025, maxConsecutive-1); err != nil {
+		t.Fatal(err)
+	}
+
+	sequences := map[string]Sequence{"MemorySequence": memory, "FileSequence": files}
+	for name, seq := range sequences {
+		t.Run(name, func(t *testing.T) {
+			if next, err := seq.Next(Invoice, 2025); err != nil || next != maxConsecutive {
+				t.Fatalf("Next = %d, %v; se esperaba %d", next, err, uint64(maxConsecutive))
+			}
+			if next, err := seq.Next(Invoice, 2025); err == nil {
+				t.Errorf("Next = %d después del último consecutivo, se esperaba error", next)
+			}
+			// Otro año y otro tipo de documento tienen su propio consecutivo
+			if next, err := seq.Next(Invoice, 2026); err != nil || next != 1 {
+				t.Errorf("Next(2026) = %d, %v", next, err)
+			}
+			if next, err := seq.Next(CreditNote, 2025); err != nil || next != 1 {
+				t.Errorf("Next(nc) = %d, %v", next, err)
+			}
+		})
+	}
+}
+
+func TestFileSequencePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secuencia.json")
+
+	for i := uint64(1); i <= 3; i++ {
+		next, err := NewFileSequence(path).Next(Invoice, 2025)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next != i {
+			t.Errorf("Next = %d, se esperaba %d", next, i)
+		}
+	}
+}
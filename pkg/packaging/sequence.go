@@ -0,0 +1,157 @@
+package packaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Sequence entrega el consecutivo anual de nombres de archivo por tipo de documento.
+// El consecutivo se reinicia cada año y no debe repetirse para el mismo emisor.
+type Sequence interface {
+	Next(docType DocumentType, year int) (uint64, error)
+}
+
+// MemorySequence es una Sequence en memoria, segura para uso concurrente.
+// Reinicia en 1 en cada proceso: en producción use FileSequence, Set al iniciar o una
+// implementación propia respaldada por la base de datos.
+type MemorySequence struct {
+	mu       sync.Mutex
+	counters map[sequenceKey]uint64
+}
+
+type sequenceKey struct {
+	docType DocumentType
+	year    int
+}
+
+// NewMemorySequence crea una secuencia en memoria que inicia en 1
+func NewMemorySequence() *MemorySequence {
+	return &MemorySequence{
+		counters: make(map[sequenceKey]uint64),
+	}
+}
+
+// Next retorna el siguiente consecutivo para el tipo de documento y año
+func (s *MemorySequence) Next(docType DocumentType, year int) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := sequenceKey{docType: docType, year: year}
+	if s.counters[key] >= maxConsecutive {
+		return 0, fmt.Errorf("consecutivo agotado para %s en %d", docType, year)
+	}
+	s.counters[key]++
+	return s.counters[key], nil
+}
+
+// Set fija el último consecutivo usado para el tipo de documento y año
+func (s *MemorySequence) Set(docType DocumentType, year int, last uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[sequenceKey{docType: docType, year: year}] = last
+}
+
+// FileSequence es una Sequence persistida en un archivo JSON, para que el consecutivo
+// sobreviva a reinicios del proceso. Es segura para uso concurrente dentro de un proceso;
+// varios procesos no deben compartir el mismo archivo.
+type FileSequence struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileSequence crea una secuencia persistida en path. Si el archivo no existe,
+// el consecutivo inicia en 1 y el archivo se crea en el primer Next.
+func NewFileSequence(path string) *FileSequence {
+	return &FileSequence{path: path}
+}
+
+// Next retorna el siguiente consecutivo y lo persiste antes de retornarlo
+func (s *FileSequence) Next(docType DocumentType, year int) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counters, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+
+	key := fileSequenceKey(docType, year)
+	if counters[key] >= maxConsecutive {
+		return 0, fmt.Errorf("consecutivo agotado para %s en %d", docType, year)
+	}
+	counters[key]++
+	if err := s.save(counters); err != nil {
+		return 0, err
+	}
+	return counters[key], nil
+}
+
+// Set fija el último consecutivo usado para el tipo de documento y año
+func (s *FileSequence) Set(docType DocumentType, year int, last uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counters, err := s.load()
+	if err != nil {
+		return err
+	}
+	counters[fileSequenceKey(docType, year)] = last
+	return s.save(counters)
+}
+
+// fileSequenceKey retorna la llave del consecutivo en el archivo, ej: "fv-2025"
+func fileSequenceKey(docType DocumentType, year int) string {
+	return fmt.Sprintf("%s-%d", docType, year)
+}
+
+// load lee los consecutivos del archivo; un archivo inexistente equivale a una secuencia vacía
+func (s *FileSequence) load() (map[string]uint64, error) {
+	counters := make(map[string]uint64)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return counters, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo secuencia: %w", err)
+	}
+	if err := json.Unmarshal(data, &counters); err != nil {
+		return nil, fmt.Errorf("error parseando secuencia %s: %w", s.path, err)
+	}
+	return counters, nil
+}
+
+// save escribe los consecutivos en un archivo temporal y lo renombra, para que una
+// interrupción no deje el archivo truncado
+func (s *FileSequence) save(counters map[string]uint64) error {
+	data, err := json.MarshalIndent(counters, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializando secuencia: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("error guardando secuencia: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error guardando secuencia: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error guardando secuencia: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error guardando secuencia: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("error guardando secuencia: %w", err)
+	}
+	return nil
+}
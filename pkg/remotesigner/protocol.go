@@ -0,0 +1,70 @@
+// Package remotesigner implementa un crypto.Signer que delega la firma a un servicio HTTP
+// (HSM, KMS o servicio interno de firma), de modo que la clave privada nunca sale del servicio.
+//
+// Protocolo (JSON sobre HTTP):
+//
+//	POST {URL}/sign
+//	{"key_id": "...", "algorithm": "RSA-PKCS1v15-SHA256", "digest": "<base64>"}
+//	-> 200 {"signature": "<base64>"}
+//	-> 4xx/5xx {"error": "..."}
+//
+// El cliente solo envía el digest, nunca el documento. Algoritmos soportados:
+// RSA-PKCS1v15-SHA256/384/512 (firma XAdES y WS-Security) y RSA-PSS-SHA256/384/512 (handshake TLS 1.3).
+package remotesigner
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"fmt"
+)
+
+// signRequest es el cuerpo de POST /sign
+type signRequest struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	Digest    string `json:"digest"`
+}
+
+// signResponse es la respuesta de POST /sign
+type signResponse struct {
+	Signature string `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// algorithmName retorna el nombre de algoritmo del protocolo para las opciones de crypto.Signer
+func algorithmName(opts crypto.SignerOpts) (string, error) {
+	var hashName string
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		hashName = "SHA256"
+	case crypto.SHA384:
+		hashName = "SHA384"
+	case crypto.SHA512:
+		hashName = "SHA512"
+	default:
+		return "", fmt.Errorf("hash no soportado: %v", opts.HashFunc())
+	}
+
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return "RSA-PSS-" + hashName, nil
+	}
+	return "RSA-PKCS1v15-" + hashName, nil
+}
+
+// parseAlgorithm es la operación inversa de algorithmName
+func parseAlgorithm(name string) (crypto.SignerOpts, error) {
+	hashes := map[string]crypto.Hash{
+		"SHA256": crypto.SHA256,
+		"SHA384": crypto.SHA384,
+		"SHA512": crypto.SHA512,
+	}
+	for suffix, hash := range hashes {
+		switch name {
+		case "RSA-PKCS1v15-" + suffix:
+			return hash, nil
+		case "RSA-PSS-" + suffix:
+			return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}, nil
+		}
+	}
+	return nil, fmt.Errorf("algoritmo no soportado: %s", name)
+}
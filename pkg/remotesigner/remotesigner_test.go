@@ -0,0 +1,198 @@
+package remotesigner
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diegofxm/go-dian/pkg/signature"
+)
+
+const testDocument = `<Invoice xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2" xmlns:ext="urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2" xmlns:cbc="urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2">` +
+	`<ext:UBLExtensions><ext:UBLExtension><ext:ExtensionContent></ext:ExtensionContent></ext:UBLExtension></ext:UBLExtensions>` +
+	`<cbc:ID>SETP990000001</cbc:ID></Invoice>`
+
+// newTestKey crea una clave RSA y su certificado autofirmado
+func newTestKey(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "EMISOR DE PRUEBA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, cert
+}
+
+// newTestService inicia un Handler con la clave indicada bajo el KeyID "firma"
+func newTestService(t *testing.T, key crypto.Signer, token string) *httptest.Server {
+	t.Helper()
+	handler := NewHandler("firma", key)
+	handler.Token = token
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestSigner(t *testing.T, config Config) *Signer {
+	t.Helper()
+	signer, err := NewSigner(config)
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	return signer
+}
+
+func TestSignXAdESRemotely(t *testing.T) {
+	key, cert := newTestKey(t)
+	server := newTestService(t, key, "secreto")
+	signer := newTestSigner(t, Config{URL: server.URL + "/", KeyID: "firma", Certificate: cert, Token: "secreto"})
+
+	signed, err := signature.SignDocument([]byte(testDocument), cert, signer, signature.SignOptions{})
+	if err != nil {
+		t.Fatalf("SignDocument: %v", err)
+	}
+
+	report, err := signature.Verify(signed)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !report.Valid {
+		t.Fatalf("firma inválida: %v", report.Errors)
+	}
+	if !report.Certificate.Equal(cert) {
+		t.Error("el certificado de la firma no es el del signer remoto")
+	}
+}
+
+func TestSignAuthorization(t *testing.T) {
+	key, cert := newTestKey(t)
+	server := newTestService(t, key, "secreto")
+	digest := sha256.Sum256([]byte("documento"))
+
+	for _, token := range []string{"", "otro"} {
+		signer := newTestSigner(t, Config{URL: server.URL, KeyID: "firma", Certificate: cert, Token: token})
+		_, err := signer.Sign(nil, digest[:], crypto.SHA256)
+		if err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+			t.Errorf("token %q: error = %v", token, err)
+		}
+	}
+}
+
+func TestSignKeyMismatch(t *testing.T) {
+	_, cert := newTestKey(t)
+	otherKey, _ := newTestKey(t)
+	server := newTestService(t, otherKey, "")
+	signer := newTestSigner(t, Config{URL: server.URL, KeyID: "firma", Certificate: cert})
+
+	digest := sha256.Sum256([]byte("documento"))
+	_, err := signer.Sign(nil, digest[:], crypto.SHA256)
+	if err == nil || !strings.Contains(err.Error(), "no corresponde al certificado") {
+		t.Fatalf("error = %v", err)
+	}
+
+	_, err = signature.SignDocument([]byte(testDocument), cert, signer, signature.SignOptions{})
+	if err == nil {
+		t.Error("SignDocument aceptó una firma de otra clave")
+	}
+}
+
+func TestSignAlgorithms(t *testing.T) {
+	key, cert := newTestKey(t)
+	server := newTestService(t, key, "")
+	signer := newTestSigner(t, Config{URL: server.URL, KeyID: "firma", Certificate: cert})
+
+	sha256Digest := sha256.Sum256([]byte("documento"))
+	sha384Digest := sha512.Sum384([]byte("documento"))
+	sha512Digest := sha512.Sum512([]byte("documento"))
+
+	tests := []struct {
+		name   string
+		digest []byte
+		opts   crypto.SignerOpts
+	}{
+		{name: "PKCS#1 v1.5 SHA-256", digest: sha256Digest[:], opts: crypto.SHA256},
+		{name: "PKCS#1 v1.5 SHA-384", digest: sha384Digest[:], opts: crypto.SHA384},
+		{name: "PKCS#1 v1.5 SHA-512", digest: sha512Digest[:], opts: crypto.SHA512},
+		{name: "PSS SHA-256", digest: sha256Digest[:], opts: &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}},
+		{name: "PSS SHA-512", digest: sha512Digest[:], opts: &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := signer.Sign(nil, tt.digest, tt.opts)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+
+			// El servicio debe usar el esquema solicitado: una firma PSS no es PKCS#1 v1.5 ni al revés
+			pss, isPSS := tt.opts.(*rsa.PSSOptions)
+			pkcs1Err := rsa.VerifyPKCS1v15(&key.PublicKey, tt.opts.HashFunc(), tt.digest, sig)
+			pssErr := rsa.VerifyPSS(&key.PublicKey, tt.opts.HashFunc(), tt.digest, sig, pss)
+			if isPSS && (pssErr != nil || pkcs1Err == nil) {
+				t.Errorf("se esperaba una firma PSS: PSS=%v, PKCS#1 v1.5=%v", pssErr, pkcs1Err)
+			}
+			if !isPSS && (pkcs1Err != nil || pssErr == nil) {
+				t.Errorf("se esperaba una firma PKCS#1 v1.5: PKCS#1 v1.5=%v, PSS=%v", pkcs1Err, pssErr)
+			}
+		})
+	}
+}
+
+func TestSignErrors(t *testing.T) {
+	key, cert := newTestKey(t)
+	server := newTestService(t, key, "")
+	digest := sha256.Sum256([]byte("documento"))
+
+	tests := []struct {
+		name   string
+		keyID  string
+		digest []byte
+		opts   crypto.SignerOpts
+		err    string
+	}{
+		{name: "clave inexistente", keyID: "otra", digest: digest[:], opts: crypto.SHA256, err: "HTTP 404: clave no encontrada: otra"},
+		{name: "hash no soportado", keyID: "firma", digest: digest[:20], opts: crypto.SHA1, err: "hash no soportado"},
+		{name: "digest de otra longitud", keyID: "firma", digest: digest[:20], opts: crypto.SHA256, err: "longitud de digest inválida"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := newTestSigner(t, Config{URL: server.URL, KeyID: tt.keyID, Certificate: cert})
+			_, err := signer.Sign(nil, tt.digest, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, se esperaba %q", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := NewSigner(Config{Certificate: cert}); err == nil {
+		t.Error("NewSigner aceptó una configuración sin URL")
+	}
+	if _, err := NewSigner(Config{URL: server.URL}); err == nil {
+		t.Error("NewSigner aceptó una configuración sin certificado")
+	}
+}
@@ -0,0 +1,69 @@
+package remotesigner
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// Handler es una implementación de referencia del servicio de firma. Sirve como
+// sustituto local en pruebas y como punto de partida para un servicio respaldado por HSM o KMS.
+type Handler struct {
+	Keys  map[string]crypto.Signer // Claves disponibles por KeyID
+	Token string                   // Si no está vacío, se exige "Authorization: Bearer <Token>"
+}
+
+// NewHandler crea un Handler con una única clave
+func NewHandler(keyID string, signer crypto.Signer) *Handler {
+	return &Handler{
+		Keys: map[string]crypto.Signer{keyID: signer},
+	}
+}
+
+// ServeHTTP atiende POST /sign
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/sign" {
+		writeJSON(w, http.StatusNotFound, signResponse{Error: "ruta no encontrada"})
+		return
+	}
+	if h.Token != "" && r.Header.Get("Authorization") != "Bearer "+h.Token {
+		writeJSON(w, http.StatusUnauthorized, signResponse{Error: "no autorizado"})
+		return
+	}
+
+	var req signRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, signResponse{Error: "request inválido"})
+		return
+	}
+	signer, ok := h.Keys[req.KeyID]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, signResponse{Error: "clave no encontrada: " + req.KeyID})
+		return
+	}
+	opts, err := parseAlgorithm(req.Algorithm)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, signResponse{Error: err.Error()})
+		return
+	}
+	digest, err := base64.StdEncoding.DecodeString(req.Digest)
+	if err != nil || len(digest) != opts.HashFunc().Size() {
+		writeJSON(w, http.StatusBadRequest, signResponse{Error: "digest inválido"})
+		return
+	}
+
+	signature, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, signResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, signResponse{Signature: base64.StdEncoding.EncodeToString(signature)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body signResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,118 @@
+package remotesigner
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Config configura el cliente del servicio de firma
+type Config struct {
+	URL         string            // URL base del servicio (sin /sign)
+	KeyID       string            // Identificador de la clave en el servicio
+	Certificate *x509.Certificate // Certificado de la clave; de él se toma la llave pública
+	Token       string            // Bearer token opcional para autenticarse ante el servicio
+	HTTPClient  *http.Client      // Por defecto un cliente con timeout de 30 segundos
+}
+
+// Signer es un crypto.Signer cuya clave privada vive en un servicio remoto
+type Signer struct {
+	config    Config
+	publicKey *rsa.PublicKey
+}
+
+// NewSigner crea un Signer remoto para la clave del certificado indicado
+func NewSigner(config Config) (*Signer, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("URL del servicio de firma requerida")
+	}
+	if config.Certificate == nil {
+		return nil, fmt.Errorf("certificado requerido")
+	}
+	publicKey, ok := config.Certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("la llave pública del certificado no es RSA")
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+
+	return &Signer{
+		config:    config,
+		publicKey: publicKey,
+	}, nil
+}
+
+// Public retorna la llave pública del certificado
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign envía el digest al servicio y verifica la firma recibida contra la llave pública,
+// de modo que una clave equivocada en el servicio se detecta antes de enviar a DIAN
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	algorithm, err := algorithmName(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(digest) != opts.HashFunc().Size() {
+		return nil, fmt.Errorf("longitud de digest inválida para %s", algorithm)
+	}
+
+	body, err := json.Marshal(signRequest{
+		KeyID:     s.config.KeyID,
+		Algorithm: algorithm,
+		Digest:    base64.StdEncoding.EncodeToString(digest),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.config.URL+"/sign", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creando request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.Token)
+	}
+
+	resp, err := s.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error contactando servicio de firma: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result signResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("respuesta inválida del servicio de firma (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("servicio de firma respondió HTTP %d: %s", resp.StatusCode, result.Error)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(result.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("firma en base64 inválida: %w", err)
+	}
+
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		err = rsa.VerifyPSS(s.publicKey, opts.HashFunc(), digest, signature, pss)
+	} else {
+		err = rsa.VerifyPKCS1v15(s.publicKey, opts.HashFunc(), digest, signature)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("la firma del servicio no corresponde al certificado: %w", err)
+	}
+
+	return signature, nil
+}
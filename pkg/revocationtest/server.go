@@ -0,0 +1,221 @@
+// Package revocationtest provee una entidad certificadora local con responder OCSP y
+// punto de distribución de CRL, para probar la verificación de revocación sin red.
+package revocationtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Server es una entidad certificadora de prueba. Los certificados emitidos con Issue
+// apuntan su AIA (OCSP) y CRLDistributionPoints a este servidor.
+type Server struct {
+	URL string
+	CA  *x509.Certificate
+
+	Now      func() time.Time
+	Validity time.Duration // Vigencia (nextUpdate) de respuestas OCSP y CRL. Por defecto 1 hora
+	OCSPDown bool          // El responder OCSP responde HTTP 503 (para probar el respaldo por CRL)
+	CRLDown  bool          // El punto de distribución de CRL responde HTTP 503
+
+	caKey     *rsa.PrivateKey
+	srv       *httptest.Server
+	mu        sync.Mutex
+	serial    int64
+	crlNumber int64
+	revoked   []x509.RevocationListEntry
+	ocspCount int
+	crlCount  int
+}
+
+// NewServer inicia la entidad certificadora de prueba
+func NewServer() (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "revocationtest CA", Organization: []string{"Entidad de Certificación de Prueba"}, Country: []string{"CO"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		CA:       ca,
+		Now:      time.Now,
+		Validity: time.Hour,
+		caKey:    key,
+		serial:   1,
+	}
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	return s, nil
+}
+
+// Close detiene el servidor
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Issue emite un certificado de firma con AIA y CRLDistributionPoints apuntando al servidor
+func (s *Server) Issue(commonName string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	s.serial++
+	serial := big.NewInt(s.serial)
+	s.mu.Unlock()
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(12 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		OCSPServer:            []string{s.URL + "/ocsp"},
+		CRLDistributionPoints: []string{s.URL + "/crl"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, s.CA, &key.PublicKey, s.caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// Revoke revoca un certificado con el código de razón RFC 5280 indicado (ej: ocsp.KeyCompromise)
+func (s *Server) Revoke(cert *x509.Certificate, reason int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked = append(s.revoked, x509.RevocationListEntry{
+		SerialNumber:   cert.SerialNumber,
+		RevocationTime: s.Now().Add(-time.Second).UTC().Truncate(time.Second),
+		ReasonCode:     reason,
+	})
+}
+
+// CRL retorna la CRL vigente en DER (para pruebas en modo offline)
+func (s *Server) CRL() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.crlNumber++
+	now := s.Now()
+	return x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(s.crlNumber),
+		ThisUpdate:                now.Add(-time.Minute),
+		NextUpdate:                now.Add(s.Validity),
+		RevokedCertificateEntries: append([]x509.RevocationListEntry(nil), s.revoked...),
+	}, s.CA, s.caKey)
+}
+
+// Requests retorna cuántas consultas OCSP y descargas de CRL ha recibido el servidor
+func (s *Server) Requests() (ocspRequests, crlRequests int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ocspCount, s.crlCount
+}
+
+// ServeHTTP atiende POST /ocsp y GET /crl
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/ocsp":
+		s.mu.Lock()
+		s.ocspCount++
+		s.mu.Unlock()
+		if s.OCSPDown {
+			http.Error(w, "OCSP no disponible", http.StatusServiceUnavailable)
+			return
+		}
+		s.serveOCSP(w, r)
+
+	case r.Method == http.MethodGet && r.URL.Path == "/crl":
+		s.mu.Lock()
+		s.crlCount++
+		s.mu.Unlock()
+		if s.CRLDown {
+			http.Error(w, "CRL no disponible", http.StatusServiceUnavailable)
+			return
+		}
+		crl, err := s.CRL()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(crl)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveOCSP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<16))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := ocsp.ParseRequest(body)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(ocsp.MalformedRequestErrorResponse)
+		return
+	}
+
+	now := s.Now()
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   now.Add(-time.Minute),
+		NextUpdate:   now.Add(s.Validity),
+	}
+	s.mu.Lock()
+	if req.SerialNumber.Cmp(big.NewInt(s.serial)) > 0 {
+		template.Status = ocsp.Unknown
+	}
+	for _, entry := range s.revoked {
+		if entry.SerialNumber.Cmp(req.SerialNumber) == 0 {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = entry.RevocationTime
+			template.RevocationReason = entry.ReasonCode
+		}
+	}
+	s.mu.Unlock()
+
+	resp, err := ocsp.CreateResponse(s.CA, s.CA, template, s.caKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
@@ -1,55 +1,238 @@
 package signature
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 )
 
 type CertificateManager struct {
 	Certificate *x509.Certificate
-	PrivateKey  *rsa.PrivateKey
+	Chain       []*x509.Certificate // Certificados intermedios (y raíz) de la entidad certificadora
+	PrivateKey  *rsa.PrivateKey     // Clave local; nil cuando la clave vive en un HSM o KMS
+	Signer      crypto.Signer       // Firmador externo (HSM, KMS, servicio remoto); tiene prioridad sobre PrivateKey
 }
 
+// NewCertificateManager crea un CertificateManager desde un archivo PEM.
+// El archivo puede incluir, además del certificado y la clave, la cadena de la entidad certificadora.
 func NewCertificateManager(certPath string) (*CertificateManager, error) {
-	cert, key, err := LoadCertificate(certPath)
+	cert, chain, key, err := loadFromPEM(certPath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error cargando certificado: %w", err)
 	}
 
 	return &CertificateManager{
 		Certificate: cert,
+		Chain:       chain,
 		PrivateKey:  key,
 	}, nil
 }
 
-// NewCertManagerFromPEM crea un CertificateManager desde strings PEM
+// NewCertManagerFromPEM crea un CertificateManager desde strings PEM.
+// certPEM puede contener el certificado seguido de los certificados intermedios.
 func NewCertManagerFromPEM(certPEM, keyPEM string) (*CertificateManager, error) {
-	cert, key, err := LoadPEMStrings(certPEM, keyPEM)
+	return NewCertManagerFromEncryptedPEM(certPEM, keyPEM, nil)
+}
+
+// NewCertificateManagerWithPassword crea un CertificateManager desde un archivo PEM
+// cuya clave privada está cifrada ("ENCRYPTED PRIVATE KEY")
+func NewCertificateManagerWithPassword(certPath string, password PasswordFunc) (*CertificateManager, error) {
+	cert, chain, key, err := loadFromPEM(certPath, password)
+	if err != nil {
+		return nil, fmt.Errorf("error cargando certificado: %w", err)
+	}
+
+	return &CertificateManager{
+		Certificate: cert,
+		Chain:       chain,
+		PrivateKey:  key,
+	}, nil
+}
+
+// NewCertManagerFromEncryptedPEM crea un CertificateManager desde strings PEM
+// cuya clave privada puede estar cifrada; password solo se invoca si la clave está cifrada
+func NewCertManagerFromEncryptedPEM(certPEM, keyPEM string, password PasswordFunc) (*CertificateManager, error) {
+	cert, chain, key, err := parsePEMData([]byte(certPEM+"\n"+keyPEM), password)
 	if err != nil {
 		return nil, fmt.Errorf("error cargando certificado PEM: %w", err)
 	}
 
 	return &CertificateManager{
 		Certificate: cert,
+		Chain:       chain,
 		PrivateKey:  key,
 	}, nil
 }
 
+// NewCertManagerFromPKCS12File crea un CertificateManager desde un archivo .p12/.pfx
+func NewCertManagerFromPKCS12File(path, password string) (*CertificateManager, error) {
+	cert, chain, key, err := LoadPKCS12(path, password)
+	if err != nil {
+		return nil, fmt.Errorf("error cargando certificado PKCS#12: %w", err)
+	}
+
+	return &CertificateManager{
+		Certificate: cert,
+		Chain:       chain,
+		PrivateKey:  key,
+	}, nil
+}
+
+// NewCertManagerFromPKCS12 crea un CertificateManager desde el contenido de un .p12/.pfx (ej: almacenado en BD)
+func NewCertManagerFromPKCS12(data []byte, password string) (*CertificateManager, error) {
+	cert, chain, key, err := ParsePKCS12(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("error cargando certificado PKCS#12: %w", err)
+	}
+
+	return &CertificateManager{
+		Certificate: cert,
+		Chain:       chain,
+		PrivateKey:  key,
+	}, nil
+}
+
+// NewCertManagerWithSigner crea un CertificateManager cuya clave privada no está disponible localmente
+// (HSM, KMS o servicio de firma remoto). certPEM contiene el certificado y opcionalmente su cadena;
+// el certificado firmante es el que corresponde a la llave pública del signer.
+func NewCertManagerWithSigner(certPEM string, signer crypto.Signer) (*CertificateManager, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("signer no configurado")
+	}
+
+	var certs []*x509.Certificate
+	rest := []byte(certPEM)
+	for {
+		block, remaining := pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		rest = remaining
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parseando certificado: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("certificado no encontrado en PEM")
+	}
+
+	cert, chain := splitChain(certs, signer.Public())
+	cm := &CertificateManager{
+		Certificate: cert,
+		Chain:       chain,
+		Signer:      signer,
+	}
+	if err := cm.checkKeyPair(); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// AddChainPEM agrega certificados intermedios en formato PEM (ej: los de Certicámara, GSE o Andes SCD)
+func (cm *CertificateManager) AddChainPEM(chainPEM []byte) error {
+	var certs []*x509.Certificate
+	rest := chainPEM
+	for {
+		block, remaining := pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		rest = remaining
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("error parseando certificado intermedio: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return fmt.Errorf("no se encontraron certificados en la cadena PEM")
+	}
+
+	cm.Chain = orderChain(cm.Certificate, append(cm.Chain, certs...))
+	return nil
+}
+
 func (cm *CertificateManager) GetCertificate() *x509.Certificate {
 	return cm.Certificate
 }
 
+// GetChain retorna los certificados intermedios, ordenados desde el emisor del certificado
+func (cm *CertificateManager) GetChain() []*x509.Certificate {
+	return cm.Chain
+}
+
 func (cm *CertificateManager) GetPrivateKey() *rsa.PrivateKey {
 	return cm.PrivateKey
 }
 
+// GetSigner retorna el firmador a usar: Signer si está configurado, o la clave local
+func (cm *CertificateManager) GetSigner() crypto.Signer {
+	if cm.Signer != nil {
+		return cm.Signer
+	}
+	if cm.PrivateKey != nil {
+		return cm.PrivateKey
+	}
+	return nil
+}
+
+// TLSCertificate retorna el certificado como tls.Certificate (para mTLS y WS-Security)
+func (cm *CertificateManager) TLSCertificate() tls.Certificate {
+	der := [][]byte{cm.Certificate.Raw}
+	for _, cert := range cm.Chain {
+		der = append(der, cert.Raw)
+	}
+
+	return tls.Certificate{
+		Certificate: der,
+		PrivateKey:  cm.GetSigner(),
+		Leaf:        cm.Certificate,
+	}
+}
+
+// Validate ejecuta las verificaciones de Health con las opciones por defecto
+// y retorna error si alguna falla (las advertencias no generan error).
+// Si el binario no incluye raíces acreditadas (signature/roots), la cadena no se verifica y solo
+// se advierte: para validarla use Health con HealthOptions.Roots.
 func (cm *CertificateManager) Validate() error {
+	return cm.Health(HealthOptions{}).Err()
+}
+
+// checkKeyPair verifica que exista una clave RSA y que corresponda al certificado
+func (cm *CertificateManager) checkKeyPair() error {
 	if cm.Certificate == nil {
 		return fmt.Errorf("certificado no cargado")
 	}
-	if cm.PrivateKey == nil {
+	signer := cm.GetSigner()
+	if signer == nil {
 		return fmt.Errorf("clave privada no cargada")
 	}
+	if _, ok := signer.Public().(*rsa.PublicKey); !ok {
+		return fmt.Errorf("la clave privada no es RSA")
+	}
+	if !bytes.Equal(publicKeyDER(signer.Public()), publicKeyDER(cm.Certificate.PublicKey)) {
+		return fmt.Errorf("la clave privada no corresponde al certificado")
+	}
 	return nil
 }
+
+// publicKeyDER serializa una llave pública para compararla
+func publicKeyDER(public crypto.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil
+	}
+	return der
+}
@@ -0,0 +1,110 @@
+package signature
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// rfc2253Keywords son los tipos de atributo con nombre corto definidos en RFC 2253.
+// Los demás (ej: emailAddress, serialNumber) se emiten como OID con el valor DER en hexadecimal.
+var rfc2253Keywords = map[string]string{
+	"2.5.4.3":                    "CN",
+	"2.5.4.7":                    "L",
+	"2.5.4.8":                    "ST",
+	"2.5.4.10":                   "O",
+	"2.5.4.11":                   "OU",
+	"2.5.4.6":                    "C",
+	"2.5.4.9":                    "STREET",
+	"0.9.2342.19200300.100.1.25": "DC",
+	"0.9.2342.19200300.100.1.1":  "UID",
+}
+
+type rawAttributeTypeAndValue struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// rawRDNSET conserva la codificación original de cada valor (el sufijo SET lo marca como SET ASN.1)
+type rawRDNSET []rawAttributeTypeAndValue
+
+// FormatRFC2253 formatea un Distinguished Name codificado en DER (ej: cert.RawIssuer)
+// según RFC 2253, como lo esperan los validadores de XAdES (X509IssuerName)
+func FormatRFC2253(rawName []byte) (string, error) {
+	var rdns []rawRDNSET
+	rest, err := asn1.Unmarshal(rawName, &rdns)
+	if err != nil {
+		return "", fmt.Errorf("error parseando nombre distinguido: %w", err)
+	}
+	if len(rest) > 0 {
+		return "", fmt.Errorf("datos sobrantes en nombre distinguido")
+	}
+
+	// RFC 2253 emite los RDN en orden inverso a la secuencia ASN.1
+	parts := make([]string, 0, len(rdns))
+	for i := len(rdns) - 1; i >= 0; i-- {
+		attrs := make([]string, 0, len(rdns[i]))
+		for _, atv := range rdns[i] {
+			attrs = append(attrs, formatAttribute(atv))
+		}
+		parts = append(parts, strings.Join(attrs, "+"))
+	}
+
+	return strings.Join(parts, ","), nil
+}
+
+func formatAttribute(atv rawAttributeTypeAndValue) string {
+	oid := atv.Type.String()
+	keyword, known := rfc2253Keywords[oid]
+	if known {
+		if value, ok := decodeString(atv.Value); ok {
+			return keyword + "=" + escapeRFC2253(value)
+		}
+	} else {
+		keyword = oid
+	}
+	return keyword + "=#" + hex.EncodeToString(atv.Value.FullBytes)
+}
+
+// decodeString decodifica los tipos de cadena ASN.1 usados en nombres distinguidos
+func decodeString(v asn1.RawValue) (string, bool) {
+	if v.Class != asn1.ClassUniversal {
+		return "", false
+	}
+	switch v.Tag {
+	case asn1.TagUTF8String, asn1.TagPrintableString, asn1.TagIA5String, asn1.TagT61String, asn1.TagNumericString:
+		return string(v.Bytes), true
+	case asn1.TagBMPString:
+		if len(v.Bytes)%2 != 0 {
+			return "", false
+		}
+		units := make([]uint16, len(v.Bytes)/2)
+		for i := range units {
+			units[i] = uint16(v.Bytes[2*i])<<8 | uint16(v.Bytes[2*i+1])
+		}
+		return string(utf16.Decode(units)), true
+	}
+	return "", false
+}
+
+// escapeRFC2253 escapa los caracteres especiales de un valor de atributo (RFC 2253, sección 2.4)
+func escapeRFC2253(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case strings.ContainsRune(",+\"\\<>;", r):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case i == 0 && (r == ' ' || r == '#'):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case i == len(s)-1 && r == ' ':
+			b.WriteString("\\ ")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
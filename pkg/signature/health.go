@@ -0,0 +1,188 @@
+package signature
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HealthStatus es el resultado de una verificación del certificado
+type HealthStatus string
+
+const (
+	HealthOK      HealthStatus = "ok"
+	HealthWarning HealthStatus = "warning" // El certificado funciona pero requiere atención (ej: próximo a vencer)
+	HealthError   HealthStatus = "error"   // DIAN rechazará los documentos firmados
+)
+
+// Nombres de las verificaciones del reporte
+const (
+	CheckKeyPair    = "key_pair"
+	CheckValidity   = "validity"
+	CheckKeyUsage   = "key_usage"
+	CheckChain      = "chain"
+	CheckRevocation = "revocation"
+)
+
+// HealthOptions configura las verificaciones de Health
+type HealthOptions struct {
+	Now           func() time.Time   // Por defecto time.Now
+	ExpiryWarning time.Duration      // Anticipación del aviso de vencimiento (por defecto 30 días)
+	Roots         *x509.CertPool     // Raíces de confianza (por defecto AccreditedRoots()); sin raíces la cadena no se verifica (warning)
+	Revocation    *RevocationChecker // Si no es nil, se consulta OCSP/CRL del certificado
+}
+
+// HealthCheck es el resultado de una verificación individual
+type HealthCheck struct {
+	Name    string
+	Status  HealthStatus
+	Message string
+}
+
+// HealthReport es el estado del certificado de firma
+type HealthReport struct {
+	Status    HealthStatus // El peor estado de las verificaciones
+	Subject   string
+	Issuer    string
+	Serial    string
+	NotBefore time.Time
+	NotAfter  time.Time
+	ExpiresIn time.Duration // Tiempo restante de vigencia (negativo si venció)
+	Checks    []HealthCheck
+}
+
+// Health verifica la clave, la vigencia, el uso de clave, la cadena y, si se configura, la revocación del certificado
+func (cm *CertificateManager) Health(opts HealthOptions) *HealthReport {
+	report := &HealthReport{Status: HealthOK}
+
+	if cm.Certificate == nil {
+		report.add(CheckKeyPair, HealthError, "certificado no cargado")
+		return report
+	}
+
+	now := time.Now()
+	if opts.Now != nil {
+		now = opts.Now()
+	}
+	warning := opts.ExpiryWarning
+	if warning == 0 {
+		warning = 30 * 24 * time.Hour
+	}
+
+	cert := cm.Certificate
+	report.Subject, _ = FormatRFC2253(cert.RawSubject)
+	report.Issuer, _ = FormatRFC2253(cert.RawIssuer)
+	report.Serial = cert.SerialNumber.String()
+	report.NotBefore = cert.NotBefore
+	report.NotAfter = cert.NotAfter
+	report.ExpiresIn = cert.NotAfter.Sub(now)
+
+	// 1. Clave privada
+	if err := cm.checkKeyPair(); err != nil {
+		report.add(CheckKeyPair, HealthError, err.Error())
+	} else {
+		report.add(CheckKeyPair, HealthOK, "la clave privada corresponde al certificado")
+	}
+
+	// 2. Vigencia
+	switch {
+	case now.Before(cert.NotBefore):
+		report.add(CheckValidity, HealthError, fmt.Sprintf("el certificado aún no es válido (desde %s)", cert.NotBefore.Format(time.RFC3339)))
+	case now.After(cert.NotAfter):
+		report.add(CheckValidity, HealthError, fmt.Sprintf("el certificado venció el %s", cert.NotAfter.Format(time.RFC3339)))
+	case report.ExpiresIn < warning:
+		report.add(CheckValidity, HealthWarning, fmt.Sprintf("el certificado vence en %d días (%s)", int(report.ExpiresIn.Hours()/24), cert.NotAfter.Format(time.RFC3339)))
+	default:
+		report.add(CheckValidity, HealthOK, fmt.Sprintf("vigente hasta %s", cert.NotAfter.Format(time.RFC3339)))
+	}
+
+	// 3. Uso de clave: firma digital requerida, no repudio esperado
+	switch {
+	case cert.KeyUsage == 0:
+		report.add(CheckKeyUsage, HealthWarning, "el certificado no declara la extensión keyUsage")
+	case cert.KeyUsage&x509.KeyUsageDigitalSignature == 0:
+		report.add(CheckKeyUsage, HealthError, "el certificado no permite firma digital (digitalSignature)")
+	case cert.KeyUsage&x509.KeyUsageContentCommitment == 0:
+		report.add(CheckKeyUsage, HealthWarning, "el certificado no declara no repudio (nonRepudiation)")
+	default:
+		report.add(CheckKeyUsage, HealthOK, "digitalSignature y nonRepudiation")
+	}
+
+	// 4. Cadena hasta una entidad de certificación acreditada
+	roots := opts.Roots
+	if roots == nil {
+		roots = AccreditedRoots()
+	}
+	if roots == nil {
+		// Sin raíces no hay forma de distinguir un certificado acreditado de uno autofirmado:
+		// se advierte en lugar de fallar, porque la librería no incluye raíces por defecto
+		report.add(CheckChain, HealthWarning, "cadena no verificada: no hay raíces de confianza configuradas; asigne HealthOptions.Roots con las raíces de la entidad de certificación")
+	} else {
+		intermediates := x509.NewCertPool()
+		for _, c := range cm.Chain {
+			intermediates.AddCert(c)
+		}
+		chains, err := cert.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   now,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			report.add(CheckChain, HealthError, fmt.Sprintf("la cadena no es de confianza: %v", err))
+		} else {
+			root := chains[0][len(chains[0])-1]
+			report.add(CheckChain, HealthOK, "emitido por "+root.Subject.CommonName)
+		}
+	}
+
+	// 5. Revocación
+	if opts.Revocation != nil {
+		result, err := cm.CheckRevocation(opts.Revocation)
+		switch {
+		case err != nil:
+			report.add(CheckRevocation, HealthWarning, fmt.Sprintf("no se verificó la revocación: %v", err))
+		case result.Status == RevocationRevoked:
+			report.add(CheckRevocation, HealthError, fmt.Sprintf("el certificado fue revocado el %s (%s)", result.RevokedAt.Format(time.RFC3339), result.Source))
+		case result.Status == RevocationUnknown:
+			report.add(CheckRevocation, HealthWarning, "estado de revocación desconocido: "+strings.Join(result.Errors, "; "))
+		default:
+			report.add(CheckRevocation, HealthOK, "no revocado ("+result.Source+")")
+		}
+	}
+
+	return report
+}
+
+// Err retorna un error con las verificaciones fallidas, o nil si no hay errores
+func (r *HealthReport) Err() error {
+	var failed []string
+	for _, check := range r.Checks {
+		if check.Status == HealthError {
+			failed = append(failed, check.Message)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("certificado inválido: %s", strings.Join(failed, "; "))
+}
+
+// Warnings retorna los mensajes de las verificaciones con advertencia
+func (r *HealthReport) Warnings() []string {
+	var warnings []string
+	for _, check := range r.Checks {
+		if check.Status == HealthWarning {
+			warnings = append(warnings, check.Message)
+		}
+	}
+	return warnings
+}
+
+func (r *HealthReport) add(name string, status HealthStatus, message string) {
+	r.Checks = append(r.Checks, HealthCheck{Name: name, Status: status, Message: message})
+	if status == HealthError || (status == HealthWarning && r.Status == HealthOK) {
+		r.Status = status
+	}
+}
@@ -0,0 +1,92 @@
+package signature
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestManager crea un CertificateManager con un certificado autofirmado apto para firma
+func newTestManager(t *testing.T) *CertificateManager {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "EMISOR DE PRUEBA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cm, err := NewCertManagerWithSigner(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), key)
+	if err != nil {
+		t.Fatalf("NewCertManagerWithSigner: %v", err)
+	}
+	return cm
+}
+
+func TestValidateWithoutRoots(t *testing.T) {
+	if AccreditedRoots() != nil {
+		t.Skip("el binario incluye raíces acreditadas")
+	}
+	cm := newTestManager(t)
+
+	if err := cm.Validate(); err != nil {
+		t.Fatalf("Validate sin raíces configuradas: %v", err)
+	}
+
+	report := cm.Health(HealthOptions{})
+	if report.Status != HealthWarning {
+		t.Errorf("Status = %s, se esperaba %s", report.Status, HealthWarning)
+	}
+	for _, check := range report.Checks {
+		if check.Name == CheckChain && (check.Status != HealthWarning || !strings.Contains(check.Message, "cadena no verificada")) {
+			t.Errorf("chain = %s (%s)", check.Status, check.Message)
+		}
+	}
+}
+
+func TestHealthChain(t *testing.T) {
+	cm := newTestManager(t)
+
+	trusted := x509.NewCertPool()
+	trusted.AddCert(cm.Certificate)
+	other := x509.NewCertPool()
+	other.AddCert(newTestManager(t).Certificate)
+
+	tests := []struct {
+		name   string
+		roots  *x509.CertPool
+		status HealthStatus
+	}{
+		{name: "emitido por una raíz de confianza", roots: trusted, status: HealthOK},
+		{name: "emitido por otra entidad", roots: other, status: HealthError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := cm.Health(HealthOptions{Roots: tt.roots})
+			for _, check := range report.Checks {
+				if check.Name == CheckChain && check.Status != tt.status {
+					t.Errorf("chain = %s (%s), se esperaba %s", check.Status, check.Message, tt.status)
+				}
+			}
+		})
+	}
+}
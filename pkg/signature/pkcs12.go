@@ -0,0 +1,38 @@
+package signature
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// LoadPKCS12 carga certificado, cadena y clave privada desde un archivo .p12/.pfx protegido con contraseña
+func LoadPKCS12(path, password string) (*x509.Certificate, []*x509.Certificate, *rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error leyendo archivo PKCS#12: %w", err)
+	}
+	return ParsePKCS12(data, password)
+}
+
+// ParsePKCS12 extrae certificado, cadena y clave privada de un contenedor PKCS#12.
+// Soporta tanto el cifrado heredado (3DES/RC2) como PBES2/AES usado por las entidades certificadoras.
+func ParsePKCS12(data []byte, password string) (*x509.Certificate, []*x509.Certificate, *rsa.PrivateKey, error) {
+	privateKey, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error decodificando PKCS#12: %w", err)
+	}
+
+	key, ok := privateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("la clave privada no es RSA")
+	}
+	if pub, ok := cert.PublicKey.(*rsa.PublicKey); !ok || !pub.Equal(&key.PublicKey) {
+		return nil, nil, nil, fmt.Errorf("el certificado del PKCS#12 no corresponde a la clave privada")
+	}
+
+	return cert, orderChain(cert, caCerts), key, nil
+}
@@ -0,0 +1,197 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// testChain es una cadena raíz → intermedia → certificado de firma
+type testChain struct {
+	root, intermediate, leaf *x509.Certificate
+	key                      *rsa.PrivateKey
+}
+
+// issueTestCertificate emite un certificado para key firmado por parent (autofirmado si parent es nil)
+func issueTestCertificate(t *testing.T, name string, isCA bool, key *rsa.PrivateKey, parent *x509.Certificate, parentKey *rsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+	if isCA {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func newTestChain(t *testing.T) *testChain {
+	t.Helper()
+
+	keys := make([]*rsa.PrivateKey, 3)
+	for i := range keys {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	root := issueTestCertificate(t, "RAIZ DE PRUEBA", true, keys[0], nil, nil)
+	intermediate := issueTestCertificate(t, "SUBORDINADA DE PRUEBA", true, keys[1], root, keys[0])
+	leaf := issueTestCertificate(t, "EMISOR DE PRUEBA", false, keys[2], intermediate, keys[1])
+	return &testChain{root: root, intermediate: intermediate, leaf: leaf, key: keys[2]}
+}
+
+func TestParsePKCS12(t *testing.T) {
+	chain := newTestChain(t)
+
+	encoders := map[string]*pkcs12.Encoder{
+		"Modern2023": pkcs12.Modern2023,
+		"LegacyDES":  pkcs12.LegacyDES,
+		"LegacyRC2":  pkcs12.LegacyRC2,
+	}
+
+	for name, encoder := range encoders {
+		t.Run(name, func(t *testing.T) {
+			// La cadena se incluye desordenada: ParsePKCS12 debe ordenarla desde el emisor hacia la raíz
+			data, err := encoder.Encode(chain.key, chain.leaf, []*x509.Certificate{chain.root, chain.intermediate}, "secreto")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cert, caCerts, key, err := ParsePKCS12(data, "secreto")
+			if err != nil {
+				t.Fatalf("ParsePKCS12: %v", err)
+			}
+			if !cert.Equal(chain.leaf) {
+				t.Errorf("certificado = %s, se esperaba %s", cert.Subject.CommonName, chain.leaf.Subject.CommonName)
+			}
+			if !key.Equal(chain.key) {
+				t.Error("la clave privada no corresponde")
+			}
+			if len(caCerts) != 2 || !caCerts[0].Equal(chain.intermediate) || !caCerts[1].Equal(chain.root) {
+				t.Errorf("cadena = %v, se esperaba [intermedia, raíz]", commonNames(caCerts))
+			}
+
+			if _, _, _, err := ParsePKCS12(data, "otra"); err == nil {
+				t.Error("se aceptó una contraseña incorrecta")
+			}
+		})
+	}
+}
+
+func commonNames(certs []*x509.Certificate) []string {
+	names := make([]string, len(certs))
+	for i, c := range certs {
+		names[i] = c.Subject.CommonName
+	}
+	return names
+}
+
+func TestParsePKCS12Invalid(t *testing.T) {
+	chain := newTestChain(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withoutKey, err := pkcs12.Modern2023.EncodeTrustStore([]*x509.Certificate{chain.leaf, chain.intermediate}, "secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched, err := pkcs12.Modern2023.Encode(otherKey, chain.leaf, nil, "secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notRSA, err := pkcs12.Modern2023.Encode(ecKey, chain.leaf, nil, "secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{name: "sin clave privada", data: withoutKey, err: "error decodificando PKCS#12"},
+		{name: "clave de otro certificado", data: mismatched, err: "no corresponde a la clave privada"},
+		{name: "clave no RSA", data: notRSA, err: "la clave privada no es RSA"},
+		{name: "datos corruptos", data: []byte("no es un PKCS#12"), err: "error decodificando PKCS#12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := ParsePKCS12(tt.data, "secreto")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, se esperaba %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNewCertManagerFromPKCS12File(t *testing.T) {
+	chain := newTestChain(t)
+	data, err := pkcs12.Modern2023.Encode(chain.key, chain.leaf, []*x509.Certificate{chain.intermediate, chain.root}, "secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "certificado.p12")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cm, err := NewCertManagerFromPKCS12File(path, "secreto")
+	if err != nil {
+		t.Fatalf("NewCertManagerFromPKCS12File: %v", err)
+	}
+	if !cm.GetCertificate().Equal(chain.leaf) || len(cm.GetChain()) != 2 {
+		t.Errorf("certificado %s con cadena %v", cm.GetCertificate().Subject.CommonName, commonNames(cm.GetChain()))
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(chain.root)
+	if err := cm.Health(HealthOptions{Roots: roots}).Err(); err != nil {
+		t.Errorf("Health con la raíz del PKCS#12: %v", err)
+	}
+
+	if _, err := NewCertManagerFromPKCS12File(path, "otra"); err == nil {
+		t.Error("se aceptó una contraseña incorrecta")
+	}
+	if _, err := NewCertManagerFromPKCS12File(filepath.Join(t.TempDir(), "no-existe.p12"), "secreto"); err == nil {
+		t.Error("se aceptó un archivo inexistente")
+	}
+}
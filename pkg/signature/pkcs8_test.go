@@ -0,0 +1,194 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const testKeyPassword = "clave-de-prueba"
+
+// readPEM retorna los bytes DER del primer bloque PEM de un archivo de testdata
+func readPEM(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("%s no contiene un bloque PEM", name)
+	}
+	return block.Bytes
+}
+
+// Las claves cifradas de testdata se generaron con OpenSSL 3 a partir de key.pem:
+//
+//	openssl pkcs8 -topk8 -in key.pem -v2 aes-256-cbc -v2prf hmacWithSHA256 -passout pass:clave-de-prueba
+func TestDecryptPKCS8OpenSSL(t *testing.T) {
+	parsed, err := x509.ParsePKCS8PrivateKey(readPEM(t, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := parsed.(*rsa.PrivateKey)
+
+	files := []string{
+		"key-aes-128-cbc-hmacWithSHA1.pem",
+		"key-aes-128-cbc-hmacWithSHA256.pem",
+		"key-aes-256-cbc-hmacWithSHA1.pem",
+		"key-aes-256-cbc-hmacWithSHA256.pem",
+	}
+
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			der := readPEM(t, file)
+
+			key, err := decryptPKCS8(der, StaticPassword(testKeyPassword))
+			if err != nil {
+				t.Fatalf("decryptPKCS8: %v", err)
+			}
+			if !key.Equal(expected) {
+				t.Error("la clave descifrada no corresponde a key.pem")
+			}
+
+			_, err = decryptPKCS8(der, StaticPassword("otra-clave"))
+			if err == nil || !strings.Contains(err.Error(), "contraseña de la clave privada incorrecta") {
+				t.Errorf("contraseña incorrecta: error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDecryptPKCS8WithoutPassword(t *testing.T) {
+	_, err := decryptPKCS8(readPEM(t, "key-aes-256-cbc-hmacWithSHA256.pem"), nil)
+	if err == nil || !strings.Contains(err.Error(), "no se configuró contraseña") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+// encryptPKCS8 cifra plain (que ya debe incluir el padding) con PBES2, PBKDF2-HMAC-SHA256 y AES-128-CBC
+func encryptPKCS8(t *testing.T, plain []byte, iterations int) []byte {
+	t.Helper()
+
+	salt := []byte("sal-de-prueba")
+	iv := bytes.Repeat([]byte{7}, aes.BlockSize)
+	kdf, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: iterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdf}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES128CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := make([]byte, len(plain))
+	if iterations > 0 && iterations <= maxPBKDF2Iterations {
+		block, err := aes.NewCipher(pbkdf2.Key([]byte(testKeyPassword), salt, iterations, 16, sha256.New))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, plain)
+	}
+
+	der, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: encrypted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestDecryptPKCS8Padding(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Relleno hasta un bloque completo menos 3 bytes para probar paddings de 3
+	body := append(plain, bytes.Repeat([]byte{0}, aes.BlockSize-(len(plain)+3)%aes.BlockSize)...)
+	body = body[:len(body):len(body)]
+
+	tests := []struct {
+		name    string
+		data    []byte
+		err     string
+		success bool
+	}{
+		{
+			name:    "padding PKCS#7 válido",
+			data:    append(append([]byte(nil), plain...), bytes.Repeat([]byte{byte(aes.BlockSize - len(plain)%aes.BlockSize)}, aes.BlockSize-len(plain)%aes.BlockSize)...),
+			success: true,
+		},
+		{name: "padding cero", data: append(body, 0, 0, 0), err: "contraseña de la clave privada incorrecta"},
+		{name: "padding mayor que el bloque", data: append(body, 17, 17, 17), err: "contraseña de la clave privada incorrecta"},
+		{name: "bytes de padding inconsistentes", data: append(body, 2, 1, 3), err: "contraseña de la clave privada incorrecta"},
+		{name: "padding válido con contenido inválido", data: append(bytes.Repeat([]byte{0x42}, 2*aes.BlockSize-3), 3, 3, 3), err: "contraseña de la clave privada incorrecta: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.data)%aes.BlockSize != 0 {
+				t.Fatalf("datos de prueba de %d bytes", len(tt.data))
+			}
+			decrypted, err := decryptPKCS8(encryptPKCS8(t, tt.data, 1000), StaticPassword(testKeyPassword))
+			if tt.success {
+				if err != nil {
+					t.Fatalf("decryptPKCS8: %v", err)
+				}
+				if !decrypted.Equal(key) {
+					t.Error("la clave descifrada no corresponde")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %v, se esperaba %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecryptPKCS8IterationCount(t *testing.T) {
+	for _, iterations := range []int{0, -1, maxPBKDF2Iterations + 1, 1 << 30} {
+		called := false
+		password := func() ([]byte, error) {
+			called = true
+			return []byte(testKeyPassword), nil
+		}
+
+		_, err := decryptPKCS8(encryptPKCS8(t, make([]byte, aes.BlockSize), iterations), password)
+		if err == nil || !strings.Contains(err.Error(), "iteraciones PBKDF2 inválido") {
+			t.Errorf("iteraciones %d: error = %v", iterations, err)
+		}
+		if called {
+			t.Errorf("iteraciones %d: se pidió la contraseña antes de validar los parámetros", iterations)
+		}
+	}
+}
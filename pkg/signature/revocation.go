@@ -0,0 +1,352 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// RevocationStatus es el estado de revocación de un certificado
+type RevocationStatus string
+
+const (
+	RevocationGood    RevocationStatus = "good"
+	RevocationRevoked RevocationStatus = "revoked"
+	RevocationUnknown RevocationStatus = "unknown" // Ninguna fuente dio una respuesta concluyente
+)
+
+// Fuentes de revocación
+const (
+	SourceOCSP = "ocsp"
+	SourceCRL  = "crl"
+)
+
+// RevocationResult es el resultado de consultar la revocación de un certificado
+type RevocationResult struct {
+	Status     RevocationStatus
+	Source     string    // SourceOCSP o SourceCRL
+	RevokedAt  time.Time // Solo si Status es RevocationRevoked
+	Reason     int       // Código de razón RFC 5280 (solo si Status es RevocationRevoked)
+	ThisUpdate time.Time
+	NextUpdate time.Time
+	CheckedAt  time.Time
+	Cached     bool     // El resultado provino de la caché
+	Errors     []string // Fuentes consultadas que fallaron
+}
+
+// RevocationChecker consulta OCSP y CRL usando las URLs AIA y CRLDistributionPoints del certificado.
+// Los resultados y las CRL descargadas se guardan en caché hasta NextUpdate, sin exceder CacheTTL.
+type RevocationChecker struct {
+	HTTPClient  *http.Client
+	Now         func() time.Time
+	CacheTTL    time.Duration // Vigencia máxima de un resultado en caché (por defecto 1 hora)
+	Offline     bool          // No consulta la red: usa solo las CRL cargadas con AddCRL o LoadCRLFile
+	DisableOCSP bool          // Consulta solo CRL
+
+	mu         sync.Mutex
+	results    map[string]*RevocationResult      // por emisor + serial
+	expires    map[string]time.Time              // vencimiento de cada resultado en caché
+	crls       map[string][]*x509.RevocationList // CRL cargadas manualmente, por emisor (RawSubject en hex)
+	downloaded map[string]*x509.RevocationList   // CRL descargadas, por URL
+	fetched    map[string]time.Time              // vencimiento de cada CRL descargada, por URL
+}
+
+// NewRevocationChecker crea un verificador con caché de 1 hora y timeout HTTP de 15 segundos
+func NewRevocationChecker() *RevocationChecker {
+	return &RevocationChecker{
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+		Now:        time.Now,
+		CacheTTL:   time.Hour,
+	}
+}
+
+// AddCRL agrega una CRL pre-descargada (DER o PEM). La firma se verifica al consultar, contra el emisor.
+func (rc *RevocationChecker) AddCRL(data []byte) error {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return fmt.Errorf("error parseando CRL: %w", err)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.init()
+	key := hex.EncodeToString(crl.RawIssuer)
+	rc.crls[key] = append(rc.crls[key], crl)
+	return nil
+}
+
+// LoadCRLFile agrega una CRL desde un archivo (.crl en DER o PEM)
+func (rc *RevocationChecker) LoadCRLFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error leyendo CRL: %w", err)
+	}
+	return rc.AddCRL(data)
+}
+
+// CheckWithChain consulta la revocación de cert buscando su emisor en chain
+func (rc *RevocationChecker) CheckWithChain(cert *x509.Certificate, chain []*x509.Certificate) (*RevocationResult, error) {
+	for _, issuer := range chain {
+		if bytes.Equal(issuer.RawSubject, cert.RawIssuer) {
+			return rc.Check(cert, issuer)
+		}
+	}
+	return nil, fmt.Errorf("el emisor del certificado no está en la cadena")
+}
+
+// Check consulta OCSP y, si no es concluyente, la CRL del emisor.
+// Retorna error solo si los argumentos son inválidos; las fallas de red o de firma
+// de las fuentes se registran en Errors y producen RevocationUnknown.
+func (rc *RevocationChecker) Check(cert, issuer *x509.Certificate) (*RevocationResult, error) {
+	if cert == nil || issuer == nil {
+		return nil, fmt.Errorf("se requieren certificado y emisor")
+	}
+	if !bytes.Equal(issuer.RawSubject, cert.RawIssuer) {
+		return nil, fmt.Errorf("el emisor no corresponde al certificado")
+	}
+
+	now := rc.now()
+	key := hex.EncodeToString(cert.RawIssuer) + "/" + cert.SerialNumber.String()
+
+	rc.mu.Lock()
+	rc.init()
+	if cached, ok := rc.results[key]; ok && now.Before(rc.expires[key]) {
+		result := *cached
+		result.Cached = true
+		rc.mu.Unlock()
+		return &result, nil
+	}
+	rc.mu.Unlock()
+
+	result := &RevocationResult{Status: RevocationUnknown, CheckedAt: now}
+
+	// 1. OCSP
+	if !rc.Offline && !rc.DisableOCSP {
+		for _, server := range cert.OCSPServer {
+			if err := rc.checkOCSP(server, cert, issuer, result); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("OCSP %s: %v", server, err))
+				continue
+			}
+			if result.Status != RevocationUnknown {
+				break
+			}
+		}
+	}
+
+	// 2. CRL
+	if result.Status == RevocationUnknown {
+		if err := rc.checkCRL(cert, issuer, result); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("CRL: %v", err))
+		}
+	}
+
+	if result.Status != RevocationUnknown {
+		expires := now.Add(rc.cacheTTL())
+		if !result.NextUpdate.IsZero() && result.NextUpdate.Before(expires) {
+			expires = result.NextUpdate
+		}
+		rc.mu.Lock()
+		rc.results[key] = result
+		rc.expires[key] = expires
+		rc.mu.Unlock()
+	}
+
+	copied := *result
+	return &copied, nil
+}
+
+// CheckRevocation consulta la revocación del certificado de firma usando su cadena
+func (cm *CertificateManager) CheckRevocation(rc *RevocationChecker) (*RevocationResult, error) {
+	if cm.Certificate == nil {
+		return nil, fmt.Errorf("certificado no cargado")
+	}
+	return rc.CheckWithChain(cm.Certificate, cm.Chain)
+}
+
+// checkOCSP consulta un responder OCSP y verifica la firma de la respuesta contra el emisor
+func (rc *RevocationChecker) checkOCSP(server string, cert, issuer *x509.Certificate, result *RevocationResult) error {
+	request, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return fmt.Errorf("error creando request: %w", err)
+	}
+
+	resp, err := rc.httpClient().Post(server, "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	parsed, err := ocsp.ParseResponseForCert(body, cert, issuer)
+	if err != nil {
+		return fmt.Errorf("respuesta inválida: %w", err)
+	}
+	if !parsed.NextUpdate.IsZero() && rc.now().After(parsed.NextUpdate) {
+		return fmt.Errorf("respuesta vencida (nextUpdate %s)", parsed.NextUpdate.Format(time.RFC3339))
+	}
+
+	result.Source = SourceOCSP
+	result.ThisUpdate = parsed.ThisUpdate
+	result.NextUpdate = parsed.NextUpdate
+	switch parsed.Status {
+	case ocsp.Good:
+		result.Status = RevocationGood
+	case ocsp.Revoked:
+		result.Status = RevocationRevoked
+		result.RevokedAt = parsed.RevokedAt
+		result.Reason = parsed.RevocationReason
+	default:
+		result.Status = RevocationUnknown
+	}
+	return nil
+}
+
+// checkCRL busca el serial en las CRL del emisor, descargándolas si no está en modo offline
+func (rc *RevocationChecker) checkCRL(cert, issuer *x509.Certificate, result *RevocationResult) error {
+	if !rc.Offline {
+		for _, url := range cert.CRLDistributionPoints {
+			if err := rc.fetchCRL(url); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("CRL %s: %v", url, err))
+			}
+		}
+	}
+
+	rc.mu.Lock()
+	rc.init()
+	crls := append([]*x509.RevocationList(nil), rc.crls[hex.EncodeToString(issuer.RawSubject)]...)
+	for _, url := range cert.CRLDistributionPoints {
+		if crl, ok := rc.downloaded[url]; ok && bytes.Equal(crl.RawIssuer, issuer.RawSubject) {
+			crls = append(crls, crl)
+		}
+	}
+	rc.mu.Unlock()
+	if len(crls) == 0 {
+		return fmt.Errorf("no hay CRL disponible para el emisor")
+	}
+
+	// La CRL más reciente primero
+	sort.SliceStable(crls, func(i, j int) bool {
+		return crls[i].ThisUpdate.After(crls[j].ThisUpdate)
+	})
+
+	now := rc.now()
+	var lastErr error
+	for _, crl := range crls {
+		if err := crl.CheckSignatureFrom(issuer); err != nil {
+			lastErr = fmt.Errorf("firma de CRL inválida: %w", err)
+			continue
+		}
+		if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+			lastErr = fmt.Errorf("CRL vencida (nextUpdate %s)", crl.NextUpdate.Format(time.RFC3339))
+			continue
+		}
+
+		result.Source = SourceCRL
+		result.Status = RevocationGood
+		result.ThisUpdate = crl.ThisUpdate
+		result.NextUpdate = crl.NextUpdate
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				result.Status = RevocationRevoked
+				result.RevokedAt = entry.RevocationTime
+				result.Reason = entry.ReasonCode
+				break
+			}
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// fetchCRL descarga una CRL si no está en caché o si su caché venció
+func (rc *RevocationChecker) fetchCRL(url string) error {
+	now := rc.now()
+	rc.mu.Lock()
+	rc.init()
+	expires, ok := rc.fetched[url]
+	rc.mu.Unlock()
+	if ok && now.Before(expires) {
+		return nil
+	}
+
+	resp, err := rc.httpClient().Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return fmt.Errorf("error parseando CRL: %w", err)
+	}
+
+	expires = now.Add(rc.cacheTTL())
+	if !crl.NextUpdate.IsZero() && crl.NextUpdate.Before(expires) {
+		expires = crl.NextUpdate
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.downloaded[url] = crl
+	rc.fetched[url] = expires
+	return nil
+}
+
+func (rc *RevocationChecker) init() {
+	if rc.results == nil {
+		rc.results = make(map[string]*RevocationResult)
+		rc.expires = make(map[string]time.Time)
+		rc.crls = make(map[string][]*x509.RevocationList)
+		rc.downloaded = make(map[string]*x509.RevocationList)
+		rc.fetched = make(map[string]time.Time)
+	}
+}
+
+func (rc *RevocationChecker) httpClient() *http.Client {
+	if rc.HTTPClient != nil {
+		return rc.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (rc *RevocationChecker) now() time.Time {
+	if rc.Now != nil {
+		return rc.Now()
+	}
+	return time.Now()
+}
+
+func (rc *RevocationChecker) cacheTTL() time.Duration {
+	if rc.CacheTTL > 0 {
+		return rc.CacheTTL
+	}
+	return time.Hour
+}
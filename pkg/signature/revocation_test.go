@@ -0,0 +1,373 @@
+package signature
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/diegofxm/go-dian/pkg/revocationtest"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testClock es un reloj compartido entre el verificador y el servidor de prueba
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newRevocationServer(t *testing.T) *revocationtest.Server {
+	t.Helper()
+	server, err := revocationtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func issue(t *testing.T, server *revocationtest.Server) *x509.Certificate {
+	t.Helper()
+	cert, _, err := server.Issue("EMISOR DE PRUEBA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// forgeIssuer retorna un certificado con el mismo nombre de la entidad de prueba pero otra clave
+func forgeIssuer(t *testing.T, server *revocationtest.Server) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &x509.Certificate{
+		Subject:      server.CA.Subject,
+		RawSubject:   server.CA.RawSubject,
+		SubjectKeyId: []byte{1, 2, 3, 4},
+		KeyUsage:     x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		PublicKey:    &key.PublicKey,
+	}, key
+}
+
+// unknownCert emite, sin pasar por el servidor, un certificado cuyo serial el responder OCSP no conoce
+func unknownCert(t *testing.T, server *revocationtest.Server) *x509.Certificate {
+	t.Helper()
+	parent, key := forgeIssuer(t, server)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1000),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		OCSPServer:            []string{server.URL + "/ocsp"},
+		CRLDistributionPoints: []string{server.URL + "/crl"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func hasError(result *RevocationResult, substr string) bool {
+	for _, e := range result.Errors {
+		if strings.Contains(e, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRevocationOnline(t *testing.T) {
+	tests := []struct {
+		name     string
+		revoke   bool
+		unknown  bool
+		ocspDown bool
+		crlDown  bool
+		status   RevocationStatus
+		source   string
+		errors   []string
+	}{
+		{name: "OCSP good", status: RevocationGood, source: SourceOCSP},
+		{name: "OCSP revoked", revoke: true, status: RevocationRevoked, source: SourceOCSP},
+		{name: "OCSP unknown con respaldo CRL", unknown: true, status: RevocationGood, source: SourceCRL},
+		{name: "OCSP unknown sin CRL", unknown: true, crlDown: true, status: RevocationUnknown, source: SourceOCSP, errors: []string{"HTTP 503"}},
+		{name: "OCSP caído, CRL good", ocspDown: true, status: RevocationGood, source: SourceCRL, errors: []string{"OCSP"}},
+		{name: "OCSP caído, CRL revoked", ocspDown: true, revoke: true, status: RevocationRevoked, source: SourceCRL},
+		{name: "ambas fuentes caídas", ocspDown: true, crlDown: true, status: RevocationUnknown, errors: []string{"OCSP", "CRL"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRevocationServer(t)
+			server.OCSPDown = tt.ocspDown
+			server.CRLDown = tt.crlDown
+
+			cert := issue(t, server)
+			if tt.unknown {
+				cert = unknownCert(t, server)
+			}
+			if tt.revoke {
+				server.Revoke(cert, ocsp.KeyCompromise)
+			}
+
+			result, err := NewRevocationChecker().Check(cert, server.CA)
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if result.Status != tt.status || result.Source != tt.source {
+				t.Fatalf("resultado = %s (%s), se esperaba %s (%s); errores: %v", result.Status, result.Source, tt.status, tt.source, result.Errors)
+			}
+			if tt.revoke && (result.Reason != ocsp.KeyCompromise || result.RevokedAt.IsZero()) {
+				t.Errorf("revocación sin razón o fecha: %+v", result)
+			}
+			for _, e := range tt.errors {
+				if !hasError(result, e) {
+					t.Errorf("no se registró el error %q: %v", e, result.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestRevocationStaleSources(t *testing.T) {
+	server := newRevocationServer(t)
+	cert := issue(t, server)
+
+	// CRL vigente cargada antes de que el servidor empiece a responder con fechas vencidas
+	fresh, err := server.CRL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+
+	t.Run("OCSP y CRL vencidos", func(t *testing.T) {
+		result, err := NewRevocationChecker().Check(cert, server.CA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != RevocationUnknown {
+			t.Fatalf("Status = %s, se esperaba %s", result.Status, RevocationUnknown)
+		}
+		if !hasError(result, "respuesta vencida") || !hasError(result, "CRL vencida") {
+			t.Errorf("errores = %v", result.Errors)
+		}
+	})
+
+	t.Run("OCSP vencido, CRL vigente cargada", func(t *testing.T) {
+		rc := NewRevocationChecker()
+		if err := rc.AddCRL(fresh); err != nil {
+			t.Fatal(err)
+		}
+		result, err := rc.Check(cert, server.CA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != RevocationGood || result.Source != SourceCRL {
+			t.Fatalf("resultado = %s (%s): %v", result.Status, result.Source, result.Errors)
+		}
+		if !hasError(result, "respuesta vencida") {
+			t.Errorf("no se registró el OCSP vencido: %v", result.Errors)
+		}
+	})
+}
+
+func TestRevocationOffline(t *testing.T) {
+	server := newRevocationServer(t)
+	good := issue(t, server)
+	revoked := issue(t, server)
+	server.Revoke(revoked, ocsp.CessationOfOperation)
+
+	crl, err := server.CRL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ca.crl")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rc := &RevocationChecker{Offline: true}
+	if err := rc.LoadCRLFile(path); err != nil {
+		t.Fatalf("LoadCRLFile: %v", err)
+	}
+
+	result, err := rc.Check(good, server.CA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != RevocationGood || result.Source != SourceCRL {
+		t.Errorf("good = %s (%s): %v", result.Status, result.Source, result.Errors)
+	}
+
+	result, err = rc.Check(revoked, server.CA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != RevocationRevoked || result.Reason != ocsp.CessationOfOperation {
+		t.Errorf("revoked = %s (razón %d): %v", result.Status, result.Reason, result.Errors)
+	}
+
+	if ocspRequests, crlRequests := server.Requests(); ocspRequests != 0 || crlRequests != 0 {
+		t.Errorf("el modo offline consultó la red: %d OCSP, %d CRL", ocspRequests, crlRequests)
+	}
+
+	t.Run("CRL vencida", func(t *testing.T) {
+		rc := &RevocationChecker{Offline: true, Now: func() time.Time { return time.Now().Add(2 * time.Hour) }}
+		if err := rc.AddCRL(crl); err != nil {
+			t.Fatal(err)
+		}
+		result, err := rc.Check(good, server.CA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != RevocationUnknown || !hasError(result, "CRL vencida") {
+			t.Errorf("resultado = %s: %v", result.Status, result.Errors)
+		}
+	})
+
+	t.Run("sin CRL", func(t *testing.T) {
+		result, err := (&RevocationChecker{Offline: true}).Check(good, server.CA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != RevocationUnknown || !hasError(result, "no hay CRL disponible") {
+			t.Errorf("resultado = %s: %v", result.Status, result.Errors)
+		}
+	})
+}
+
+func TestRevocationCRLBadSignature(t *testing.T) {
+	server := newRevocationServer(t)
+	cert := issue(t, server)
+	server.Revoke(cert, ocsp.KeyCompromise)
+
+	// CRL con el nombre del emisor pero firmada con otra clave: no debe ocultar la revocación ni aceptarse
+	forged, key := forgeIssuer(t, server)
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(99),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+	}, forged, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc := &RevocationChecker{Offline: true}
+	if err := rc.AddCRL(der); err != nil {
+		t.Fatal(err)
+	}
+	result, err := rc.Check(cert, server.CA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != RevocationUnknown || !hasError(result, "firma de CRL inválida") {
+		t.Fatalf("resultado = %s: %v", result.Status, result.Errors)
+	}
+
+	// Con la CRL auténtica también cargada, la falsificada (más reciente) se descarta
+	genuine, err := server.CRL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.AddCRL(genuine); err != nil {
+		t.Fatal(err)
+	}
+	result, err = rc.Check(cert, server.CA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != RevocationRevoked {
+		t.Errorf("Status = %s, se esperaba %s: %v", result.Status, RevocationRevoked, result.Errors)
+	}
+}
+
+func TestRevocationCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		validity time.Duration // nextUpdate de las respuestas del servidor
+		ttl      time.Duration
+		expires  time.Duration // cuándo debe volver a consultarse
+	}{
+		{name: "acotada por CacheTTL", validity: time.Hour, ttl: 10 * time.Minute, expires: 10 * time.Minute},
+		{name: "acotada por NextUpdate", validity: 20 * time.Minute, ttl: time.Hour, expires: 20 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &testClock{now: time.Now()}
+			server := newRevocationServer(t)
+			server.Now = clock.Now
+			server.Validity = tt.validity
+			cert := issue(t, server)
+
+			rc := NewRevocationChecker()
+			rc.Now = clock.Now
+			rc.CacheTTL = tt.ttl
+
+			check := func(cached bool, requests int) {
+				t.Helper()
+				result, err := rc.Check(cert, server.CA)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Status != RevocationGood || result.Cached != cached {
+					t.Errorf("resultado = %s (cached %v), se esperaba good (cached %v): %v", result.Status, result.Cached, cached, result.Errors)
+				}
+				if got, _ := server.Requests(); got != requests {
+					t.Errorf("consultas OCSP = %d, se esperaban %d", got, requests)
+				}
+			}
+
+			check(false, 1)
+			clock.Advance(tt.expires - time.Second)
+			check(true, 1)
+			clock.Advance(time.Second)
+			check(false, 2)
+		})
+	}
+}
+
+func TestCheckWithChain(t *testing.T) {
+	server := newRevocationServer(t)
+	cert := issue(t, server)
+
+	if _, err := NewRevocationChecker().CheckWithChain(cert, nil); err == nil {
+		t.Error("se aceptó una cadena sin el emisor")
+	}
+	if _, err := NewRevocationChecker().Check(cert, cert); err == nil {
+		t.Error("se aceptó un emisor que no corresponde al certificado")
+	}
+
+	result, err := NewRevocationChecker().CheckWithChain(cert, []*x509.Certificate{server.CA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != RevocationGood {
+		t.Errorf("Status = %s: %v", result.Status, result.Errors)
+	}
+}
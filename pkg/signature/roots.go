@@ -0,0 +1,41 @@
+package signature
+
+import (
+	"crypto/x509"
+	"embed"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+//go:embed roots
+var rootsFS embed.FS
+
+var (
+	accreditedRootsOnce sync.Once
+	accreditedRoots     *x509.CertPool
+	accreditedRootCount int
+)
+
+// AccreditedRoots retorna el pool de raíces de las entidades de certificación acreditadas
+// incluidas en el directorio roots/ del paquete. Retorna nil si no hay raíces incluidas;
+// en ese caso Health y Validate reportan la cadena como no verificable.
+func AccreditedRoots() *x509.CertPool {
+	accreditedRootsOnce.Do(func() {
+		pool := x509.NewCertPool()
+		fs.WalkDir(rootsFS, "roots", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".pem") {
+				return nil
+			}
+			data, err := rootsFS.ReadFile(path)
+			if err == nil && pool.AppendCertsFromPEM(data) {
+				accreditedRootCount++
+			}
+			return nil
+		})
+		if accreditedRootCount > 0 {
+			accreditedRoots = pool
+		}
+	})
+	return accreditedRoots
+}
@@ -1,34 +1,54 @@
 package signature
 
 import (
+	"bytes"
 	"crypto"
-	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
 	"crypto/x509"
-	"encoding/base64"
 	"encoding/pem"
 	"encoding/xml"
 	"fmt"
 	"os"
 	"strings"
-	"time"
+
+	"github.com/diegofxm/go-dian/pkg/c14n"
 )
 
+// Namespaces, algoritmos y política de firma DIAN v2
+const (
+	NamespaceDS       = "http://www.w3.org/2000/09/xmldsig#"
+	NamespaceXAdES    = "http://uri.etsi.org/01903/v1.3.2#"
+	NamespaceXAdES141 = "http://uri.etsi.org/01903/v1.4.1#"
+
+	AlgorithmC14N        = c14n.C14N10
+	AlgorithmEnveloped   = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	AlgorithmRSASHA256   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	AlgorithmSHA256      = "http://www.w3.org/2001/04/xmlenc#sha256"
+	TypeSignedProperties = "http://uri.etsi.org/01903#SignedProperties"
+
+	PolicyIdentifier  = "https://facturaelectronica.dian.gov.co/politicadefirma/v2/politicadefirmav2.pdf"
+	PolicyDescription = "Política de firma para facturas electrónicas de la República de Colombia."
+	PolicyHash        = "dMoMvtcG5aIzgYo0tIsSQeVJBDnUnfSOfBpxXrmor0Y="
+
+	RoleSupplier   = "supplier"    // Firma del obligado a facturar
+	RoleThirdParty = "third party" // Firma de un proveedor tecnológico o tercero
+)
+
+// Signature representa una firma XMLDSig con propiedades XAdES-EPES
 type Signature struct {
-	XMLName        xml.Name       `xml:"http://www.w3.org/2000/09/xmldsig# Signature"`
+	XMLName        xml.Name       `xml:"ds:Signature"`
+	XmlnsDS        string         `xml:"xmlns:ds,attr"`
 	ID             string         `xml:"Id,attr"`
-	SignedInfo     SignedInfo     `xml:"SignedInfo"`
-	SignatureValue SignatureValue `xml:"SignatureValue"`
-	KeyInfo        KeyInfo        `xml:"KeyInfo"`
-	Object         *Object        `xml:"Object,omitempty"`
+	SignedInfo     SignedInfo     `xml:"ds:SignedInfo"`
+	SignatureValue SignatureValue `xml:"ds:SignatureValue"`
+	KeyInfo        KeyInfo        `xml:"ds:KeyInfo"`
+	Object         Object         `xml:"ds:Object"`
 }
 
 type SignedInfo struct {
-	XMLName                xml.Name               `xml:"SignedInfo"`
-	CanonicalizationMethod CanonicalizationMethod `xml:"CanonicalizationMethod"`
-	SignatureMethod        SignatureMethod        `xml:"SignatureMethod"`
-	Reference              []Reference            `xml:"Reference"`
+	CanonicalizationMethod CanonicalizationMethod `xml:"ds:CanonicalizationMethod"`
+	SignatureMethod        SignatureMethod        `xml:"ds:SignatureMethod"`
+	Reference              []Reference            `xml:"ds:Reference"`
 }
 
 type CanonicalizationMethod struct {
@@ -41,15 +61,15 @@ type SignatureMethod struct {
 
 type Reference struct {
 	ID           string       `xml:"Id,attr,omitempty"`
-	URI          string       `xml:"URI,attr"`
 	Type         string       `xml:"Type,attr,omitempty"`
-	Transforms   *Transforms  `xml:"Transforms,omitempty"`
-	DigestMethod DigestMethod `xml:"DigestMethod"`
-	DigestValue  string       `xml:"DigestValue"`
+	URI          string       `xml:"URI,attr"`
+	Transforms   *Transforms  `xml:"ds:Transforms,omitempty"`
+	DigestMethod DigestMethod `xml:"ds:DigestMethod"`
+	DigestValue  string       `xml:"ds:DigestValue"`
 }
 
 type Transforms struct {
-	Transform []Transform `xml:"Transform"`
+	Transform []Transform `xml:"ds:Transform"`
 }
 
 type Transform struct {
@@ -67,100 +87,121 @@ type SignatureValue struct {
 
 type KeyInfo struct {
 	ID       string   `xml:"Id,attr,omitempty"`
-	X509Data X509Data `xml:"X509Data"`
+	X509Data X509Data `xml:"ds:X509Data"`
 }
 
 type X509Data struct {
-	X509Certificate string `xml:"X509Certificate"`
+	X509Certificate []string `xml:"ds:X509Certificate"`
 }
 
 type Object struct {
-	QualifyingProperties QualifyingProperties `xml:"http://uri.etsi.org/01903/v1.3.2# QualifyingProperties"`
+	QualifyingProperties QualifyingProperties `xml:"xades:QualifyingProperties"`
 }
 
 type QualifyingProperties struct {
+	XmlnsXAdES       string           `xml:"xmlns:xades,attr"`
+	XmlnsXAdES141    string           `xml:"xmlns:xades141,attr"`
 	Target           string           `xml:"Target,attr"`
-	SignedProperties SignedProperties `xml:"SignedProperties"`
+	SignedProperties SignedProperties `xml:"xades:SignedProperties"`
 }
 
 type SignedProperties struct {
 	ID                        string                    `xml:"Id,attr"`
-	SignedSignatureProperties SignedSignatureProperties `xml:"SignedSignatureProperties"`
+	SignedSignatureProperties SignedSignatureProperties `xml:"xades:SignedSignatureProperties"`
 }
 
 type SignedSignatureProperties struct {
-	SigningTime               string                    `xml:"SigningTime"`
-	SigningCertificate        SigningCertificate        `xml:"SigningCertificate"`
-	SignaturePolicyIdentifier SignaturePolicyIdentifier `xml:"SignaturePolicyIdentifier"`
+	SigningTime               string                    `xml:"xades:SigningTime"`
+	SigningCertificate        SigningCertificate        `xml:"xades:SigningCertificate"`
+	SignaturePolicyIdentifier SignaturePolicyIdentifier `xml:"xades:SignaturePolicyIdentifier"`
+	SignerRole                SignerRole                `xml:"xades:SignerRole"`
 }
 
 type SigningCertificate struct {
-	Cert []Cert `xml:"Cert"`
+	Cert []Cert `xml:"xades:Cert"`
 }
 
 type Cert struct {
-	CertDigest   CertDigest   `xml:"CertDigest"`
-	IssuerSerial IssuerSerial `xml:"IssuerSerial"`
+	CertDigest   CertDigest   `xml:"xades:CertDigest"`
+	IssuerSerial IssuerSerial `xml:"xades:IssuerSerial"`
 }
 
 type CertDigest struct {
-	DigestMethod DigestMethod `xml:"http://www.w3.org/2000/09/xmldsig# DigestMethod"`
-	DigestValue  string       `xml:"http://www.w3.org/2000/09/xmldsig# DigestValue"`
+	DigestMethod DigestMethod `xml:"ds:DigestMethod"`
+	DigestValue  string       `xml:"ds:DigestValue"`
 }
 
 type IssuerSerial struct {
-	X509IssuerName   string `xml:"http://www.w3.org/2000/09/xmldsig# X509IssuerName"`
-	X509SerialNumber string `xml:"http://www.w3.org/2000/09/xmldsig# X509SerialNumber"`
+	X509IssuerName   string `xml:"ds:X509IssuerName"`
+	X509SerialNumber string `xml:"ds:X509SerialNumber"`
 }
 
 type SignaturePolicyIdentifier struct {
-	SignaturePolicyId SignaturePolicyId `xml:"SignaturePolicyId"`
+	SignaturePolicyId SignaturePolicyId `xml:"xades:SignaturePolicyId"`
 }
 
 type SignaturePolicyId struct {
-	SigPolicyId   SigPolicyId   `xml:"SigPolicyId"`
-	SigPolicyHash SigPolicyHash `xml:"SigPolicyHash"`
+	SigPolicyId   SigPolicyId   `xml:"xades:SigPolicyId"`
+	SigPolicyHash SigPolicyHash `xml:"xades:SigPolicyHash"`
 }
 
 type SigPolicyId struct {
-	Identifier  string `xml:"Identifier"`
-	Description string `xml:"Description,omitempty"`
+	Identifier  string `xml:"xades:Identifier"`
+	Description string `xml:"xades:Description,omitempty"`
 }
 
 type SigPolicyHash struct {
-	DigestMethod DigestMethod `xml:"http://www.w3.org/2000/09/xmldsig# DigestMethod"`
-	DigestValue  string       `xml:"http://www.w3.org/2000/09/xmldsig# DigestValue"`
+	DigestMethod DigestMethod `xml:"ds:DigestMethod"`
+	DigestValue  string       `xml:"ds:DigestValue"`
+}
+
+type SignerRole struct {
+	ClaimedRoles ClaimedRoles `xml:"xades:ClaimedRoles"`
+}
+
+type ClaimedRoles struct {
+	ClaimedRole []string `xml:"xades:ClaimedRole"`
 }
 
 // LoadCertificate carga un certificado desde un archivo PEM
-// Solo acepta archivos .pem (no P12)
+// Solo acepta archivos .pem; para .p12/.pfx use LoadPKCS12
 func LoadCertificate(path string) (*x509.Certificate, *rsa.PrivateKey, error) {
-	if !strings.HasSuffix(strings.ToLower(path), ".pem") {
-		return nil, nil, fmt.Errorf("solo se aceptan archivos PEM (.pem). Use un convertidor para transformar P12 a PEM")
-	}
-	return loadFromPEM(path)
+	cert, _, key, err := loadFromPEM(path, nil)
+	return cert, key, err
 }
 
-func loadFromPEM(pemPath string) (*x509.Certificate, *rsa.PrivateKey, error) {
+func loadFromPEM(pemPath string, password PasswordFunc) (*x509.Certificate, []*x509.Certificate, *rsa.PrivateKey, error) {
+	if !strings.HasSuffix(strings.ToLower(pemPath), ".pem") {
+		return nil, nil, nil, fmt.Errorf("solo se aceptan archivos PEM (.pem). Para archivos .p12/.pfx use LoadPKCS12")
+	}
+
 	pemData, err := os.ReadFile(pemPath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error leyendo archivo PEM: %w", err)
+		return nil, nil, nil, fmt.Errorf("error leyendo archivo PEM: %w", err)
 	}
 
-	return parsePEMData(pemData)
+	return parsePEMData(pemData, password)
 }
 
 // LoadPEMStrings carga certificado y clave privada desde strings PEM
 func LoadPEMStrings(certPEM, keyPEM string) (*x509.Certificate, *rsa.PrivateKey, error) {
-	// Combinar ambos strings
-	pemData := []byte(certPEM + "\n" + keyPEM)
-	return parsePEMData(pemData)
+	cert, _, key, err := parsePEMData([]byte(certPEM+"\n"+keyPEM), nil)
+	return cert, key, err
+}
+
+// LoadPEMStringsWithPassword carga certificado y clave privada desde strings PEM,
+// donde la clave puede estar cifrada ("ENCRYPTED PRIVATE KEY")
+func LoadPEMStringsWithPassword(certPEM, keyPEM string, password PasswordFunc) (*x509.Certificate, *rsa.PrivateKey, error) {
+	cert, _, key, err := parsePEMData([]byte(certPEM+"\n"+keyPEM), password)
+	return cert, key, err
 }
 
-func parsePEMData(pemData []byte) (*x509.Certificate, *rsa.PrivateKey, error) {
-	var cert *x509.Certificate
+// parsePEMData extrae la clave privada, el certificado firmante (el que corresponde a la clave)
+// y los certificados restantes ordenados como cadena (intermedias y raíz de la entidad certificadora).
+// password solo se invoca si la clave está cifrada.
+func parsePEMData(pemData []byte, password PasswordFunc) (*x509.Certificate, []*x509.Certificate, *rsa.PrivateKey, error) {
+	var certs []*x509.Certificate
 	var key *rsa.PrivateKey
-	var err error
 
 	rest := pemData
 	for {
@@ -172,131 +213,91 @@ func parsePEMData(pemData []byte) (*x509.Certificate, *rsa.PrivateKey, error) {
 
 		switch block.Type {
 		case "CERTIFICATE":
-			if cert == nil {
-				cert, err = x509.ParseCertificate(block.Bytes)
-				if err != nil {
-					return nil, nil, fmt.Errorf("error parseando certificado: %w", err)
-				}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("error parseando certificado: %w", err)
 			}
+			certs = append(certs, cert)
 
 		case "PRIVATE KEY":
 			parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
 			if err != nil {
-				return nil, nil, fmt.Errorf("error parseando clave PKCS8: %w", err)
+				return nil, nil, nil, fmt.Errorf("error parseando clave PKCS8: %w", err)
 			}
 			var ok bool
 			key, ok = parsedKey.(*rsa.PrivateKey)
 			if !ok {
-				return nil, nil, fmt.Errorf("la clave privada no es RSA")
+				return nil, nil, nil, fmt.Errorf("la clave privada no es RSA")
+			}
+
+		case "ENCRYPTED PRIVATE KEY":
+			var err error
+			key, err = decryptPKCS8(block.Bytes, password)
+			if err != nil {
+				return nil, nil, nil, err
 			}
 
 		case "RSA PRIVATE KEY":
+			var err error
 			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
 			if err != nil {
-				return nil, nil, fmt.Errorf("error parseando clave RSA: %w", err)
+				return nil, nil, nil, fmt.Errorf("error parseando clave RSA: %w", err)
 			}
 		}
 	}
 
-	if cert == nil || key == nil {
-		return nil, nil, fmt.Errorf("certificado o clave privada no encontrados en PEM")
+	if len(certs) == 0 || key == nil {
+		return nil, nil, nil, fmt.Errorf("certificado o clave privada no encontrados en PEM")
 	}
 
-	return cert, key, nil
-}
-
-func SignXMLDocument(xmlData []byte, cert *x509.Certificate, privateKey *rsa.PrivateKey) ([]byte, error) {
-	hash := sha256.Sum256(xmlData)
-	digestValue := base64.StdEncoding.EncodeToString(hash[:])
-
-	signedInfo := SignedInfo{
-		CanonicalizationMethod: CanonicalizationMethod{
-			Algorithm: "http://www.w3.org/TR/2001/REC-xml-c14n-20010315",
-		},
-		SignatureMethod: SignatureMethod{
-			Algorithm: "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256",
-		},
-		Reference: []Reference{
-			{
-				URI: "",
-				Transforms: &Transforms{
-					Transform: []Transform{
-						{Algorithm: "http://www.w3.org/2000/09/xmldsig#enveloped-signature"},
-					},
-				},
-				DigestMethod: DigestMethod{
-					Algorithm: "http://www.w3.org/2001/04/xmlenc#sha256",
-				},
-				DigestValue: digestValue,
-			},
-		},
-	}
+	leaf, chain := splitChain(certs, &key.PublicKey)
+	return leaf, chain, key, nil
+}
 
-	signedInfoXML, err := xml.Marshal(signedInfo)
-	if err != nil {
-		return nil, fmt.Errorf("error serializando SignedInfo: %w", err)
+// splitChain separa el certificado de la clave privada del resto y ordena la cadena
+// desde el emisor del certificado firmante hacia la raíz
+func splitChain(certs []*x509.Certificate, public crypto.PublicKey) (*x509.Certificate, []*x509.Certificate) {
+	leafIndex := 0
+	for i, cert := range certs {
+		if pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && pub.Equal(public) {
+			leafIndex = i
+			break
+		}
 	}
+	leaf := certs[leafIndex]
 
-	signedInfoHash := sha256.Sum256(signedInfoXML)
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, signedInfoHash[:])
-	if err != nil {
-		return nil, fmt.Errorf("error firmando: %w", err)
+	var pending []*x509.Certificate
+	for i, cert := range certs {
+		if i != leafIndex {
+			pending = append(pending, cert)
+		}
 	}
 
-	certDER := base64.StdEncoding.EncodeToString(cert.Raw)
-
-	sig := Signature{
-		ID:         "xmldsig-signature",
-		SignedInfo: signedInfo,
-		SignatureValue: SignatureValue{
-			Value: base64.StdEncoding.EncodeToString(signature),
-		},
-		KeyInfo: KeyInfo{
-			X509Data: X509Data{
-				X509Certificate: certDER,
-			},
-		},
-		Object: &Object{
-			QualifyingProperties: QualifyingProperties{
-				Target: "#xmldsig-signature",
-				SignedProperties: SignedProperties{
-					ID: "xmldsig-signedproperties",
-					SignedSignatureProperties: SignedSignatureProperties{
-						SigningTime: time.Now().Format(time.RFC3339),
-						SigningCertificate: SigningCertificate{
-							Cert: []Cert{
-								{
-									CertDigest: CertDigest{
-										DigestMethod: DigestMethod{
-											Algorithm: "http://www.w3.org/2001/04/xmlenc#sha256",
-										},
-										DigestValue: base64.StdEncoding.EncodeToString(hash[:]),
-									},
-									IssuerSerial: IssuerSerial{
-										X509IssuerName:   cert.Issuer.String(),
-										X509SerialNumber: cert.SerialNumber.String(),
-									},
-								},
-							},
-						},
-						SignaturePolicyIdentifier: SignaturePolicyIdentifier{
-							SignaturePolicyId: SignaturePolicyId{
-								SigPolicyId: SigPolicyId{
-									Identifier: "https://facturaelectronica.dian.gov.co/politicadefirma/v2/politicadefirmav2.pdf",
-								},
-								SigPolicyHash: SigPolicyHash{
-									DigestMethod: DigestMethod{
-										Algorithm: "http://www.w3.org/2001/04/xmlenc#sha256",
-									},
-									DigestValue: "dMoMvtcG5aIzgYo0tIsSQeVJBDnUnfSOfBpxXrmor0Y=",
-								},
-							},
-						},
-					},
-				},
-			},
-		},
+	return leaf, orderChain(leaf, pending)
+}
+
+// orderChain ordena los certificados de modo que cada uno sea emisor del anterior;
+// los que no encajan en la cadena se agregan al final en su orden original
+func orderChain(leaf *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	var chain []*x509.Certificate
+	pending := append([]*x509.Certificate(nil), certs...)
+	current := leaf
+
+	for len(pending) > 0 {
+		next := -1
+		for i, cert := range pending {
+			if bytes.Equal(cert.RawSubject, current.RawIssuer) && !bytes.Equal(cert.Raw, current.Raw) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		current = pending[next]
+		chain = append(chain, current)
+		pending = append(pending[:next], pending[next+1:]...)
 	}
 
-	return xml.MarshalIndent(sig, "      ", "  ")
+	return append(chain, pending...)
 }
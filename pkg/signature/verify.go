@@ -0,0 +1,565 @@
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/diegofxm/go-dian/pkg/c14n"
+)
+
+// namespaceExt es el namespace de UBLExtensions
+const namespaceExt = "urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2"
+
+// VerificationReport es el resultado de verificar la firma de un documento UBL
+type VerificationReport struct {
+	Valid       bool                // true si todas las verificaciones fueron exitosas
+	SignatureID string              // Id del ds:Signature
+	Certificate *x509.Certificate   // Certificado firmante
+	Chain       []*x509.Certificate // Certificados adicionales incluidos en KeyInfo
+	Subject     string              // Sujeto del certificado firmante (RFC 2253)
+	SigningTime time.Time           // xades:SigningTime
+	Role        string              // xades:ClaimedRole
+
+	PolicyIdentifier string // Identificador de la política de firma
+	PolicyHash       string // Digest declarado de la política de firma
+
+	SignatureValueValid   bool // SignatureValue corresponde a SignedInfo y al certificado
+	SignedPropertiesValid bool // SignedProperties está referenciado y es consistente con el certificado
+
+	References []ReferenceResult // Resultado por cada ds:Reference
+	Errors     []string          // Descripción de cada verificación fallida
+}
+
+// ReferenceResult es el resultado de verificar una ds:Reference
+type ReferenceResult struct {
+	URI      string
+	Type     string
+	Expected string // DigestValue declarado
+	Actual   string // DigestValue calculado
+	Valid    bool
+	Error    string
+}
+
+// transformMethod es un ds:Transform o ds:CanonicalizationMethod con su ec:InclusiveNamespaces opcional
+type transformMethod struct {
+	Algorithm           string `xml:"Algorithm,attr"`
+	InclusiveNamespaces struct {
+		PrefixList string `xml:"PrefixList,attr"`
+	} `xml:"http://www.w3.org/2001/10/xml-exc-c14n# InclusiveNamespaces"`
+}
+
+// c14nOptions retorna las opciones de canonicalización del algoritmo declarado
+func (t transformMethod) c14nOptions() (c14n.Options, error) {
+	opts, err := c14n.ForAlgorithm(t.Algorithm)
+	if err != nil {
+		return opts, err
+	}
+	if opts.Exclusive {
+		opts.InclusivePrefixes = strings.Fields(t.InclusiveNamespaces.PrefixList)
+	}
+	return opts, nil
+}
+
+// parsedSignature representa un ds:Signature leído de un documento.
+// encoding/xml aplica el namespace del tag a todos los elementos de una ruta,
+// por eso las rutas que mezclan ds y xades se resuelven por nombre local.
+type parsedSignature struct {
+	ID         string `xml:"Id,attr"`
+	SignedInfo struct {
+		CanonicalizationMethod transformMethod `xml:"http://www.w3.org/2000/09/xmldsig# CanonicalizationMethod"`
+		SignatureMethod        struct {
+			Algorithm string `xml:"Algorithm,attr"`
+		} `xml:"http://www.w3.org/2000/09/xmldsig# SignatureMethod"`
+		Reference []struct {
+			Type         string            `xml:"Type,attr"`
+			URI          string            `xml:"URI,attr"`
+			Transforms   []transformMethod `xml:"http://www.w3.org/2000/09/xmldsig# Transforms>Transform"`
+			DigestMethod struct {
+				Algorithm string `xml:"Algorithm,attr"`
+			} `xml:"http://www.w3.org/2000/09/xmldsig# DigestMethod"`
+			DigestValue string `xml:"http://www.w3.org/2000/09/xmldsig# DigestValue"`
+		} `xml:"http://www.w3.org/2000/09/xmldsig# Reference"`
+	} `xml:"http://www.w3.org/2000/09/xmldsig# SignedInfo"`
+	SignatureValue   string   `xml:"http://www.w3.org/2000/09/xmldsig# SignatureValue"`
+	X509Certificates []string `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo>X509Data>X509Certificate"`
+	Objects          []struct {
+		Qualifying []qualifyingProperties `xml:"http://uri.etsi.org/01903/v1.3.2# QualifyingProperties"`
+	} `xml:"http://www.w3.org/2000/09/xmldsig# Object"`
+}
+
+// qualifyingProperties representa xades:QualifyingProperties. SignedProperties es una lista
+// para rechazar firmas con más de uno.
+type qualifyingProperties struct {
+	Target           string             `xml:"Target,attr"`
+	SignedProperties []signedProperties `xml:"http://uri.etsi.org/01903/v1.3.2# SignedProperties"`
+}
+
+// signedProperties representa xades:SignedProperties
+type signedProperties struct {
+	ID          string `xml:"Id,attr"`
+	SigningTime string `xml:"http://uri.etsi.org/01903/v1.3.2# SignedSignatureProperties>SigningTime"`
+	Certs       []struct {
+		DigestMethod struct {
+			Algorithm string `xml:"Algorithm,attr"`
+		} `xml:"CertDigest>DigestMethod"`
+		DigestValue  string `xml:"CertDigest>DigestValue"`
+		SerialNumber string `xml:"IssuerSerial>X509SerialNumber"`
+	} `xml:"http://uri.etsi.org/01903/v1.3.2# SignedSignatureProperties>SigningCertificate>Cert"`
+	PolicyIdentifier string `xml:"http://uri.etsi.org/01903/v1.3.2# SignedSignatureProperties>SignaturePolicyIdentifier>SignaturePolicyId>SigPolicyId>Identifier"`
+	PolicyHash       string `xml:"SignedSignatureProperties>SignaturePolicyIdentifier>SignaturePolicyId>SigPolicyHash>DigestValue"`
+	ClaimedRole      string `xml:"http://uri.etsi.org/01903/v1.3.2# SignedSignatureProperties>SignerRole>ClaimedRoles>ClaimedRole"`
+}
+
+// qualifying retorna el único xades:QualifyingProperties de la firma y su único SignedProperties.
+// Un ds:Object adicional no está cubierto por la referencia enveloped (que excluye ds:Signature),
+// así que más de uno permitiría reportar propiedades que nadie firmó.
+func (s *parsedSignature) qualifying() (*qualifyingProperties, *signedProperties, error) {
+	var found []qualifyingProperties
+	for _, object := range s.Objects {
+		found = append(found, object.Qualifying...)
+	}
+	switch {
+	case len(found) == 0:
+		return nil, nil, fmt.Errorf("la firma no contiene xades:SignedProperties")
+	case len(found) > 1:
+		return nil, nil, fmt.Errorf("la firma debe contener exactamente un xades:QualifyingProperties (%d)", len(found))
+	case len(found[0].SignedProperties) != 1:
+		return nil, nil, fmt.Errorf("xades:QualifyingProperties debe contener exactamente un SignedProperties (%d)", len(found[0].SignedProperties))
+	}
+	return &found[0], &found[0].SignedProperties[0], nil
+}
+
+// Verify verifica la firma XAdES de un documento UBL firmado (factura, nota, ApplicationResponse, etc.):
+// digests de cada referencia, SignatureValue contra el certificado de KeyInfo y SignedProperties.
+// El documento debe tener una sola firma con una referencia URI="" enveloped-signature, y las
+// referencias "#id" deben resolver a un único elemento dentro de la firma o a la raíz.
+//
+// Retorna error solo si la firma no puede leerse; las verificaciones fallidas
+// se registran en el reporte con Valid en false.
+func Verify(xmlData []byte) (*VerificationReport, error) {
+	sig, err := findSignature(xmlData)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerificationReport{SignatureID: sig.ID}
+	qualifying, props, qualifyingErr := sig.qualifying()
+
+	// 1. Certificado firmante y cadena
+	for i, certB64 := range sig.X509Certificates {
+		der, err := base64.StdEncoding.DecodeString(stripSpaces(certB64))
+		if err != nil {
+			return nil, fmt.Errorf("certificado en base64 inválido: %w", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("certificado inválido: %w", err)
+		}
+		if i == 0 {
+			report.Certificate = cert
+		} else {
+			report.Chain = append(report.Chain, cert)
+		}
+	}
+	if report.Certificate == nil {
+		return nil, fmt.Errorf("KeyInfo no contiene un certificado X509")
+	}
+	if report.Subject, err = FormatRFC2253(report.Certificate.RawSubject); err != nil {
+		report.Subject = report.Certificate.Subject.String()
+	}
+
+	// 2. Referencias. Para evitar XML Signature Wrapping el documento debe tener una sola firma,
+	// cada Id debe ser único y las referencias solo pueden apuntar a la firma o a la raíz
+	index, err := indexDocument(xmlData)
+	if err != nil {
+		return nil, err
+	}
+	if index.signatures != 1 {
+		report.Errors = append(report.Errors, fmt.Sprintf("el documento debe contener exactamente un ds:Signature (%d)", index.signatures))
+	}
+	if index.signedInfos != 1 {
+		report.Errors = append(report.Errors, fmt.Sprintf("el documento debe contener exactamente un ds:SignedInfo (%d)", index.signedInfos))
+	}
+	documentReferences := 0
+
+	excludeSignature := func(start xml.StartElement) bool {
+		if start.Name.Space != NamespaceDS || start.Name.Local != "Signature" {
+			return false
+		}
+		return sig.ID == "" || attrValue(start, "Id") == sig.ID
+	}
+	signedPropsReferenced := false
+	for _, ref := range sig.SignedInfo.Reference {
+		result := ReferenceResult{
+			URI:      ref.URI,
+			Type:     ref.Type,
+			Expected: strings.TrimSpace(ref.DigestValue),
+		}
+
+		// Sin transformación de canonicalización se aplica Canonical XML 1.0.
+		// Las referencias al mismo documento descartan comentarios (XPointer).
+		opts := c14n.Options{}
+		enveloped := false
+		for _, transform := range ref.Transforms {
+			if transform.Algorithm == AlgorithmEnveloped {
+				enveloped = true
+				continue
+			}
+			transformOpts, err := transform.c14nOptions()
+			if err != nil {
+				result.Error = fmt.Sprintf("transformación no soportada: %s", transform.Algorithm)
+				continue
+			}
+			opts = transformOpts
+		}
+		opts.WithComments = false
+		if enveloped {
+			opts.Exclude = excludeSignature
+		}
+
+		if result.Error == "" {
+			switch {
+			case ref.URI == "":
+				documentReferences++
+				if !enveloped {
+					result.Error = "la referencia al documento no aplica la transformación enveloped-signature"
+				}
+			case strings.HasPrefix(ref.URI, "#"):
+				result.Error = index.checkReference(strings.TrimPrefix(ref.URI, "#"))
+			default:
+				result.Error = "URI externa no soportada"
+			}
+		}
+
+		var canonical []byte
+		if result.Error == "" {
+			if ref.URI == "" {
+				canonical, err = c14n.Canonicalize(xmlData, opts)
+			} else {
+				canonical, err = c14n.CanonicalizeSubtree(xmlData, c14n.ByID(strings.TrimPrefix(ref.URI, "#")), opts)
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+		}
+
+		if result.Error == "" {
+			h, err := newDigest(ref.DigestMethod.Algorithm)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				h.Write(canonical)
+				result.Actual = base64.StdEncoding.EncodeToString(h.Sum(nil))
+				result.Valid = result.Actual == result.Expected
+				if !result.Valid {
+					result.Error = "el digest no coincide"
+				}
+			}
+		}
+
+		// Solo cuenta la referencia al SignedProperties de la firma; las propiedades del
+		// reporte se toman de ese elemento una vez verificado su digest
+		pointsToProps := props != nil && props.ID != "" && ref.URI == "#"+props.ID
+		if ref.Type == TypeSignedProperties && !pointsToProps && result.Valid {
+			result.Valid = false
+			result.Error = "la referencia de tipo SignedProperties no apunta al xades:SignedProperties de la firma"
+		}
+		if pointsToProps {
+			signedPropsReferenced = result.Valid
+		}
+		if !result.Valid {
+			report.Errors = append(report.Errors, fmt.Sprintf("referencia %q: %s", ref.URI, result.Error))
+		}
+		report.References = append(report.References, result)
+	}
+	if len(report.References) == 0 {
+		report.Errors = append(report.Errors, "SignedInfo no contiene referencias")
+	}
+	if documentReferences != 1 {
+		report.Errors = append(report.Errors, fmt.Sprintf("SignedInfo debe contener exactamente una referencia al documento (URI=\"\"), tiene %d", documentReferences))
+	}
+
+	// 3. SignatureValue sobre SignedInfo canonicalizado
+	if err := verifySignatureValue(xmlData, sig, report.Certificate); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("SignatureValue: %v", err))
+	} else {
+		report.SignatureValueValid = true
+	}
+
+	// 4. Propiedades XAdES
+	var xadesErrors []string
+	if qualifyingErr != nil {
+		xadesErrors = []string{qualifyingErr.Error()}
+	} else {
+		xadesErrors = checkSignedProperties(sig.ID, qualifying, props, report, signedPropsReferenced)
+	}
+	report.SignedPropertiesValid = len(xadesErrors) == 0
+	report.Errors = append(report.Errors, xadesErrors...)
+
+	report.Valid = len(report.Errors) == 0
+	return report, nil
+}
+
+// findSignature ubica el primer ds:Signature dentro de ext:ExtensionContent
+func findSignature(xmlData []byte) (*parsedSignature, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(xmlData))
+	depthInExtension := 0
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("el documento no contiene ds:Signature en UBLExtensions")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("XML inválido: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depthInExtension > 0 {
+				depthInExtension++
+			}
+			if t.Name.Space == namespaceExt && t.Name.Local == "ExtensionContent" {
+				depthInExtension = 1
+				continue
+			}
+			if depthInExtension > 0 && t.Name.Space == NamespaceDS && t.Name.Local == "Signature" {
+				var sig parsedSignature
+				if err := decoder.DecodeElement(&sig, &t); err != nil {
+					return nil, fmt.Errorf("ds:Signature inválido: %w", err)
+				}
+				return &sig, nil
+			}
+		case xml.EndElement:
+			if depthInExtension > 0 {
+				depthInExtension--
+			}
+		}
+	}
+}
+
+// documentIndex resume los elementos del documento relevantes para resolver referencias sin ambigüedad
+type documentIndex struct {
+	signatures  int                    // ds:Signature en todo el documento
+	signedInfos int                    // ds:SignedInfo en todo el documento
+	ids         map[string]*idLocation // elementos por valor de atributo Id/ID/id
+}
+
+// idLocation describe dónde aparece un Id del documento
+type idLocation struct {
+	count       int  // elementos con el mismo Id
+	root        bool // el Id pertenece al elemento raíz
+	inSignature bool // el Id pertenece a un elemento dentro de ds:Signature
+}
+
+// indexDocument recorre el documento contando firmas y ubicando cada Id
+func indexDocument(xmlData []byte) (*documentIndex, error) {
+	index := &documentIndex{ids: make(map[string]*idLocation)}
+	decoder := xml.NewDecoder(bytes.NewReader(xmlData))
+	depth, signatureDepth := 0, 0
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return index, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("XML inválido: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if t.Name.Space == NamespaceDS && t.Name.Local == "Signature" {
+				index.signatures++
+				if signatureDepth == 0 {
+					signatureDepth = depth
+				}
+			}
+			if t.Name.Space == NamespaceDS && t.Name.Local == "SignedInfo" {
+				index.signedInfos++
+			}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || (attr.Name.Local != "Id" && attr.Name.Local != "ID" && attr.Name.Local != "id") {
+					continue
+				}
+				location := index.ids[attr.Value]
+				if location == nil {
+					location = &idLocation{}
+					index.ids[attr.Value] = location
+				}
+				location.count++
+				location.root = location.root || depth == 1
+				location.inSignature = location.inSignature || signatureDepth > 0
+			}
+		case xml.EndElement:
+			if depth == signatureDepth {
+				signatureDepth = 0
+			}
+			depth--
+		}
+	}
+}
+
+// checkReference retorna la descripción del problema de una referencia "#id", o "" si resuelve
+// a un único elemento dentro de la firma o a la raíz del documento
+func (d *documentIndex) checkReference(id string) string {
+	location := d.ids[id]
+	switch {
+	case id == "" || location == nil:
+		return "el Id referenciado no existe"
+	case location.count > 1:
+		return fmt.Sprintf("el Id referenciado aparece en %d elementos", location.count)
+	case !location.root && !location.inSignature:
+		return "la referencia apunta fuera de la firma y de la raíz del documento"
+	}
+	return ""
+}
+
+// verifySignatureValue valida SignatureValue contra SignedInfo canonicalizado
+func verifySignatureValue(xmlData []byte, sig *parsedSignature, cert *x509.Certificate) error {
+	opts, err := sig.SignedInfo.CanonicalizationMethod.c14nOptions()
+	if err != nil {
+		return fmt.Errorf("canonicalización no soportada: %s", sig.SignedInfo.CanonicalizationMethod.Algorithm)
+	}
+
+	hashFunc, err := signatureHash(sig.SignedInfo.SignatureMethod.Algorithm)
+	if err != nil {
+		return err
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("la llave pública no es RSA")
+	}
+
+	canonical, err := c14n.CanonicalizeSubtree(xmlData, c14n.ByName(NamespaceDS, "SignedInfo"), opts)
+	if err != nil {
+		return fmt.Errorf("error canonicalizando SignedInfo: %w", err)
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(stripSpaces(sig.SignatureValue))
+	if err != nil {
+		return fmt.Errorf("base64 inválido: %w", err)
+	}
+
+	h := hashFunc.New()
+	h.Write(canonical)
+	if err := rsa.VerifyPKCS1v15(publicKey, hashFunc, h.Sum(nil), signatureBytes); err != nil {
+		return fmt.Errorf("firma inválida: %w", err)
+	}
+	return nil
+}
+
+// checkSignedProperties valida las propiedades XAdES-EPES y completa con ellas el reporte.
+// Si SignedProperties no está cubierto por una referencia válida sus valores no se reportan.
+func checkSignedProperties(signatureID string, qualifying *qualifyingProperties, props *signedProperties, report *VerificationReport, referenced bool) []string {
+	if props.ID == "" {
+		return []string{"xades:SignedProperties no tiene Id"}
+	}
+	if !referenced {
+		return []string{"SignedProperties no está cubierto por una referencia válida"}
+	}
+
+	var errs []string
+	if signatureID != "" && qualifying.Target != "#"+signatureID {
+		errs = append(errs, fmt.Sprintf("QualifyingProperties Target %q no corresponde a la firma", qualifying.Target))
+	}
+
+	report.Role = strings.TrimSpace(props.ClaimedRole)
+	report.PolicyIdentifier = strings.TrimSpace(props.PolicyIdentifier)
+	report.PolicyHash = strings.TrimSpace(props.PolicyHash)
+
+	signingTime := strings.TrimSpace(props.SigningTime)
+	parsed, err := time.Parse(time.RFC3339Nano, signingTime)
+	if err != nil {
+		parsed, err = time.Parse("2006-01-02T15:04:05", signingTime)
+	}
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("SigningTime inválido: %q", signingTime))
+	} else {
+		report.SigningTime = parsed
+	}
+
+	if report.PolicyIdentifier == "" {
+		errs = append(errs, "la firma no declara política de firma")
+	} else if report.PolicyIdentifier == PolicyIdentifier && report.PolicyHash != PolicyHash {
+		errs = append(errs, "el digest de la política de firma no corresponde a la política DIAN v2")
+	}
+
+	// SigningCertificate debe incluir el certificado firmante con su digest y serial
+	signerFound := false
+	for _, cert := range props.Certs {
+		h, err := newDigest(cert.DigestMethod.Algorithm)
+		if err != nil {
+			continue
+		}
+		h.Write(report.Certificate.Raw)
+		if base64.StdEncoding.EncodeToString(h.Sum(nil)) != strings.TrimSpace(cert.DigestValue) {
+			continue
+		}
+		signerFound = true
+		if strings.TrimSpace(cert.SerialNumber) != report.Certificate.SerialNumber.String() {
+			errs = append(errs, "el serial de SigningCertificate no corresponde al certificado firmante")
+		}
+	}
+	if !signerFound {
+		errs = append(errs, "SigningCertificate no contiene el digest del certificado firmante")
+	}
+
+	return errs
+}
+
+// newDigest retorna el hash para un algoritmo DigestMethod
+func newDigest(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case AlgorithmSHA256:
+		return sha256.New(), nil
+	case "http://www.w3.org/2001/04/xmldsig-more#sha384":
+		return sha512.New384(), nil
+	case "http://www.w3.org/2001/04/xmlenc#sha512":
+		return sha512.New(), nil
+	case "http://www.w3.org/2000/09/xmldsig#sha1":
+		return sha1.New(), nil
+	}
+	return nil, fmt.Errorf("algoritmo de digest no soportado: %s", algorithm)
+}
+
+// signatureHash retorna el hash para un algoritmo SignatureMethod RSA
+func signatureHash(algorithm string) (crypto.Hash, error) {
+	switch algorithm {
+	case AlgorithmRSASHA256:
+		return crypto.SHA256, nil
+	case "http://www.w3.org/2001/04/xmldsig-more#rsa-sha384":
+		return crypto.SHA384, nil
+	case "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512":
+		return crypto.SHA512, nil
+	case "http://www.w3.org/2000/09/xmldsig#rsa-sha1":
+		return crypto.SHA1, nil
+	}
+	return 0, fmt.Errorf("algoritmo de firma no soportado: %s", algorithm)
+}
+
+func attrValue(start xml.StartElement, local string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// stripSpaces elimina los saltos de línea y espacios del contenido base64
+func stripSpaces(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
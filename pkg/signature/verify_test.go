@@ -0,0 +1,245 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diegofxm/go-dian/pkg/c14n"
+)
+
+const testDocument = `<Invoice xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2" xmlns:ext="urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2" xmlns:cbc="urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2">` +
+	`<ext:UBLExtensions><ext:UBLExtension><ext:ExtensionContent></ext:ExtensionContent></ext:UBLExtension></ext:UBLExtensions>` +
+	`<cbc:ID>SETP990000001</cbc:ID><cbc:Note>Factura de prueba</cbc:Note></Invoice>`
+
+// signTestDocument firma testDocument con un certificado autofirmado
+func signTestDocument(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "EMISOR DE PRUEBA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := SignDocument([]byte(testDocument), cert, key, SignOptions{})
+	if err != nil {
+		t.Fatalf("SignDocument: %v", err)
+	}
+	return string(signed), key
+}
+
+var signatureValuePattern = regexp.MustCompile(`(<ds:SignatureValue[^>]*>)[^<]*(</ds:SignatureValue>)`)
+
+// resign recalcula SignatureValue sobre el SignedInfo modificado, como lo haría el titular de la clave
+func resign(t *testing.T, doc string, key *rsa.PrivateKey) string {
+	t.Helper()
+
+	canonical, err := c14n.CanonicalizeSubtree([]byte(doc), c14n.ByName(NamespaceDS, "SignedInfo"), c14n.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := sha256.Sum256(canonical)
+	value, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signatureValuePattern.ReplaceAllString(doc, "${1}"+base64.StdEncoding.EncodeToString(value)+"${2}")
+}
+
+// between retorna la primera subcadena que inicia en start y termina en end (inclusive)
+func between(t *testing.T, s, start, end string) string {
+	t.Helper()
+
+	i := strings.Index(s, start)
+	if i < 0 {
+		t.Fatalf("no se encontró %q", start)
+	}
+	j := strings.Index(s[i:], end)
+	if j < 0 {
+		t.Fatalf("no se encontró %q", end)
+	}
+	return s[i : i+j+len(end)]
+}
+
+func TestVerifyValid(t *testing.T) {
+	signed, _ := signTestDocument(t)
+
+	report, err := Verify([]byte(signed))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !report.Valid {
+		t.Fatalf("firma inválida: %v", report.Errors)
+	}
+	if report.Role != RoleSupplier {
+		t.Errorf("Role = %q, se esperaba %q", report.Role, RoleSupplier)
+	}
+}
+
+// TestVerifySignatureWrapping cubre variantes de XML Signature Wrapping: elementos agregados
+// dentro de ds:Signature no alteran el digest del documento (enveloped-signature los excluye)
+func TestVerifySignatureWrapping(t *testing.T) {
+	signed, key := signTestDocument(t)
+	object := between(t, signed, "<ds:Object>", "</ds:Object>")
+	signedInfo := between(t, signed, "<ds:SignedInfo>", "</ds:SignedInfo>")
+	documentReference := between(t, signed, `<ds:Reference Id="`, "</ds:Reference>")
+
+	tests := []struct {
+		name   string
+		doc    string
+		errors []string
+	}{
+		{
+			// El verificador digiere el primer SignedProperties (original) y el lector toma el último (alterado)
+			name: "SignedProperties duplicado",
+			doc: strings.Replace(signed, object,
+				object+strings.Replace(object, "<xades:ClaimedRole>supplier<", "<xades:ClaimedRole>third party<", 1), 1),
+			errors: []string{"el Id referenciado aparece en 2 elementos"},
+		},
+		{
+			// SignatureValue se verifica sobre el primer SignedInfo y las referencias se leen del último
+			name:   "SignedInfo duplicado",
+			doc:    strings.Replace(signed, signedInfo, signedInfo+signedInfo, 1),
+			errors: []string{"exactamente un ds:SignedInfo (2)"},
+		},
+		{
+			name: "segunda firma",
+			doc: strings.Replace(signed, "</ext:UBLExtensions>",
+				"<ext:UBLExtension><ext:ExtensionContent>"+between(t, signed, "<ds:Signature ", "</ds:Signature>")+"</ext:ExtensionContent></ext:UBLExtension></ext:UBLExtensions>", 1),
+			errors: []string{"exactamente un ds:Signature (2)"},
+		},
+		{
+			name: "referencia a un elemento fuera de la firma",
+			doc: resign(t, strings.Replace(
+				strings.Replace(signed, "<cbc:Note>", `<cbc:Note Id="nota">`, 1),
+				`URI=""`, `URI="#nota"`, 1), key),
+			errors: []string{
+				"la referencia apunta fuera de la firma y de la raíz del documento",
+				`exactamente una referencia al documento (URI=""), tiene 0`,
+			},
+		},
+		{
+			name: "referencia al documento sin enveloped-signature",
+			doc: resign(t, strings.Replace(signed,
+				`<ds:Transforms><ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"></ds:Transform></ds:Transforms>`, "", 1), key),
+			errors: []string{"no aplica la transformación enveloped-signature"},
+		},
+		{
+			name:   "dos referencias al documento",
+			doc:    resign(t, strings.Replace(signed, documentReference, documentReference+documentReference, 1), key),
+			errors: []string{`exactamente una referencia al documento (URI=""), tiene 2`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.doc == signed {
+				t.Fatal("el documento no fue modificado")
+			}
+			report, err := Verify([]byte(tt.doc))
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if report.Valid {
+				t.Fatal("Verify aceptó el documento alterado")
+			}
+			joined := strings.Join(report.Errors, "\n")
+			for _, expected := range tt.errors {
+				if !strings.Contains(joined, expected) {
+					t.Errorf("errores %q no incluyen %q", report.Errors, expected)
+				}
+			}
+		})
+	}
+}
+
+// TestVerifyInjectedQualifyingProperties cubre propiedades XAdES agregadas sin firmar: la referencia
+// enveloped excluye ds:Signature, así que ningún digest cambia y el reporte no debe tomarlas
+func TestVerifyInjectedQualifyingProperties(t *testing.T) {
+	signed, _ := signTestDocument(t)
+
+	const evilProps = `<xades:SignedProperties Id="evil"><xades:SignedSignatureProperties>` +
+		`<xades:SigningTime>2001-01-01T00:00:00Z</xades:SigningTime>` +
+		`<xades:SignerRole><xades:ClaimedRoles><xades:ClaimedRole>third party</xades:ClaimedRole></xades:ClaimedRoles></xades:SignerRole>` +
+		`</xades:SignedSignatureProperties></xades:SignedProperties>`
+	const evilQualifying = `<xades:QualifyingProperties xmlns:xades="http://uri.etsi.org/01903/v1.3.2#">` + evilProps + `</xades:QualifyingProperties>`
+
+	tests := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{
+			name: "segundo ds:Object",
+			doc:  strings.Replace(signed, "</ds:Signature>", "<ds:Object>"+evilQualifying+"</ds:Object></ds:Signature>", 1),
+			err:  "exactamente un xades:QualifyingProperties (2)",
+		},
+		{
+			name: "segundo QualifyingProperties en el mismo ds:Object",
+			doc:  strings.Replace(signed, "</ds:Object>", evilQualifying+"</ds:Object>", 1),
+			err:  "exactamente un xades:QualifyingProperties (2)",
+		},
+		{
+			name: "segundo SignedProperties",
+			doc:  strings.Replace(signed, "</xades:QualifyingProperties>", evilProps+"</xades:QualifyingProperties>", 1),
+			err:  "exactamente un SignedProperties (2)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.doc == signed {
+				t.Fatal("el documento no fue modificado")
+			}
+			report, err := Verify([]byte(tt.doc))
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if report.Valid {
+				t.Error("Verify aceptó propiedades XAdES sin firmar")
+			}
+			if !strings.Contains(strings.Join(report.Errors, "\n"), tt.err) {
+				t.Errorf("errores %q no incluyen %q", report.Errors, tt.err)
+			}
+			if report.Role == "third party" || report.SigningTime.Year() == 2001 {
+				t.Errorf("el reporte tomó propiedades no firmadas: Role %q, SigningTime %s", report.Role, report.SigningTime)
+			}
+		})
+	}
+}
+
+// TestVerifyReportsSignedProperties verifica que el reporte tome las propiedades del SignedProperties firmado
+func TestVerifyReportsSignedProperties(t *testing.T) {
+	signed, _ := signTestDocument(t)
+
+	report, err := Verify([]byte(signed))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if report.Role != RoleSupplier || report.PolicyIdentifier != PolicyIdentifier || report.SigningTime.IsZero() {
+		t.Errorf("Role %q, PolicyIdentifier %q, SigningTime %s", report.Role, report.PolicyIdentifier, report.SigningTime)
+	}
+}
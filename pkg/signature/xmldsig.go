@@ -1,6 +1,7 @@
 package signature
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
@@ -10,100 +11,213 @@ import (
 	"encoding/xml"
 	"fmt"
 	"time"
+
+	xmlutil "github.com/diegofxm/go-dian/internal/xml"
+	"github.com/diegofxm/go-dian/pkg/c14n"
+	"github.com/google/uuid"
 )
 
-func CreateXMLSignature(xmlData []byte, cert *x509.Certificate, privateKey *rsa.PrivateKey) (*Signature, error) {
-	hash := sha256.Sum256(xmlData)
-	digestValue := base64.StdEncoding.EncodeToString(hash[:])
+// SignOptions configura la firma XAdES-EPES
+type SignOptions struct {
+	Role        string              // SignerRole: RoleSupplier (por defecto) o RoleThirdParty
+	SigningTime time.Time           // Por defecto la hora actual
+	Chain       []*x509.Certificate // Certificados intermedios incluidos en KeyInfo y SigningCertificate
+}
 
-	signedInfo := SignedInfo{
-		CanonicalizationMethod: CanonicalizationMethod{
-			Algorithm: "http://www.w3.org/TR/2001/REC-xml-c14n-20010315",
-		},
-		SignatureMethod: SignatureMethod{
-			Algorithm: "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256",
-		},
-		Reference: []Reference{
-			{
-				URI: "",
-				Transforms: &Transforms{
-					Transform: []Transform{
-						{Algorithm: "http://www.w3.org/2000/09/xmldsig#enveloped-signature"},
-					},
-				},
-				DigestMethod: DigestMethod{
-					Algorithm: "http://www.w3.org/2001/04/xmlenc#sha256",
-				},
-				DigestValue: digestValue,
-			},
-		},
+// SignDocument firma un documento UBL con XAdES-EPES según la política de firma DIAN v2
+// y retorna el documento con la firma insertada en un nuevo UBLExtension.
+//
+// La firma contiene tres referencias, todas con C14N 1.0 inclusiva y SHA-256:
+// el documento (URI="" con transformación enveloped-signature), ds:KeyInfo y xades:SignedProperties.
+//
+// signer puede ser un *rsa.PrivateKey o cualquier crypto.Signer RSA (HSM, KMS, servicio remoto).
+func SignDocument(xmlData []byte, cert *x509.Certificate, signer crypto.Signer, opts SignOptions) ([]byte, error) {
+	if bytes.Contains(xmlData, []byte(NamespaceDS)) {
+		return nil, fmt.Errorf("el documento ya contiene una firma XMLDSig")
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("signer no configurado")
+	}
+	if _, ok := signer.Public().(*rsa.PublicKey); !ok {
+		return nil, fmt.Errorf("la política de firma DIAN requiere una clave RSA")
+	}
+
+	sig, err := newSignature(cert, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	signedInfoXML, err := xml.Marshal(signedInfo)
+	// 1. Digest del documento: la transformación enveloped-signature excluye ds:Signature,
+	// por lo que se canonicaliza el documento ya ensamblado para conservar el contenedor UBLExtension
+	doc, err := assemble(xmlData, sig)
 	if err != nil {
-		return nil, fmt.Errorf("error serializando SignedInfo: %w", err)
+		return nil, err
 	}
+	docCanonical, err := c14n.Canonicalize(doc, c14n.Options{Exclude: c14n.ByName(NamespaceDS, "Signature")})
+	if err != nil {
+		return nil, fmt.Errorf("error canonicalizando documento: %w", err)
+	}
+	sig.SignedInfo.Reference[0].DigestValue = digest(docCanonical)
+
+	// 2. Digests de KeyInfo y SignedProperties como subárboles del documento (heredan sus namespaces)
+	doc, err = assemble(xmlData, sig)
+	if err != nil {
+		return nil, err
+	}
+	keyInfoCanonical, err := c14n.CanonicalizeSubtree(doc, c14n.ByID(sig.KeyInfo.ID), c14n.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("error canonicalizando KeyInfo: %w", err)
+	}
+	sig.SignedInfo.Reference[1].DigestValue = digest(keyInfoCanonical)
 
-	signedInfoHash := sha256.Sum256(signedInfoXML)
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, signedInfoHash[:])
+	signedPropsID := sig.Object.QualifyingProperties.SignedProperties.ID
+	signedPropsCanonical, err := c14n.CanonicalizeSubtree(doc, c14n.ByID(signedPropsID), c14n.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("error canonicalizando SignedProperties: %w", err)
+	}
+	sig.SignedInfo.Reference[2].DigestValue = digest(signedPropsCanonical)
+
+	// 3. SignatureValue sobre SignedInfo canonicalizado
+	doc, err = assemble(xmlData, sig)
+	if err != nil {
+		return nil, err
+	}
+	signedInfoCanonical, err := c14n.CanonicalizeSubtree(doc, c14n.ByName(NamespaceDS, "SignedInfo"), c14n.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("error canonicalizando SignedInfo: %w", err)
+	}
+	signedInfoHash := sha256.Sum256(signedInfoCanonical)
+	signatureBytes, err := signer.Sign(rand.Reader, signedInfoHash[:], crypto.SHA256)
 	if err != nil {
 		return nil, fmt.Errorf("error firmando: %w", err)
 	}
+	sig.SignatureValue.Value = base64.StdEncoding.EncodeToString(signatureBytes)
 
-	certDER := base64.StdEncoding.EncodeToString(cert.Raw)
+	return assemble(xmlData, sig)
+}
+
+// newSignature construye la estructura de la firma con digests de referencias y SignatureValue vacíos
+func newSignature(cert *x509.Certificate, opts SignOptions) (*Signature, error) {
+	id := "xmldsig-" + uuid.New().String()
+
+	role := opts.Role
+	if role == "" {
+		role = RoleSupplier
+	}
+	signingTime := opts.SigningTime
+	if signingTime.IsZero() {
+		signingTime = time.Now()
+	}
+
+	sha256Method := DigestMethod{Algorithm: AlgorithmSHA256}
+
+	// KeyInfo y SigningCertificate incluyen el certificado firmante seguido de su cadena
+	var x509Certificates []string
+	var signingCerts []Cert
+	for _, c := range append([]*x509.Certificate{cert}, opts.Chain...) {
+		issuerName, err := FormatRFC2253(c.RawIssuer)
+		if err != nil {
+			return nil, fmt.Errorf("error formateando emisor del certificado: %w", err)
+		}
+		certHash := sha256.Sum256(c.Raw)
 
-	sig := &Signature{
-		ID:         "xmldsig-signature",
-		SignedInfo: signedInfo,
+		x509Certificates = append(x509Certificates, base64.StdEncoding.EncodeToString(c.Raw))
+		signingCerts = append(signingCerts, Cert{
+			CertDigest: CertDigest{
+				DigestMethod: sha256Method,
+				DigestValue:  base64.StdEncoding.EncodeToString(certHash[:]),
+			},
+			IssuerSerial: IssuerSerial{
+				X509IssuerName:   issuerName,
+				X509SerialNumber: c.SerialNumber.String(),
+			},
+		})
+	}
+
+	return &Signature{
+		XmlnsDS: NamespaceDS,
+		ID:      id,
+		SignedInfo: SignedInfo{
+			CanonicalizationMethod: CanonicalizationMethod{Algorithm: AlgorithmC14N},
+			SignatureMethod:        SignatureMethod{Algorithm: AlgorithmRSASHA256},
+			Reference: []Reference{
+				{
+					ID:  id + "-ref0",
+					URI: "",
+					Transforms: &Transforms{
+						Transform: []Transform{{Algorithm: AlgorithmEnveloped}},
+					},
+					DigestMethod: sha256Method,
+				},
+				{
+					URI:          "#" + id + "-keyinfo",
+					DigestMethod: sha256Method,
+				},
+				{
+					Type:         TypeSignedProperties,
+					URI:          "#" + id + "-signedprops",
+					DigestMethod: sha256Method,
+				},
+			},
+		},
 		SignatureValue: SignatureValue{
-			Value: base64.StdEncoding.EncodeToString(signature),
+			ID: id + "-sigvalue",
 		},
 		KeyInfo: KeyInfo{
+			ID: id + "-keyinfo",
 			X509Data: X509Data{
-				X509Certificate: certDER,
+				X509Certificate: x509Certificates,
 			},
 		},
-		Object: &Object{
+		Object: Object{
 			QualifyingProperties: QualifyingProperties{
-				Target: "#xmldsig-signature",
+				XmlnsXAdES:    NamespaceXAdES,
+				XmlnsXAdES141: NamespaceXAdES141,
+				Target:        "#" + id,
 				SignedProperties: SignedProperties{
-					ID: "xmldsig-signedproperties",
+					ID: id + "-signedprops",
 					SignedSignatureProperties: SignedSignatureProperties{
-						SigningTime: time.Now().Format(time.RFC3339),
+						SigningTime: signingTime.Format("2006-01-02T15:04:05.000-07:00"),
 						SigningCertificate: SigningCertificate{
-							Cert: []Cert{
-								{
-									CertDigest: CertDigest{
-										DigestMethod: DigestMethod{
-											Algorithm: "http://www.w3.org/2001/04/xmlenc#sha256",
-										},
-										DigestValue: base64.StdEncoding.EncodeToString(hash[:]),
-									},
-									IssuerSerial: IssuerSerial{
-										X509IssuerName:   cert.Issuer.String(),
-										X509SerialNumber: cert.SerialNumber.String(),
-									},
-								},
-							},
+							Cert: signingCerts,
 						},
 						SignaturePolicyIdentifier: SignaturePolicyIdentifier{
 							SignaturePolicyId: SignaturePolicyId{
 								SigPolicyId: SigPolicyId{
-									Identifier: "https://facturaelectronica.dian.gov.co/politicadefirma/v2/politicadefirmav2.pdf",
+									Identifier:  PolicyIdentifier,
+									Description: PolicyDescription,
 								},
 								SigPolicyHash: SigPolicyHash{
-									DigestMethod: DigestMethod{
-										Algorithm: "http://www.w3.org/2001/04/xmlenc#sha256",
-									},
-									DigestValue: "dMoMvtcG5aIzgYo0tIsSQeVJBDnUnfSOfBpxXrmor0Y=",
+									DigestMethod: sha256Method,
+									DigestValue:  PolicyHash,
 								},
 							},
 						},
+						SignerRole: SignerRole{
+							ClaimedRoles: ClaimedRoles{ClaimedRole: []string{role}},
+						},
 					},
 				},
 			},
 		},
+	}, nil
+}
+
+// assemble inserta la firma en UBLExtensions del documento original
+func assemble(xmlData []byte, sig *Signature) ([]byte, error) {
+	signatureXML, err := xml.Marshal(sig)
+	if err != nil {
+		return nil, fmt.Errorf("error serializando firma: %w", err)
+	}
+
+	doc, err := xmlutil.InsertSignature(xmlData, signatureXML)
+	if err != nil {
+		return nil, fmt.Errorf("error insertando firma: %w", err)
 	}
+	return doc, nil
+}
 
-	return sig, nil
+func digest(data []byte) string {
+	hash := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(hash[:])
 }
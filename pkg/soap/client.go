@@ -7,72 +7,132 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"github.com/diegofxm/go-dian/pkg/environment"
 	"github.com/diegofxm/go-dian/pkg/wssecurity"
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 // Environment representa el ambiente de DIAN
-type Environment string
+type Environment = environment.Environment
 
-const (
-	Test       Environment = "test"
-	Production Environment = "production"
+// Ambientes de DIAN
+var (
+	Test       = environment.Test
+	Production = environment.Production
 )
 
-// Endpoints de DIAN
-var Endpoints = map[Environment]string{
-	Test:       "https://vpfe-hab.dian.gov.co/WcfDianCustomerServices.svc",
-	Production: "https://vpfe.dian.gov.co/WcfDianCustomerServices.svc",
-}
-
 // Client es el cliente SOAP para DIAN
 type Client struct {
 	URL             string
 	Environment     Environment
-	Certificate     tls.Certificate
 	HTTPClient      *http.Client
 	EnvelopeBuilder *EnvelopeBuilder
-	HeaderBuilder   *wssecurity.HeaderBuilder
+	Verifier        *wssecurity.Verifier // Si no es nil, se rechazan respuestas sin firma WS-Security válida
+	Logger          *slog.Logger         // Opcional: registra nombre de archivo, tamaños y código HTTP de cada envío (nunca el contenido)
+
+	credentials atomic.Pointer[credentials]
+}
+
+// credentials agrupa el certificado mTLS y el builder WS-Security que lo usa,
+// para reemplazarlos juntos de forma atómica
+type credentials struct {
+	certificate   tls.Certificate
+	headerBuilder *wssecurity.HeaderBuilder
 }
 
 // NewClient crea un nuevo cliente SOAP con certificado para mTLS
-func NewClient(environment Environment, certPEMBlock, keyPEMBlock []byte) (*Client, error) {
+func NewClient(env Environment, certPEMBlock, keyPEMBlock []byte) (*Client, error) {
 	// Cargar certificado TLS
 	cert, err := tls.X509KeyPair(certPEMBlock, keyPEMBlock)
 	if err != nil {
 		return nil, fmt.Errorf("error cargando certificado: %w", err)
 	}
 
-	// Configurar TLS con mTLS
+	return NewClientWithCertificate(env, cert)
+}
+
+// NewClientFromPKCS12 crea un nuevo cliente SOAP desde el contenido de un .p12/.pfx y su contraseña
+func NewClientFromPKCS12(env Environment, p12Data []byte, password string) (*Client, error) {
+	key, leaf, caCerts, err := pkcs12.DecodeChain(p12Data, password)
+	if err != nil {
+		return nil, fmt.Errorf("error cargando certificado PKCS#12: %w", err)
+	}
+
+	cert := tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, ca := range caCerts {
+		cert.Certificate = append(cert.Certificate, ca.Raw)
+	}
+
+	return NewClientWithCertificate(env, cert)
+}
+
+// NewClientWithCertificate crea un nuevo cliente SOAP a partir de un certificado ya cargado
+func NewClientWithCertificate(env Environment, cert tls.Certificate) (*Client, error) {
+	if err := env.Validate(); err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		URL:             env.ServiceURL,
+		Environment:     env,
+		EnvelopeBuilder: NewEnvelopeBuilder(),
+	}
+	if err := client.ReplaceCertificate(cert); err != nil {
+		return nil, err
+	}
+
+	// Configurar TLS con mTLS; el certificado se resuelve en cada handshake
+	// para que ReplaceCertificate aplique a las conexiones nuevas
 	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		MinVersion:   tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			current := client.Certificate()
+			return &current, nil
+		},
+		MinVersion: tls.VersionTLS12,
 	}
 
 	// Crear cliente HTTP con TLS
-	httpClient := &http.Client{
+	client.HTTPClient = &http.Client{
 		Timeout: 30 * time.Second,
 		Transport: &http.Transport{
 			TLSClientConfig: tlsConfig,
 		},
 	}
 
-	// Crear HeaderBuilder para WS-Security
+	return client, nil
+}
+
+// Certificate retorna el certificado mTLS y WS-Security vigente
+func (c *Client) Certificate() tls.Certificate {
+	return c.credentials.Load().certificate
+}
+
+// ReplaceCertificate reemplaza de forma atómica el certificado usado para mTLS y WS-Security.
+// Es seguro llamarlo mientras hay envíos en curso: los envíos ya iniciados terminan con el
+// certificado anterior y las conexiones ociosas se cierran para que las nuevas usen el nuevo.
+func (c *Client) ReplaceCertificate(cert tls.Certificate) error {
 	headerBuilder, err := wssecurity.NewHeaderBuilder(cert)
 	if err != nil {
-		return nil, fmt.Errorf("error creando header builder: %w", err)
+		return fmt.Errorf("error creando header builder: %w", err)
 	}
 
-	return &Client{
-		URL:             Endpoints[environment],
-		Environment:     environment,
-		Certificate:     cert,
-		HTTPClient:      httpClient,
-		EnvelopeBuilder: NewEnvelopeBuilder(),
-		HeaderBuilder:   headerBuilder,
-	}, nil
+	c.credentials.Store(&credentials{
+		certificate:   cert,
+		headerBuilder: headerBuilder,
+	})
+	if c.HTTPClient != nil {
+		c.HTTPClient.CloseIdleConnections()
+	}
+	return nil
 }
 
 // SendInvoice envía una factura a DIAN
@@ -82,7 +142,7 @@ func (c *Client) SendInvoice(fileName string, signedXML []byte) (*Response, erro
 
 	// 2. Generar WS-Security Header (con wsa:To firmado - requerido por DIAN)
 	wsaAction := "http://wcf.dian.colombia/IWcfDianCustomerServices/SendBillSync"
-	wsSecurityHeader, err := c.HeaderBuilder.Build(c.URL)
+	wsSecurityHeader, err := c.credentials.Load().headerBuilder.Build(c.URL)
 	if err != nil {
 		return nil, fmt.Errorf("error generando WS-Security header: %w", err)
 	}
@@ -97,11 +157,6 @@ func (c *Client) SendInvoice(fileName string, signedXML []byte) (*Response, erro
 		return nil, fmt.Errorf("error construyendo envelope SOAP: %w", err)
 	}
 
-	// Debug: imprimir mensaje SOAP
-	fmt.Println("\n=== MENSAJE SOAP ENVIADO ===")
-	fmt.Println(string(soapMessage))
-	fmt.Println("============================\n")
-
 	// 4. Crear petición HTTP
 	req, err := http.NewRequest("POST", c.URL, bytes.NewBuffer(soapMessage))
 	if err != nil {
@@ -127,25 +182,46 @@ func (c *Client) SendInvoice(fileName string, signedXML []byte) (*Response, erro
 		return nil, fmt.Errorf("error leyendo respuesta: %w", err)
 	}
 
-	// Debug: imprimir respuesta
-	fmt.Println("\n=== RESPUESTA DIAN ===")
-	fmt.Printf("Status Code: %d\n", resp.StatusCode)
-	fmt.Println(string(body))
-	fmt.Println("======================\n")
+	// El mensaje lleva la factura firmada y el BinarySecurityToken: solo se registran metadatos
+	if c.Logger != nil {
+		c.Logger.Debug("envío SOAP a DIAN",
+			"archivo", fileName,
+			"url", c.URL,
+			"bytes_enviados", len(soapMessage),
+			"status", resp.StatusCode,
+			"bytes_recibidos", len(body),
+		)
+	}
 
 	// 7. Verificar código de estado
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("DIAN retornó código %d: %s", resp.StatusCode, string(body))
 	}
 
-	// 8. Parsear respuesta SOAP
-	var responseEnvelope ResponseEnvelope
-	if err := xml.Unmarshal(body, &responseEnvelope); err != nil {
-		return nil, fmt.Errorf("error parseando respuesta SOAP: %w", err)
+	// 8. Parsear respuesta SOAP. Con Verifier se verifican firma y timestamp, y el resultado
+	// se lee solo del Body firmado
+	var responseBody ResponseBody
+	if c.Verifier != nil {
+		verified, err := c.Verifier.Verify(body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnverifiedResponse, err)
+		}
+		if len(verified.Body) == 0 {
+			return nil, fmt.Errorf("%w: el Body de la respuesta no está firmado", ErrUnverifiedResponse)
+		}
+		if err := xml.Unmarshal(verified.Body, &responseBody); err != nil {
+			return nil, fmt.Errorf("error parseando respuesta SOAP: %w", err)
+		}
+	} else {
+		var responseEnvelope ResponseEnvelope
+		if err := xml.Unmarshal(body, &responseEnvelope); err != nil {
+			return nil, fmt.Errorf("error parseando respuesta SOAP: %w", err)
+		}
+		responseBody = responseEnvelope.Body
 	}
 
 	// 9. Decodificar respuesta de DIAN (viene en base64)
-	responseData, err := base64.StdEncoding.DecodeString(responseEnvelope.Body.SendBillSyncResponse.Result)
+	responseData, err := base64.StdEncoding.DecodeString(responseBody.SendBillSyncResponse.Result)
 	if err != nil {
 		return nil, fmt.Errorf("error decodificando respuesta DIAN: %w", err)
 	}
@@ -167,6 +243,10 @@ func parseResponse(data []byte) (*Response, error) {
 		StatusCode    string   `xml:"DocumentResponse>Response>Status>StatusReasonCode"`
 		StatusMessage string   `xml:"DocumentResponse>Response>Status>StatusReason"`
 		CUFE          string   `xml:"DocumentResponse>DocumentReference>UUID"`
+		LineResponses []struct {
+			Code        string `xml:"Response>ResponseCode"`
+			Description string `xml:"Response>Description"`
+		} `xml:"DocumentResponse>LineResponse"`
 	}
 
 	var dianResp DianResponse
@@ -174,10 +254,17 @@ func parseResponse(data []byte) (*Response, error) {
 		return nil, err
 	}
 
+	// Reglas de validación incumplidas (ej: "FAD06: Valor del CUFE no está calculado correctamente")
+	var errorMessages []string
+	for _, line := range dianResp.LineResponses {
+		errorMessages = append(errorMessages, fmt.Sprintf("%s: %s", line.Code, line.Description))
+	}
+
 	return &Response{
 		IsValid:       dianResp.IsValid == "00",
 		StatusCode:    dianResp.StatusCode,
 		StatusMessage: dianResp.StatusMessage,
+		ErrorMessages: errorMessages,
 		CUFE:          dianResp.CUFE,
 	}, nil
 }
@@ -0,0 +1,194 @@
+package soap
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diegofxm/go-dian/pkg/environment"
+	"github.com/diegofxm/go-dian/pkg/wssecurity"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	testSOAPNamespace = "http://www.w3.org/2003/05/soap-envelope"
+	testWsuNamespace  = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+)
+
+// newTestCertificate genera un certificado autofirmado
+func newTestCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "PRUEBA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// responseBody retorna un Body de SendBillSyncResponse con el ResponseCode de DIAN indicado (sin wsu:Id si id es vacío)
+func responseBody(id, responseCode string) string {
+	result := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(
+		`<ApplicationResponse><DocumentResponse><Response><ResponseCode>%s</ResponseCode></Response></DocumentResponse></ApplicationResponse>`, responseCode)))
+	attrs := fmt.Sprintf(`xmlns:s="%s"`, testSOAPNamespace)
+	if id != "" {
+		attrs += fmt.Sprintf(` xmlns:wsu="%s" wsu:Id="%s"`, testWsuNamespace, id)
+	}
+	return fmt.Sprintf(`<s:Body %s><SendBillSyncResponse xmlns="http://wcf.dian.colombia"><SendBillSyncResult>%s</SendBillSyncResult></SendBillSyncResponse></s:Body>`,
+		attrs, result)
+}
+
+// newResponder retorna un servidor que responde con el Body firmado seguido de extra
+func newResponder(t *testing.T, cert tls.Certificate, signed, extra string) *httptest.Server {
+	t.Helper()
+
+	builder, err := wssecurity.NewHeaderBuilder(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header, err := builder.Build("https://dian.test", wssecurity.SignedPart{ID: "_1", XML: signed})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `<s:Envelope xmlns:s="%s"><s:Header>%s</s:Header>%s%s</s:Envelope>`,
+			testSOAPNamespace, header.ToXML("SendBillSyncResponse"), signed, extra)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSendInvoiceVerifiedBody(t *testing.T) {
+	dian := newTestCertificate(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(dian.Leaf)
+
+	tests := []struct {
+		name  string
+		extra string
+		valid bool
+		err   error
+	}{
+		{
+			name:  "respuesta firmada",
+			valid: false,
+		},
+		{
+			// Un Body sin firmar ni wsu:Id después del firmado no puede cambiar el resultado leído
+			name:  "Body duplicado sin firmar",
+			extra: responseBody("", "00"),
+			err:   ErrUnverifiedResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newResponder(t, dian, responseBody("_1", "99"), tt.extra)
+			client, err := NewClientWithCertificate(environment.Test.WithServiceURL(srv.URL), newTestCertificate(t))
+			if err != nil {
+				t.Fatalf("NewClientWithCertificate: %v", err)
+			}
+			client.Verifier = wssecurity.NewVerifier(roots)
+
+			resp, err := client.SendInvoice("fv.zip", []byte("<Invoice/>"))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, se esperaba %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendInvoice: %v", err)
+			}
+			if resp.IsValid != tt.valid {
+				t.Errorf("IsValid = %v, se esperaba %v", resp.IsValid, tt.valid)
+			}
+		})
+	}
+}
+
+func TestNewClientFromPKCS12(t *testing.T) {
+	ca := newTestCertificate(t)
+	caKey := ca.PrivateKey.(*rsa.PrivateKey)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "EMISOR DE PRUEBA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Leaf, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p12, err := pkcs12.Modern2023.Encode(key, leaf, []*x509.Certificate{ca.Leaf}, "secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClientFromPKCS12(environment.Test, p12, "secreto")
+	if err != nil {
+		t.Fatalf("NewClientFromPKCS12: %v", err)
+	}
+	cert := client.Certificate()
+	if !cert.Leaf.Equal(leaf) {
+		t.Errorf("Leaf = %s, se esperaba %s", cert.Leaf.Subject.CommonName, leaf.Subject.CommonName)
+	}
+	if len(cert.Certificate) != 2 || !bytes.Equal(cert.Certificate[1], ca.Leaf.Raw) {
+		t.Errorf("la cadena mTLS tiene %d certificados, se esperaba el certificado y su emisor", len(cert.Certificate))
+	}
+	if signer, ok := cert.PrivateKey.(*rsa.PrivateKey); !ok || !signer.Equal(key) {
+		t.Error("la clave privada no corresponde al PKCS#12")
+	}
+
+	if _, err := NewClientFromPKCS12(environment.Test, p12, "otra"); err == nil {
+		t.Error("se aceptó una contraseña incorrecta")
+	}
+
+	withoutKey, err := pkcs12.Modern2023.EncodeTrustStore([]*x509.Certificate{leaf}, "secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClientFromPKCS12(environment.Test, withoutKey, "secreto"); err == nil {
+		t.Error("se aceptó un PKCS#12 sin clave privada")
+	}
+}
@@ -0,0 +1,8 @@
+package soap
+
+import "errors"
+
+// Error types
+var (
+	ErrUnverifiedResponse = errors.New("respuesta DIAN sin firma WS-Security válida")
+)
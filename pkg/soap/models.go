@@ -41,10 +41,13 @@ type Response struct {
 
 // ResponseEnvelope representa el envelope de respuesta SOAP
 type ResponseEnvelope struct {
-	XMLName xml.Name `xml:"Envelope"`
-	Body    struct {
-		SendBillSyncResponse struct {
-			Result string `xml:"SendBillSyncResult"`
-		} `xml:"SendBillSyncResponse"`
-	} `xml:"Body"`
+	XMLName xml.Name     `xml:"Envelope"`
+	Body    ResponseBody `xml:"Body"`
+}
+
+// ResponseBody representa el Body de la respuesta SOAP
+type ResponseBody struct {
+	SendBillSyncResponse struct {
+		Result string `xml:"SendBillSyncResult"`
+	} `xml:"SendBillSyncResponse"`
 }
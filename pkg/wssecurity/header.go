@@ -1,6 +1,7 @@
 package wssecurity
 
 import (
+	"crypto"
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
@@ -31,7 +32,7 @@ type BinarySecurityToken struct {
 // HeaderBuilder construye un WS-Security Header completo
 type HeaderBuilder struct {
 	certificate *x509.Certificate
-	privateKey  *rsa.PrivateKey
+	signer      crypto.Signer
 	certBytes   []byte
 }
 
@@ -43,21 +44,24 @@ func NewHeaderBuilder(cert tls.Certificate) (*HeaderBuilder, error) {
 		return nil, fmt.Errorf("error parseando certificado: %w", err)
 	}
 
-	// Extraer llave privada RSA
-	privateKey, ok := cert.PrivateKey.(*rsa.PrivateKey)
+	// La llave privada puede ser local o un crypto.Signer externo (HSM, KMS)
+	signer, ok := cert.PrivateKey.(crypto.Signer)
 	if !ok {
+		return nil, fmt.Errorf("la llave privada no implementa crypto.Signer")
+	}
+	if _, ok := signer.Public().(*rsa.PublicKey); !ok {
 		return nil, fmt.Errorf("la llave privada no es RSA")
 	}
 
 	return &HeaderBuilder{
 		certificate: x509Cert,
-		privateKey:  privateKey,
+		signer:      signer,
 		certBytes:   cert.Certificate[0],
 	}, nil
 }
 
-// Build construye el WS-Security Header completo con wsa:To firmado
-func (hb *HeaderBuilder) Build(wsaToURL string) (*Header, error) {
+// Build construye el WS-Security Header completo con wsa:To y las partes adicionales firmados
+func (hb *HeaderBuilder) Build(wsaToURL string, parts ...SignedPart) (*Header, error) {
 	// 1. Generar IDs únicos
 	securityTokenID := "SecurityToken-" + uuid.New().String()
 	timestampID := "Timestamp-" + uuid.New().String()
@@ -76,8 +80,8 @@ func (hb *HeaderBuilder) Build(wsaToURL string) (*Header, error) {
 	timestamp := NewTimestamp(timestampID, 5*time.Minute)
 
 	// 4. Crear Signature (firma Timestamp Y wsa:To - requerido por DIAN)
-	signer := NewSigner(hb.privateKey, hb.certificate, hb.certBytes)
-	signature, err := signer.SignTimestamp(timestamp, securityTokenID, wsaToID, wsaToURL)
+	signer := NewSigner(hb.signer, hb.certificate, hb.certBytes)
+	signature, err := signer.SignTimestamp(timestamp, securityTokenID, wsaToID, wsaToURL, parts...)
 	if err != nil {
 		return nil, fmt.Errorf("error firmando timestamp: %w", err)
 	}
@@ -3,11 +3,12 @@ package wssecurity
 import (
 	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"fmt"
+
+	"github.com/diegofxm/go-dian/pkg/c14n"
 )
 
 // Signature representa una firma digital XMLDSig
@@ -44,13 +45,13 @@ type SecurityTokenReference struct {
 
 // Signer maneja la firma de documentos
 type Signer struct {
-	privateKey  *rsa.PrivateKey
+	privateKey  crypto.Signer
 	certificate *x509.Certificate
 	certBytes   []byte
 }
 
-// NewSigner crea un nuevo firmador con certificado y llave privada
-func NewSigner(privateKey *rsa.PrivateKey, certificate *x509.Certificate, certBytes []byte) *Signer {
+// NewSigner crea un nuevo firmador con certificado y llave privada (local o crypto.Signer externo)
+func NewSigner(privateKey crypto.Signer, certificate *x509.Certificate, certBytes []byte) *Signer {
 	return &Signer{
 		privateKey:  privateKey,
 		certificate: certificate,
@@ -58,11 +59,18 @@ func NewSigner(privateKey *rsa.PrivateKey, certificate *x509.Certificate, certBy
 	}
 }
 
-// SignTimestamp firma un timestamp y wsa:To, retorna una Signature completa
-func (s *Signer) SignTimestamp(timestamp *Timestamp, securityTokenID string, wsaToID string, wsaToURL string) (*Signature, error) {
+// SignedPart es un elemento adicional cubierto por la firma (ej: el Body de una respuesta).
+// XML es el elemento tal como aparecerá en el mensaje, con su wsu:Id y declarando los namespaces que utiliza.
+type SignedPart struct {
+	ID  string
+	XML string
+}
+
+// SignTimestamp firma un timestamp, wsa:To y las partes adicionales, retorna una Signature completa
+func (s *Signer) SignTimestamp(timestamp *Timestamp, securityTokenID string, wsaToID string, wsaToURL string, parts ...SignedPart) (*Signature, error) {
 	// 1. Generar DigestValue del Timestamp
 	timestampXML := timestamp.ToXML()
-	timestampCanonical, err := CanonicalizeSigned(timestampXML)
+	timestampCanonical, err := canonicalize(timestampXML)
 	if err != nil {
 		return nil, fmt.Errorf("error canonicalizando timestamp: %w", err)
 	}
@@ -76,13 +84,13 @@ func (s *Signer) SignTimestamp(timestamp *Timestamp, securityTokenID string, wsa
 		DigestMethod: "http://www.w3.org/2001/04/xmlenc#sha256",
 		DigestValue:  timestampDigestB64,
 		Transforms: []string{
-			"http://www.w3.org/2001/10/xml-exc-c14n#",
+			c14n.ExcC14N,
 		},
 	}
 
 	// 3. Generar DigestValue del wsa:To (REQUERIDO POR DIAN)
 	wsaToXML := fmt.Sprintf(`<wsa:To xmlns:wsa="http://www.w3.org/2005/08/addressing" xmlns:wsu="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd" wsu:Id="%s">%s</wsa:To>`, wsaToID, wsaToURL)
-	wsaToCanonical, err := CanonicalizeSigned(wsaToXML)
+	wsaToCanonical, err := canonicalize(wsaToXML)
 	if err != nil {
 		return nil, fmt.Errorf("error canonicalizando wsa:To: %w", err)
 	}
@@ -96,27 +104,42 @@ func (s *Signer) SignTimestamp(timestamp *Timestamp, securityTokenID string, wsa
 		DigestMethod: "http://www.w3.org/2001/04/xmlenc#sha256",
 		DigestValue:  wsaToDigestB64,
 		Transforms: []string{
-			"http://www.w3.org/2001/10/xml-exc-c14n#",
+			c14n.ExcC14N,
 		},
 	}
 
-	// 5. Crear SignedInfo con AMBAS referencias (Timestamp Y wsa:To)
+	// 5. Crear SignedInfo con AMBAS referencias (Timestamp Y wsa:To) y las partes adicionales
 	signedInfo := &SignedInfo{
-		CanonicalizationMethod: "http://www.w3.org/2001/10/xml-exc-c14n#",
+		CanonicalizationMethod: c14n.ExcC14N,
 		SignatureMethod:        "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256",
 		References:             []*Reference{refTimestamp, refWsaTo},
 	}
+	for _, part := range parts {
+		partCanonical, err := canonicalize(part.XML)
+		if err != nil {
+			return nil, fmt.Errorf("error canonicalizando %s: %w", part.ID, err)
+		}
+		partDigest := sha256.Sum256(partCanonical)
+		signedInfo.References = append(signedInfo.References, &Reference{
+			URI:          "#" + part.ID,
+			DigestMethod: "http://www.w3.org/2001/04/xmlenc#sha256",
+			DigestValue:  base64.StdEncoding.EncodeToString(partDigest[:]),
+			Transforms: []string{
+				c14n.ExcC14N,
+			},
+		})
+	}
 
 	// 6. Canonicalizar SignedInfo
 	signedInfoXML := signedInfo.ToXML()
-	signedInfoCanonical, err := CanonicalizeSigned(signedInfoXML)
+	signedInfoCanonical, err := canonicalize(signedInfoXML)
 	if err != nil {
 		return nil, fmt.Errorf("error canonicalizando SignedInfo: %w", err)
 	}
 
 	// 7. Firmar SignedInfo
 	signedInfoHash := sha256.Sum256(signedInfoCanonical)
-	signatureBytes, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, signedInfoHash[:])
+	signatureBytes, err := s.privateKey.Sign(rand.Reader, signedInfoHash[:], crypto.SHA256)
 	if err != nil {
 		return nil, fmt.Errorf("error firmando: %w", err)
 	}
@@ -137,6 +160,12 @@ func (s *Signer) SignTimestamp(timestamp *Timestamp, securityTokenID string, wsa
 	}, nil
 }
 
+// canonicalize aplica Exclusive XML Canonicalization a un fragmento que declara sus namespaces.
+// Como solo se emiten los namespaces utilizados, el resultado coincide con el del elemento dentro del envelope.
+func canonicalize(fragment string) ([]byte, error) {
+	return c14n.Canonicalize([]byte(fragment), c14n.Options{Exclusive: true})
+}
+
 // ToXML genera el XML de SignedInfo
 func (si *SignedInfo) ToXML() string {
 	xml := `<ds:SignedInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#">`
@@ -0,0 +1,402 @@
+package wssecurity
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/diegofxm/go-dian/pkg/c14n"
+)
+
+const (
+	wsuNamespace = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+	dsNamespace  = "http://www.w3.org/2000/09/xmldsig#"
+)
+
+// Verifier verifica el header WS-Security de mensajes SOAP recibidos
+type Verifier struct {
+	ClockSkew time.Duration    // Tolerancia de reloj para el Timestamp
+	Now       func() time.Time // Reloj usado para validar el Timestamp
+	Roots     *x509.CertPool   // Raíces de confianza del certificado firmante. Requerido
+
+	// InsecureSkipChain acepta cualquier certificado firmante sin validar su cadena.
+	// Solo para pruebas: sin raíces, cualquiera puede firmar un mensaje con un certificado autofirmado.
+	InsecureSkipChain bool
+
+	// UnsignedBody acepta mensajes cuyo Body no está firmado. Las peticiones a DIAN firman
+	// Timestamp y wsa:To, pero las respuestas deben firmar Timestamp y Body.
+	UnsignedBody bool
+}
+
+// VerifiedHeader contiene el resultado de una verificación exitosa
+type VerifiedHeader struct {
+	Certificate *x509.Certificate // Certificado que firmó el mensaje
+	Created     time.Time         // wsu:Created del Timestamp
+	Expires     time.Time         // wsu:Expires del Timestamp
+	SignedIDs   []string          // wsu:Id de los elementos cubiertos por la firma
+
+	// Body es el elemento Body verificado, tal como aparece en el mensaje. Los datos de la
+	// respuesta deben leerse de aquí y no del mensaje completo. Vacío con UnsignedBody.
+	Body []byte
+}
+
+// NewVerifier crea un verificador de respuestas con tolerancia de reloj de 5 minutos
+// que acepta solo certificados firmantes emitidos por roots
+func NewVerifier(roots *x509.CertPool) *Verifier {
+	return &Verifier{
+		ClockSkew: 5 * time.Minute,
+		Now:       time.Now,
+		Roots:     roots,
+	}
+}
+
+// securityHeader representa el wsse:Security y el Body de un envelope SOAP 1.1 o 1.2.
+// Timestamp, Signature y Body son listas para rechazar mensajes con más de uno.
+type securityHeader struct {
+	XMLName  xml.Name
+	Security struct {
+		BinarySecurityToken []struct {
+			ID    string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Id,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd BinarySecurityToken"`
+		Timestamp []struct {
+			ID      string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Id,attr"`
+			Created string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Created"`
+			Expires string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Expires"`
+		} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Timestamp"`
+		Signature []struct {
+			SignedInfo struct {
+				CanonicalizationMethod c14nMethod `xml:"http://www.w3.org/2000/09/xmldsig# CanonicalizationMethod"`
+				SignatureMethod        struct {
+					Algorithm string `xml:"Algorithm,attr"`
+				} `xml:"http://www.w3.org/2000/09/xmldsig# SignatureMethod"`
+				Reference []struct {
+					URI          string       `xml:"URI,attr"`
+					Transforms   []c14nMethod `xml:"http://www.w3.org/2000/09/xmldsig# Transforms>Transform"`
+					DigestMethod struct {
+						Algorithm string `xml:"Algorithm,attr"`
+					} `xml:"http://www.w3.org/2000/09/xmldsig# DigestMethod"`
+					DigestValue string `xml:"http://www.w3.org/2000/09/xmldsig# DigestValue"`
+				} `xml:"http://www.w3.org/2000/09/xmldsig# Reference"`
+			} `xml:"http://www.w3.org/2000/09/xmldsig# SignedInfo"`
+			SignatureValue string `xml:"http://www.w3.org/2000/09/xmldsig# SignatureValue"`
+			TokenReference struct {
+				URI string `xml:"URI,attr"`
+			} `xml:"KeyInfo>SecurityTokenReference>Reference"`
+			X509Certificate string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"http://www.w3.org/2000/09/xmldsig# Signature"`
+	} `xml:"Header>Security"`
+	Body []struct {
+		ID string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Id,attr"`
+	} `xml:"Body"`
+}
+
+// c14nMethod es un ds:CanonicalizationMethod o ds:Transform con su ec:InclusiveNamespaces opcional
+type c14nMethod struct {
+	Algorithm           string `xml:"Algorithm,attr"`
+	InclusiveNamespaces struct {
+		PrefixList string `xml:"PrefixList,attr"`
+	} `xml:"http://www.w3.org/2001/10/xml-exc-c14n# InclusiveNamespaces"`
+}
+
+// options retorna las opciones de canonicalización del algoritmo declarado
+func (m c14nMethod) options() (c14n.Options, error) {
+	opts, err := c14n.ForAlgorithm(m.Algorithm)
+	if err != nil {
+		return opts, err
+	}
+	if opts.Exclusive {
+		opts.InclusivePrefixes = strings.Fields(m.InclusiveNamespaces.PrefixList)
+	}
+	return opts, nil
+}
+
+// Verify valida el Timestamp, los digests de cada Reference y el SignatureValue
+// del header WS-Security contra el certificado incluido en el mensaje.
+// Cada wsu:Id referenciado debe identificar un único elemento del mensaje, el envelope debe
+// tener un único Body hijo directo, y la firma debe cubrir el Timestamp y, salvo UnsignedBody,
+// ese Body (que se retorna en VerifiedHeader.Body).
+func (v *Verifier) Verify(message []byte) (*VerifiedHeader, error) {
+	if v.Roots == nil && !v.InsecureSkipChain {
+		return nil, fmt.Errorf("no hay raíces de confianza configuradas para el certificado firmante")
+	}
+
+	var header securityHeader
+	if err := xml.Unmarshal(message, &header); err != nil {
+		return nil, fmt.Errorf("envelope inválido: %w", err)
+	}
+	if header.XMLName.Local != "Envelope" {
+		return nil, fmt.Errorf("el mensaje no es un envelope SOAP (%s)", header.XMLName.Local)
+	}
+	// Un segundo Body sin firmar junto al firmado sería el que lee quien parsea el mensaje
+	if len(header.Body) != 1 {
+		return nil, fmt.Errorf("el envelope debe contener exactamente un Body (%d)", len(header.Body))
+	}
+	sec := header.Security
+	if len(sec.Signature) != 1 {
+		return nil, fmt.Errorf("wsse:Security debe contener exactamente un ds:Signature (%d)", len(sec.Signature))
+	}
+	if len(sec.Timestamp) != 1 {
+		return nil, fmt.Errorf("wsse:Security debe contener exactamente un wsu:Timestamp (%d)", len(sec.Timestamp))
+	}
+	signature, timestamp := sec.Signature[0], sec.Timestamp[0]
+
+	// Un segundo SignedInfo en el mensaje (ej: dentro del Body) haría ambigua su canonicalización
+	if n, err := c14n.Count(message, c14n.ByName(dsNamespace, "SignedInfo")); err != nil {
+		return nil, err
+	} else if n != 1 {
+		return nil, fmt.Errorf("el mensaje debe contener exactamente un ds:SignedInfo (%d)", n)
+	}
+
+	result := &VerifiedHeader{}
+
+	// 1. Ventana del Timestamp
+	var err error
+	result.Created, err = time.Parse(time.RFC3339, strings.TrimSpace(timestamp.Created))
+	if err != nil {
+		return nil, fmt.Errorf("wsu:Created inválido: %w", err)
+	}
+	result.Expires, err = time.Parse(time.RFC3339, strings.TrimSpace(timestamp.Expires))
+	if err != nil {
+		return nil, fmt.Errorf("wsu:Expires inválido: %w", err)
+	}
+	now := v.now()
+	if now.Add(v.ClockSkew).Before(result.Created) {
+		return nil, fmt.Errorf("timestamp creado en el futuro (%s)", timestamp.Created)
+	}
+	if now.Add(-v.ClockSkew).After(result.Expires) {
+		return nil, fmt.Errorf("timestamp expirado (%s)", timestamp.Expires)
+	}
+
+	// 2. Certificado firmante (BinarySecurityToken referenciado o X509Data)
+	certB64 := signature.X509Certificate
+	if ref := signature.TokenReference.URI; ref != "" {
+		if err := uniqueID(message, strings.TrimPrefix(ref, "#")); err != nil {
+			return nil, fmt.Errorf("SecurityTokenReference: %w", err)
+		}
+		certB64 = ""
+		for _, token := range sec.BinarySecurityToken {
+			if "#"+token.ID == ref {
+				certB64 = token.Value
+			}
+		}
+	}
+	if certB64 == "" {
+		return nil, fmt.Errorf("KeyInfo no referencia un certificado")
+	}
+	certDER, err := base64.StdEncoding.DecodeString(strings.TrimSpace(certB64))
+	if err != nil {
+		return nil, fmt.Errorf("certificado en base64 inválido: %w", err)
+	}
+	result.Certificate, err = x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("certificado inválido: %w", err)
+	}
+	publicKey, ok := result.Certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("la llave pública no es RSA")
+	}
+	if !v.InsecureSkipChain {
+		if _, err := result.Certificate.Verify(x509.VerifyOptions{
+			Roots:       v.Roots,
+			CurrentTime: now,
+			KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			return nil, fmt.Errorf("certificado no confiable: %w", err)
+		}
+	}
+
+	// 3. Digests de las referencias, cada una a un único elemento del mensaje
+	for _, ref := range signature.SignedInfo.Reference {
+		if !strings.HasPrefix(ref.URI, "#") {
+			return nil, fmt.Errorf("referencia %q: solo se admiten referencias por wsu:Id", ref.URI)
+		}
+		id := strings.TrimPrefix(ref.URI, "#")
+		if err := uniqueID(message, id); err != nil {
+			return nil, fmt.Errorf("referencia %s: %w", ref.URI, err)
+		}
+
+		// Sin transformaciones se aplica Canonical XML 1.0; las referencias al mismo documento omiten comentarios
+		opts := c14n.Options{}
+		for _, transform := range ref.Transforms {
+			if opts, err = transform.options(); err != nil {
+				return nil, fmt.Errorf("referencia %s: %w", ref.URI, err)
+			}
+		}
+		opts.WithComments = false
+
+		h, err := newDigest(ref.DigestMethod.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("referencia %s: %w", ref.URI, err)
+		}
+		canonical, err := c14n.CanonicalizeSubtree(message, byWsuID(id), opts)
+		if err != nil {
+			return nil, fmt.Errorf("error canonicalizando %s: %w", ref.URI, err)
+		}
+		h.Write(canonical)
+		if base64.StdEncoding.EncodeToString(h.Sum(nil)) != strings.TrimSpace(ref.DigestValue) {
+			return nil, fmt.Errorf("digest inválido para %s", ref.URI)
+		}
+		result.SignedIDs = append(result.SignedIDs, id)
+	}
+	if !result.Signs(timestamp.ID) {
+		return nil, fmt.Errorf("la firma no cubre el Timestamp")
+	}
+	if !v.UnsignedBody {
+		bodyID := header.Body[0].ID
+		if bodyID == "" {
+			return nil, fmt.Errorf("el Body no tiene wsu:Id")
+		}
+		if !result.Signs(bodyID) {
+			return nil, fmt.Errorf("la firma no cubre el Body")
+		}
+		// El Id es único en el mensaje, así que el elemento extraído es el Body hijo del envelope
+		if result.Body, err = ExtractElement(message, byWsuID(bodyID)); err != nil {
+			return nil, fmt.Errorf("Body: %w", err)
+		}
+	}
+
+	// 4. SignatureValue sobre SignedInfo canonicalizado, con el algoritmo declarado
+	hashFunc, err := signatureHash(signature.SignedInfo.SignatureMethod.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("SignedInfo: %w", err)
+	}
+	opts, err := signature.SignedInfo.CanonicalizationMethod.options()
+	if err != nil {
+		return nil, fmt.Errorf("SignedInfo: %w", err)
+	}
+	canonical, err := c14n.CanonicalizeSubtree(message, c14n.ByName(dsNamespace, "SignedInfo"), opts)
+	if err != nil {
+		return nil, fmt.Errorf("error canonicalizando SignedInfo: %w", err)
+	}
+	signatureValue, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature.SignatureValue))
+	if err != nil {
+		return nil, fmt.Errorf("SignatureValue inválido: %w", err)
+	}
+	h := hashFunc.New()
+	h.Write(canonical)
+	if err := rsa.VerifyPKCS1v15(publicKey, hashFunc, h.Sum(nil), signatureValue); err != nil {
+		return nil, fmt.Errorf("SignatureValue no corresponde al certificado: %w", err)
+	}
+
+	return result, nil
+}
+
+// byWsuID retorna una función de selección por atributo wsu:Id
+func byWsuID(id string) func(xml.StartElement) bool {
+	return func(start xml.StartElement) bool {
+		for _, attr := range start.Attr {
+			if attr.Name.Space == wsuNamespace && attr.Name.Local == "Id" && attr.Value == id {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// uniqueID verifica que el Id identifique exactamente un elemento del mensaje.
+// Se cuenta cualquier atributo Id, no solo wsu:Id, para que un elemento con el mismo
+// valor en otro atributo no pueda suplantar al referenciado.
+func uniqueID(message []byte, id string) error {
+	if id == "" {
+		return fmt.Errorf("Id vacío")
+	}
+	n, err := c14n.Count(message, c14n.ByID(id))
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return fmt.Errorf("el Id %q debe identificar un único elemento (%d)", id, n)
+	}
+	return nil
+}
+
+// newDigest retorna el hash de un DigestMethod admitido (familia SHA-2)
+func newDigest(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "http://www.w3.org/2001/04/xmlenc#sha256":
+		return sha256.New(), nil
+	case "http://www.w3.org/2001/04/xmldsig-more#sha384":
+		return sha512.New384(), nil
+	case "http://www.w3.org/2001/04/xmlenc#sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("algoritmo de digest no admitido: %q", algorithm)
+}
+
+// signatureHash retorna el hash de un SignatureMethod RSA admitido (familia SHA-2)
+func signatureHash(algorithm string) (crypto.Hash, error) {
+	switch algorithm {
+	case "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256":
+		return crypto.SHA256, nil
+	case "http://www.w3.org/2001/04/xmldsig-more#rsa-sha384":
+		return crypto.SHA384, nil
+	case "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512":
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("algoritmo de firma no admitido: %q", algorithm)
+}
+
+// Signs indica si la firma cubre el elemento con el wsu:Id dado
+func (h *VerifiedHeader) Signs(id string) bool {
+	for _, signed := range h.SignedIDs {
+		if id != "" && signed == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now == nil {
+		return time.Now()
+	}
+	return v.Now()
+}
+
+// ExtractElement retorna los bytes originales del primer elemento que cumple match
+func ExtractElement(data []byte, match func(xml.StartElement) bool) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	start := int64(-1)
+	depth := 0
+
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if start >= 0 {
+				depth++
+			} else if match(t) {
+				start = offset
+				depth = 1
+			}
+		case xml.EndElement:
+			if start >= 0 {
+				depth--
+				if depth == 0 {
+					return data[start:decoder.InputOffset()], nil
+				}
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("elemento no encontrado")
+}
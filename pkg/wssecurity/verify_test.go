@@ -0,0 +1,210 @@
+package wssecurity
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSOAPNamespace = "http://www.w3.org/2003/05/soap-envelope"
+
+// newTestCertificate genera un certificado autofirmado para firmar mensajes
+func newTestCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "DIAN PRUEBA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// signedResponse construye una respuesta SOAP con el Body dado, firmada sobre Timestamp, wsa:To y las partes indicadas
+func signedResponse(t *testing.T, cert tls.Certificate, body string, parts ...SignedPart) string {
+	t.Helper()
+
+	builder, err := NewHeaderBuilder(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := builder.Build("https://dian.test/WcfDianCustomerServices.svc", parts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf(`<s:Envelope xmlns:s="%s"><s:Header>%s</s:Header>%s</s:Envelope>`,
+		testSOAPNamespace, header.ToXML("SendBillSyncResponse"), body)
+}
+
+// testBody retorna un Body con wsu:Id que declara los namespaces que utiliza
+func testBody(id, content string) string {
+	return fmt.Sprintf(`<s:Body xmlns:s="%s" xmlns:wsu="%s" wsu:Id="%s"><SendBillSyncResponse xmlns="http://wcf.dian.colombia">%s</SendBillSyncResponse></s:Body>`,
+		testSOAPNamespace, wsuNamespace, id, content)
+}
+
+func TestVerify(t *testing.T) {
+	cert := newTestCertificate(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(newTestCertificate(t).Leaf)
+
+	body := testBody("_1", "<Result>OK</Result>")
+	valid := signedResponse(t, cert, body, SignedPart{ID: "_1", XML: body})
+
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		message  string
+		err      string
+	}{
+		{
+			name:     "respuesta firmada sobre Timestamp y Body",
+			verifier: NewVerifier(roots),
+			message:  valid,
+		},
+		{
+			name:     "sin raíces de confianza",
+			verifier: NewVerifier(nil),
+			message:  valid,
+			err:      "no hay raíces de confianza",
+		},
+		{
+			name:     "certificado de otra entidad",
+			verifier: NewVerifier(otherRoots),
+			message:  valid,
+			err:      "certificado no confiable",
+		},
+		{
+			name:     "Body sin firmar",
+			verifier: NewVerifier(roots),
+			message:  signedResponse(t, cert, body),
+			err:      "la firma no cubre el Body",
+		},
+		{
+			name:     "Body sin firmar en una petición",
+			verifier: &Verifier{Roots: roots, ClockSkew: time.Minute, UnsignedBody: true},
+			message:  signedResponse(t, cert, body),
+		},
+		{
+			name:     "Body alterado",
+			verifier: NewVerifier(roots),
+			message:  strings.Replace(valid, "<Result>OK</Result>", "<Result>KO</Result>", 1),
+			err:      "digest inválido para #_1",
+		},
+		{
+			// El Body firmado se mueve a un elemento desconocido del header y se agrega un Body nuevo con el mismo Id
+			name:     "Id duplicado (signature wrapping)",
+			verifier: NewVerifier(roots),
+			message:  strings.Replace(valid, "</s:Header>", "<Wrapper>"+body+"</Wrapper></s:Header>", 1),
+			err:      `el Id "_1" debe identificar un único elemento (2)`,
+		},
+		{
+			// Un segundo Body sin firmar después del firmado: quien parsea el envelope leería el último
+			name:     "Body duplicado (signature wrapping)",
+			verifier: NewVerifier(roots),
+			message:  strings.Replace(valid, "</s:Envelope>", `<s:Body><SendBillSyncResponse xmlns="http://wcf.dian.colombia"><Result>EVIL</Result></SendBillSyncResponse></s:Body></s:Envelope>`, 1),
+			err:      "exactamente un Body (2)",
+		},
+		{
+			name:     "Body firmado fuera del envelope",
+			verifier: NewVerifier(roots),
+			message:  strings.Replace(strings.Replace(valid, body, testBody("_2", "<Result>EVIL</Result>"), 1), "</s:Header>", "<Wrapper>"+body+"</Wrapper></s:Header>", 1),
+			err:      "la firma no cubre el Body",
+		},
+		{
+			name:     "Timestamp duplicado",
+			verifier: NewVerifier(roots),
+			message:  strings.Replace(valid, "</wsse:Security>", `<wsu:Timestamp wsu:Id="replay"><wsu:Created>2020-01-01T00:00:00Z</wsu:Created><wsu:Expires>2020-01-01T00:05:00Z</wsu:Expires></wsu:Timestamp></wsse:Security>`, 1),
+			err:      "exactamente un wsu:Timestamp",
+		},
+		{
+			name:     "SignedInfo adicional en el Body",
+			verifier: NewVerifier(roots),
+			message:  strings.Replace(valid, "<Result>OK</Result>", `<Result>OK</Result><ds:SignedInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"></ds:SignedInfo>`, 1),
+			err:      "exactamente un ds:SignedInfo",
+		},
+		{
+			name:     "digest SHA-1",
+			verifier: NewVerifier(roots),
+			message:  strings.Replace(valid, "http://www.w3.org/2001/04/xmlenc#sha256", "http://www.w3.org/2000/09/xmldsig#sha1", 1),
+			err:      "algoritmo de digest no admitido",
+		},
+		{
+			name:     "SignatureMethod RSA-SHA1",
+			verifier: NewVerifier(roots),
+			message:  strings.Replace(valid, "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256", "http://www.w3.org/2000/09/xmldsig#rsa-sha1", 1),
+			err:      "algoritmo de firma no admitido",
+		},
+		{
+			name:     "timestamp expirado",
+			verifier: &Verifier{Roots: roots, ClockSkew: time.Minute, Now: func() time.Time { return time.Now().Add(time.Hour) }},
+			message:  valid,
+			err:      "timestamp expirado",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := tt.verifier.Verify([]byte(tt.message))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if !tt.verifier.UnsignedBody {
+					if !header.Signs("_1") {
+						t.Errorf("SignedIDs = %v, no incluye el Body", header.SignedIDs)
+					}
+					if string(header.Body) != body {
+						t.Errorf("Body = %s, se esperaba el Body firmado", header.Body)
+					}
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Verify aceptó el mensaje, se esperaba %q", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %q, se esperaba %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyInsecureSkipChain(t *testing.T) {
+	cert := newTestCertificate(t)
+	body := testBody("_1", "<Result>OK</Result>")
+	message := signedResponse(t, cert, body, SignedPart{ID: "_1", XML: body})
+
+	verifier := &Verifier{ClockSkew: time.Minute, InsecureSkipChain: true}
+	header, err := verifier.Verify([]byte(message))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !header.Certificate.Equal(cert.Leaf) {
+		t.Error("el certificado firmante no corresponde")
+	}
+}